@@ -3,6 +3,7 @@ package albums
 import (
 	"bufio"
 	"fmt"
+	"gphotos/core/i18n"
 	"gphotos/core/models"
 	"os"
 	"sort"
@@ -31,19 +32,27 @@ func ListDistinctAlbums(photos []*models.Photo) []string {
 	return albums
 }
 
-func PromptAlbumSelection(albums []string) ([]string, error) {
+// PromptAlbumSelection asks for a priority-ordered album list. When previous
+// is non-empty it is offered as the default for an empty answer, and "none"
+// selects no albums.
+func PromptAlbumSelection(albums []string, previous []string) ([]string, error) {
 	if len(albums) == 0 {
 		fmt.Println("No albums found.")
 		return nil, nil
 	}
 
-	fmt.Println("Albums found:")
+	fmt.Println(i18n.T("Albums found:"))
 	for i, name := range albums {
 		fmt.Printf("%d) %s\n", i+1, name)
 	}
-	fmt.Println("Enter album numbers or names in priority order.")
-	fmt.Println("Examples: 1,3,5  OR  Vacation,Family  OR  all  OR  (empty to keep none)")
-	fmt.Print("Selection: ")
+	fmt.Println(i18n.T("Enter album numbers or names in priority order."))
+	if len(previous) > 0 {
+		fmt.Printf("Previous selection: %s\n", strings.Join(previous, ", "))
+		fmt.Println("Examples: 1,3,5  OR  Vacation,Family  OR  all  OR  none  OR  (empty to reuse previous)")
+	} else {
+		fmt.Println("Examples: 1,3,5  OR  Vacation,Family  OR  all  OR  (empty to keep none)")
+	}
+	fmt.Print(i18n.T("Selection: "))
 
 	reader := bufio.NewReader(os.Stdin)
 	line, err := reader.ReadString('\n')
@@ -53,15 +62,39 @@ func PromptAlbumSelection(albums []string) ([]string, error) {
 
 	line = strings.TrimSpace(line)
 	if line == "" {
+		if len(previous) > 0 {
+			selected := append([]string(nil), previous...)
+			fmt.Print(i18n.Tf("Selected albums (priority order): %s\n", strings.Join(selected, ", ")))
+			return selected, nil
+		}
 		return nil, nil
 	}
-	if strings.EqualFold(line, "all") {
-		selected := append([]string(nil), albums...)
-		fmt.Printf("Selected albums (priority order): %s\n", strings.Join(selected, ", "))
-		return selected, nil
+	selected, err := ParseSelection(line, albums)
+	if err != nil {
+		return nil, err
 	}
 
-	parts := strings.Split(line, ",")
+	if len(selected) == 0 {
+		fmt.Println(i18n.T("No albums selected. All photos will go to the main library."))
+		return nil, nil
+	}
+	fmt.Print(i18n.Tf("Selected albums (priority order): %s\n", strings.Join(selected, ", ")))
+	return selected, nil
+}
+
+// ParseSelection resolves a comma-separated list of album numbers (1-based
+// into albums) or names, "all" or "none", into a priority-ordered selection.
+// Names match case-insensitively and repeated entries are dropped.
+func ParseSelection(value string, albums []string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "none") {
+		return nil, nil
+	}
+	if strings.EqualFold(value, "all") {
+		return append([]string(nil), albums...), nil
+	}
+
+	parts := strings.Split(value, ",")
 	selected := make([]string, 0, len(parts))
 	seen := make(map[string]struct{})
 
@@ -76,23 +109,18 @@ func PromptAlbumSelection(albums []string) ([]string, error) {
 			continue
 		}
 
-		if idx, err := strconv.Atoi(item); err == nil {
+		idx, err := strconv.Atoi(item)
+		if err == nil {
 			if idx < 1 || idx > len(albums) {
 				return nil, fmt.Errorf("album index out of range: %d", idx)
 			}
-			name := albums[idx-1]
-			if _, ok := seen[name]; ok {
-				continue
+			idx--
+		} else {
+			var ok bool
+			idx, ok = albumIndex[strings.ToLower(item)]
+			if !ok {
+				return nil, fmt.Errorf("unknown album name: %s", item)
 			}
-			seen[name] = struct{}{}
-			selected = append(selected, name)
-			continue
-		}
-
-		key := strings.ToLower(item)
-		idx, ok := albumIndex[key]
-		if !ok {
-			return nil, fmt.Errorf("unknown album name: %s", item)
 		}
 		name := albums[idx]
 		if _, ok := seen[name]; ok {
@@ -101,12 +129,6 @@ func PromptAlbumSelection(albums []string) ([]string, error) {
 		seen[name] = struct{}{}
 		selected = append(selected, name)
 	}
-
-	if len(selected) == 0 {
-		fmt.Println("No albums selected. All photos will go to the main library.")
-		return nil, nil
-	}
-	fmt.Printf("Selected albums (priority order): %s\n", strings.Join(selected, ", "))
 	return selected, nil
 }
 
@@ -0,0 +1,61 @@
+package albums
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+type savedSelection struct {
+	Priority []string `json:"priority"`
+}
+
+// LoadSelection reads the album priority list saved by a previous run.
+func LoadSelection(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var s savedSelection
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return s.Priority, nil
+}
+
+// SaveSelection stores the album priority list for the next run.
+func SaveSelection(path string, selected []string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(savedSelection{Priority: selected}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// FilterKnown keeps the entries of selected that are present in albums,
+// preserving priority order.
+func FilterKnown(selected []string, albums []string) []string {
+	known := make(map[string]bool, len(albums))
+	for _, name := range albums {
+		known[name] = true
+	}
+	var out []string
+	for _, name := range selected {
+		if known[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package albums
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gphotos/core/models"
+)
+
+// Strategy names accepted by OrderByStrategy.
+const (
+	StrategyManual       = "manual"
+	StrategySmallest     = "smallest"
+	StrategyLargest      = "largest"
+	StrategyNewest       = "newest"
+	StrategyAlphabetical = "alphabetical"
+)
+
+// ValidStrategy reports whether name is a known album priority strategy.
+func ValidStrategy(name string) bool {
+	switch name {
+	case StrategyManual, StrategySmallest, StrategyLargest, StrategyNewest, StrategyAlphabetical:
+		return true
+	default:
+		return false
+	}
+}
+
+// OrderByStrategy returns every album in priority order according to an
+// automatic strategy, so AssignFinalAlbums can place each photo without a
+// hand-curated list. Ties are broken alphabetically.
+func OrderByStrategy(strategy string, photos []*models.Photo, albums []string) ([]string, error) {
+	counts := make(map[string]int)
+	newest := make(map[string]time.Time)
+	for _, p := range photos {
+		if p == nil {
+			continue
+		}
+		var taken time.Time
+		if p.Meta.TakenTime != "" {
+			taken, _ = time.Parse(time.RFC3339, p.Meta.TakenTime)
+		}
+		for name, ok := range p.Albums {
+			if !ok {
+				continue
+			}
+			counts[name]++
+			if taken.After(newest[name]) {
+				newest[name] = taken
+			}
+		}
+	}
+
+	ordered := append([]string(nil), albums...)
+	var less func(a, b string) bool
+	switch strategy {
+	case StrategySmallest:
+		less = func(a, b string) bool { return counts[a] < counts[b] }
+	case StrategyLargest:
+		less = func(a, b string) bool { return counts[a] > counts[b] }
+	case StrategyNewest:
+		less = func(a, b string) bool { return newest[a].After(newest[b]) }
+	case StrategyAlphabetical:
+		less = func(a, b string) bool { return false }
+	default:
+		return nil, fmt.Errorf("unknown album strategy: %s", strategy)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return strings.ToLower(a) < strings.ToLower(b)
+	})
+	return ordered, nil
+}
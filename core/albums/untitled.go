@@ -0,0 +1,121 @@
+package albums
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gphotos/core/models"
+)
+
+// RangePlaceholder is replaced by an album's date range in the templates
+// passed to ProposeUntitledNames.
+const RangePlaceholder = "{{range}}"
+
+var untitledPattern = regexp.MustCompile(`(?i)^untitled\s*(\(\d+\))?$`)
+
+// IsUntitled reports whether name is one of Google Photos' placeholder
+// album names: "Untitled", "Untitled(1)", "Untitled (2)", ...
+func IsUntitled(name string) bool {
+	return untitledPattern.MatchString(strings.TrimSpace(name))
+}
+
+// UntitledRename is a proposed name for a placeholder album, with the files
+// and taken dates it was derived from.
+type UntitledRename struct {
+	Old         string
+	New         string
+	Files       int
+	First, Last time.Time
+}
+
+// ProposeUntitledNames suggests a name for every untitled album from the
+// taken dates of its photos. template must contain {{range}}, which becomes
+// the month ("2018-07") or month range ("2018-07 to 2018-09"). Names that
+// clash with another album get a " (2)", " (3)", ... suffix. Albums without
+// any dated photo keep their name and are not returned.
+func ProposeUntitledNames(photos []*models.Photo, template string) []UntitledRename {
+	byName := make(map[string]*UntitledRename)
+	taken := make(map[string]bool)
+	for _, p := range photos {
+		if p == nil {
+			continue
+		}
+		var t time.Time
+		if p.Meta.TakenTime != "" {
+			t, _ = time.Parse(time.RFC3339, p.Meta.TakenTime)
+		}
+		for name, ok := range p.Albums {
+			if !ok {
+				continue
+			}
+			taken[strings.ToLower(name)] = true
+			if !IsUntitled(name) {
+				continue
+			}
+			r := byName[name]
+			if r == nil {
+				r = &UntitledRename{Old: name}
+				byName[name] = r
+			}
+			r.Files++
+			if t.IsZero() {
+				continue
+			}
+			if r.First.IsZero() || t.Before(r.First) {
+				r.First = t
+			}
+			if t.After(r.Last) {
+				r.Last = t
+			}
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var renames []UntitledRename
+	for _, name := range names {
+		r := byName[name]
+		if r.First.IsZero() {
+			continue
+		}
+		dates := r.First.Format("2006-01")
+		if last := r.Last.Format("2006-01"); last != dates {
+			dates += " to " + last
+		}
+		base := strings.TrimSpace(strings.ReplaceAll(template, RangePlaceholder, dates))
+		r.New = base
+		for n := 2; taken[strings.ToLower(r.New)]; n++ {
+			r.New = fmt.Sprintf("%s (%d)", base, n)
+		}
+		taken[strings.ToLower(r.New)] = true
+		renames = append(renames, *r)
+	}
+	return renames
+}
+
+// RenameAlbums moves every photo's album memberships from the old names in
+// renames to the new ones.
+func RenameAlbums(photos []*models.Photo, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+	for _, p := range photos {
+		if p == nil {
+			continue
+		}
+		for old, name := range renames {
+			if !p.Albums[old] {
+				continue
+			}
+			delete(p.Albums, old)
+			p.Albums[name] = true
+		}
+	}
+}
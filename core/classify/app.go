@@ -0,0 +1,84 @@
+package classify
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gphotos/core/models"
+)
+
+var appPackages = map[string]string{
+	"com.whatsapp":                      "WhatsApp",
+	"com.whatsapp.w4b":                  "WhatsApp",
+	"com.snapchat.android":              "Snapchat",
+	"org.thoughtcrime.securesms":        "Signal",
+	"org.telegram.messenger":            "Telegram",
+	"com.facebook.orca":                 "Messenger",
+	"com.facebook.katana":               "Facebook",
+	"com.instagram.android":             "Instagram",
+	"jp.naver.line.android":             "LINE",
+	"com.viber.voip":                    "Viber",
+	"com.tencent.mm":                    "WeChat",
+	"com.discord":                       "Discord",
+	"com.twitter.android":               "Twitter",
+	"com.zhiliaoapp.musically":          "TikTok",
+	"com.google.android.apps.messaging": "Messages",
+}
+
+var messagingApps = map[string]bool{
+	"WhatsApp":  true,
+	"Snapchat":  true,
+	"Signal":    true,
+	"Telegram":  true,
+	"Messenger": true,
+	"LINE":      true,
+	"Viber":     true,
+	"WeChat":    true,
+	"Discord":   true,
+	"Messages":  true,
+}
+
+var appNamePatterns = []struct {
+	re  *regexp.Regexp
+	app string
+}{
+	{regexp.MustCompile(`(?i)^(IMG|VID|AUD|PTT|STK)-\d{8}-WA\d+`), "WhatsApp"},
+	{regexp.MustCompile(`(?i)^Snapchat-\d+`), "Snapchat"},
+	{regexp.MustCompile(`(?i)^signal-\d{4}-\d{2}-\d{2}`), "Signal"},
+	{regexp.MustCompile(`(?i)^received_\d+`), "Messenger"},
+	{regexp.MustCompile(`(?i)^FB_IMG_\d+`), "Facebook"},
+	{regexp.MustCompile(`(?i)^(IMG|VID)_\d+_\d+_\d+_Telegram`), "Telegram"},
+	{regexp.MustCompile(`(?i)^mmexport\d+`), "WeChat"},
+}
+
+// DetectApp returns the display name of the app a photo came from, using
+// the JSON appSource first, then well-known filename prefixes, then the
+// device folder it was uploaded from. It returns "" when unknown.
+func DetectApp(p *models.Photo) string {
+	if p == nil {
+		return ""
+	}
+	if app, ok := appPackages[strings.ToLower(strings.TrimSpace(p.Meta.AppSource))]; ok {
+		return app
+	}
+	base := filepath.Base(p.SrcPath)
+	for _, pat := range appNamePatterns {
+		if pat.re.MatchString(base) {
+			return pat.app
+		}
+	}
+	folder := strings.ToLower(p.Meta.Origin.MobileUploadDeviceFolder)
+	for _, app := range []string{"WhatsApp", "Telegram", "Signal", "Snapchat", "Instagram", "Messenger", "Viber"} {
+		if strings.Contains(folder, strings.ToLower(app)) {
+			return app
+		}
+	}
+	return ""
+}
+
+// IsMessagingApp reports whether app (as returned by DetectApp) is a chat
+// or messaging app rather than a camera or social feed.
+func IsMessagingApp(app string) bool {
+	return messagingApps[app]
+}
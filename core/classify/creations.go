@@ -0,0 +1,37 @@
+package classify
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gphotos/core/metadata"
+	"gphotos/core/models"
+)
+
+// creationSuffixes are the name markers Google appends to files its
+// assistant generated from the user's photos.
+var creationSuffixes = []string{"-COLLAGE", "-ANIMATION", "-EFFECTS", "-MOVIE", "-CINEMATIC"}
+
+var copyIndexRe = regexp.MustCompile(`\(\d+\)$`)
+
+// IsCreation reports whether a photo is a Google-generated creation
+// (collage, animation, stylized effect, or auto-made "Memories" movie)
+// rather than something the user shot.
+func IsCreation(p *models.Photo) bool {
+	if p == nil {
+		return false
+	}
+	if t := strings.ToUpper(strings.TrimSpace(p.Meta.Origin.CompositionType)); t != "" && t != "NONE" {
+		return true
+	}
+	base := filepath.Base(p.SrcPath)
+	name := strings.ToUpper(strings.TrimSuffix(base, filepath.Ext(base)))
+	name = copyIndexRe.ReplaceAllString(name, "")
+	for _, suffix := range creationSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return metadata.IsVideoPath(p.SrcPath) && strings.HasPrefix(name, "MEMORIES")
+}
@@ -0,0 +1,17 @@
+package classify
+
+import "gphotos/core/models"
+
+// IsPartnerShared reports whether a photo arrived through Partner Sharing.
+// Newer sidecars mark this explicitly; older ones only say the item came from
+// a shared album and carry no upload origin of the account owner's own.
+func IsPartnerShared(p *models.Photo) bool {
+	if p == nil {
+		return false
+	}
+	origin := p.Meta.Origin
+	if origin.FromPartnerSharing {
+		return true
+	}
+	return origin.FromSharedAlbum && !origin.MobileUpload && !origin.WebUpload
+}
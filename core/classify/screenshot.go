@@ -0,0 +1,46 @@
+package classify
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gphotos/core/metadata"
+	"gphotos/core/models"
+)
+
+var screenshotNameRe = regexp.MustCompile(`(?i)(screen[ _-]?shot|scrnshot|screen[ _-]?capture|bildschirmfoto|capture d.?[ée]cran|captura de pantalla)`)
+
+// Common short-side widths of phone, tablet, and desktop screens in pixels.
+var screenWidths = map[int]bool{
+	640: true, 720: true, 750: true, 768: true, 828: true, 1080: true,
+	1125: true, 1170: true, 1179: true, 1242: true, 1284: true, 1290: true,
+	1440: true, 1536: true, 1600: true, 1668: true, 2048: true,
+}
+
+// IsScreenshot reports whether a photo looks like a screen capture rather
+// than a camera photo, based on its name, upload folder, and PNG dimensions.
+func IsScreenshot(p *models.Photo) bool {
+	if p == nil {
+		return false
+	}
+	if screenshotNameRe.MatchString(filepath.Base(p.SrcPath)) {
+		return true
+	}
+	if strings.EqualFold(strings.TrimSpace(p.Meta.Origin.MobileUploadDeviceFolder), "Screenshots") {
+		return true
+	}
+	if kind, ok := metadata.DetectFileKind(p.SrcPath); !ok || kind != "png" {
+		return false
+	}
+	w, h, ok := metadata.ImageDimensions(p.SrcPath)
+	if !ok || w == 0 || h == 0 {
+		return false
+	}
+	short, long := w, h
+	if short > long {
+		short, long = long, short
+	}
+	ratio := float64(long) / float64(short)
+	return screenWidths[short] && ratio >= 1.3 && ratio <= 2.4
+}
@@ -0,0 +1,128 @@
+package classify
+
+import (
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gphotos/core/metadata"
+	"gphotos/core/models"
+)
+
+// Video kinds returned by VideoKind.
+const (
+	KindSlowMotion = "slow-motion"
+	KindTimelapse  = "timelapse"
+)
+
+var (
+	slowMotionNameRe = regexp.MustCompile(`(?i)(slo[-_ ]?mo|slow[-_ ]?motion)`)
+	timelapseNameRe  = regexp.MustCompile(`(?i)(time[-_ ]?lapse|hyper[-_ ]?lapse|(^|[_.])TL([_.]|$))`)
+)
+
+// slowMotionFPS is the recorded frame rate from which a video counts as
+// slow motion; normal footage stays at or below 60.
+const slowMotionFPS = 100
+
+var (
+	ffprobeOnce      sync.Once
+	ffprobeAvailable bool
+	videoKinds       sync.Map // path -> kind
+)
+
+// HasFFprobe reports whether ffprobe is on PATH. Without it VideoKind only
+// goes by file names.
+func HasFFprobe() bool {
+	ffprobeOnce.Do(func() {
+		if _, err := exec.LookPath("ffprobe"); err == nil {
+			ffprobeAvailable = true
+		}
+	})
+	return ffprobeAvailable
+}
+
+// VideoKind reports whether a video is slow motion or a timelapse, from the
+// phone's name markers (VID_..._SLOMO, TIMELAPSE_...) or, when ffprobe is
+// installed, from the recorded frame rate. It returns "" for photos and
+// ordinary videos.
+func VideoKind(p *models.Photo) string {
+	if p == nil || !metadata.IsVideoPath(p.SrcPath) {
+		return ""
+	}
+	name := strings.TrimSuffix(filepath.Base(p.SrcPath), filepath.Ext(p.SrcPath))
+	switch {
+	case slowMotionNameRe.MatchString(name):
+		return KindSlowMotion
+	case timelapseNameRe.MatchString(name):
+		return KindTimelapse
+	}
+	if !HasFFprobe() {
+		return ""
+	}
+	if kind, ok := videoKinds.Load(p.SrcPath); ok {
+		return kind.(string)
+	}
+	kind := probeVideoKind(p.SrcPath)
+	videoKinds.Store(p.SrcPath, kind)
+	return kind
+}
+
+type ffprobeResult struct {
+	Streams []struct {
+		RFrameRate string            `json:"r_frame_rate"`
+		Tags       map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		Tags map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// probeVideoKind compares the capture frame rate Android records
+// (com.android.capture.fps) with the playback rate, and otherwise treats a
+// high recorded rate, as iPhones keep it, as slow motion.
+func probeVideoKind(path string) string {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=r_frame_rate:stream_tags:format_tags", "-of", "json", path).Output()
+	if err != nil {
+		return ""
+	}
+	var res ffprobeResult
+	if err := json.Unmarshal(out, &res); err != nil || len(res.Streams) == 0 {
+		return ""
+	}
+	playback := parseFrameRate(res.Streams[0].RFrameRate)
+	capture := parseFrameRate(res.Format.Tags["com.android.capture.fps"])
+	if capture == 0 {
+		capture = parseFrameRate(res.Streams[0].Tags["com.android.capture.fps"])
+	}
+	switch {
+	case capture > 0 && playback > 0 && capture > playback*1.5:
+		return KindSlowMotion
+	case capture > 0 && playback > 0 && capture < playback/1.5:
+		return KindTimelapse
+	case playback >= slowMotionFPS:
+		return KindSlowMotion
+	}
+	return ""
+}
+
+// parseFrameRate reads "240/1", "30000/1001" or "120.000000".
+func parseFrameRate(s string) float64 {
+	num, den, found := strings.Cut(strings.TrimSpace(s), "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
@@ -0,0 +1,63 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	reset  = "\x1b[0m"
+	red    = "\x1b[31m"
+	green  = "\x1b[32m"
+	yellow = "\x1b[33m"
+	bold   = "\x1b[1m"
+)
+
+var colorEnabled = stdoutIsTerminal()
+
+// stdoutIsTerminal reports whether stdout is an interactive terminal that
+// should receive ANSI colors. NO_COLOR and TERM=dumb disable colors.
+func stdoutIsTerminal() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// SetColor enables or disables colors. Colors are never enabled when stdout
+// is not a terminal.
+func SetColor(on bool) {
+	colorEnabled = on && stdoutIsTerminal()
+}
+
+// IsTerminal reports whether stdout is an interactive terminal.
+func IsTerminal() bool {
+	return stdoutIsTerminal()
+}
+
+func paint(code, s string) string {
+	if !colorEnabled {
+		return s
+	}
+	return code + s + reset
+}
+
+func Error(s string) string   { return paint(red, s) }
+func Warn(s string) string    { return paint(yellow, s) }
+func Success(s string) string { return paint(green, s) }
+func Heading(s string) string { return paint(bold, s) }
+
+// Errorln prints its operands like fmt.Println, colored as an error.
+func Errorln(a ...any) {
+	fmt.Println(Error(strings.TrimSuffix(fmt.Sprintln(a...), "\n")))
+}
+
+// Warnf prints a formatted warning.
+func Warnf(format string, a ...any) {
+	fmt.Print(Warn(fmt.Sprintf(format, a...)))
+}
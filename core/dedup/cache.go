@@ -2,14 +2,19 @@ package dedup
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type hashCacheEntry struct {
 	Size    int64  `json:"size"`
 	MtimeNs int64  `json:"mtime_ns"`
 	Hash    string `json:"hash"`
+	// Sample is SampleFile of the content, checked before the hash is
+	// reused for a same-named file under another root.
+	Sample string `json:"sample,omitempty"`
 }
 
 type hashCache struct {
@@ -50,3 +55,123 @@ func SaveHashCache(path string, c hashCache) error {
 	}
 	return os.WriteFile(path, data, 0o644)
 }
+
+// lookup finds the cache entry for path. Entries are stored under absolute
+// paths so one cache file can be shared by several Takeout roots; older
+// caches keyed by the path as given are still honoured.
+func (c hashCache) lookup(path string) (hashCacheEntry, bool) {
+	if abs, err := filepath.Abs(path); err == nil {
+		if entry, ok := c.Files[abs]; ok {
+			return entry, true
+		}
+	}
+	entry, ok := c.Files[path]
+	return entry, ok
+}
+
+func (c hashCache) store(path string, entry hashCacheEntry) {
+	if abs, err := filepath.Abs(path); err == nil {
+		delete(c.Files, path)
+		path = abs
+	}
+	c.Files[path] = entry
+}
+
+// identityIndex maps name+size+mtime to a cache entry so a file that
+// appears under a different root (e.g. a newer export of the same library)
+// can reuse the hash once its content sample matches. Entries without a
+// sample, and keys shared by different content, are left out.
+func (c hashCache) identityIndex() map[string]hashCacheEntry {
+	index := make(map[string]hashCacheEntry, len(c.Files))
+	ambiguous := make(map[string]bool)
+	for path, entry := range c.Files {
+		if entry.Hash == "" || entry.Sample == "" {
+			continue
+		}
+		key := identityKey(filepath.Base(path), entry.Size, entry.MtimeNs)
+		if prev, ok := index[key]; ok && prev.Hash != entry.Hash {
+			ambiguous[key] = true
+		}
+		index[key] = entry
+	}
+	for key := range ambiguous {
+		delete(index, key)
+	}
+	return index
+}
+
+func identityKey(base string, size, mtimeNs int64) string {
+	return fmt.Sprintf("%s:%d:%d", base, size, mtimeNs)
+}
+
+// RebaseHashCache moves the entries of the cache at path that lie under
+// oldRoot to the same place under newRoot, e.g. after the Takeout was moved
+// to another machine. It returns how many entries moved. Both roots must be
+// paths of this OS; archives from another OS use PortableHashCache.
+func RebaseHashCache(path, oldRoot, newRoot string) (int, error) {
+	c, err := LoadHashCache(path)
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	files := make(map[string]hashCacheEntry, len(c.Files))
+	for file, entry := range c.Files {
+		rel, err := filepath.Rel(oldRoot, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			files[file] = entry
+			continue
+		}
+		files[filepath.Join(newRoot, rel)] = entry
+		moved++
+	}
+	if moved == 0 {
+		return 0, nil
+	}
+	c.Files = files
+	return moved, SaveHashCache(path, c)
+}
+
+// portableHashCache is a hash cache as stored in a state archive. Entries
+// under the Takeout root are keyed by slash-separated paths relative to it,
+// so they can be joined onto the root on a machine with another OS; other
+// entries keep their paths and are only useful by name.
+type portableHashCache struct {
+	Relative map[string]hashCacheEntry `json:"relative"`
+	Other    map[string]hashCacheEntry `json:"other,omitempty"`
+}
+
+// PortableHashCache returns the cache at path in the archive form, with the
+// entries under root made relative to it.
+func PortableHashCache(path, root string) ([]byte, error) {
+	c, err := LoadHashCache(path)
+	if err != nil {
+		return nil, err
+	}
+	pc := portableHashCache{Relative: make(map[string]hashCacheEntry), Other: make(map[string]hashCacheEntry)}
+	for file, entry := range c.Files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil || !filepath.IsAbs(file) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			pc.Other[file] = entry
+			continue
+		}
+		pc.Relative[filepath.ToSlash(rel)] = entry
+	}
+	return json.MarshalIndent(pc, "", "  ")
+}
+
+// RestorePortableHashCache writes data from PortableHashCache to path as a
+// cache for root and returns how many entries were placed under root.
+func RestorePortableHashCache(path string, data []byte, root string) (int, error) {
+	var pc portableHashCache
+	if err := json.Unmarshal(data, &pc); err != nil {
+		return 0, err
+	}
+	c := hashCache{Files: make(map[string]hashCacheEntry, len(pc.Relative)+len(pc.Other))}
+	for file, entry := range pc.Other {
+		c.Files[file] = entry
+	}
+	for rel, entry := range pc.Relative {
+		c.Files[filepath.Join(root, filepath.FromSlash(rel))] = entry
+	}
+	return len(pc.Relative), SaveHashCache(path, c)
+}
@@ -49,24 +49,40 @@ func GroupIdentical(photos []*models.Photo) map[string][]*models.Photo {
 	return finalGroups
 }
 
+// chooseBest keeps the copy with the most accurate date, then the shortest
+// path, and the path itself as the last tie-break so the winner does not
+// depend on input order.
 func chooseBest(group []*models.Photo) *models.Photo {
 	sort.Slice(group, func(i, j int) bool {
-		if group[i].DateAccuracy < group[j].DateAccuracy {
-			return group[i].DateAccuracy < group[j].DateAccuracy
+		a, b := group[i], group[j]
+		if a.DateAccuracy != b.DateAccuracy {
+			return a.DateAccuracy < b.DateAccuracy
 		}
-		return len(group[i].SrcPath) < len(group[j].SrcPath)
+		if len(a.SrcPath) != len(b.SrcPath) {
+			return len(a.SrcPath) < len(b.SrcPath)
+		}
+		return a.SrcPath < b.SrcPath
 	})
 
 	return group[0]
 }
 
+// MergeIdentical keeps one photo per group of identical files, carrying
+// over every album and source copy. Callers then run AlignLivePairs so the
+// halves of a Live Photo use copies from the same folder.
 func MergeIdentical(photos []*models.Photo, progress func(done, total int)) []*models.Photo {
 	grouped := GroupIdentical(photos)
+	keys := make([]string, 0, len(grouped))
+	for key := range grouped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 	var result []*models.Photo
 	total := len(grouped)
 	processed := 0
 
-	for _, group := range grouped {
+	for _, key := range keys {
+		group := grouped[key]
 		if len(group) == 1 {
 			result = append(result, group[0])
 			processed++
@@ -79,11 +95,14 @@ func MergeIdentical(photos []*models.Photo, progress func(done, total int)) []*m
 		best := chooseBest(group)
 
 		best.Albums = make(map[string]bool)
+		var copies []models.SourceCopy
 		for _, p := range group {
 			for album := range p.Albums {
 				best.Albums[album] = true
 			}
+			copies = append(copies, p.Copies...)
 		}
+		best.Copies = copies
 
 		result = append(result, best)
 		processed++
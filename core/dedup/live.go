@@ -0,0 +1,124 @@
+package dedup
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gphotos/core/models"
+)
+
+var (
+	liveStillExts = map[string]bool{".heic": true, ".heif": true, ".jpg": true, ".jpeg": true}
+	liveVideoExts = map[string]bool{".mov": true, ".mp4": true}
+)
+
+// liveKey identifies the halves of a Live Photo: the still and the video
+// share a folder and a base name (IMG_1234.HEIC and IMG_1234.MOV). ok is
+// false for files that cannot be either half.
+func liveKey(path string) (key string, video bool, ok bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !liveStillExts[ext] && !liveVideoExts[ext] {
+		return "", false, false
+	}
+	return strings.ToLower(strings.TrimSuffix(path, filepath.Ext(path))), liveVideoExts[ext], true
+}
+
+// AlignLivePairs points the video half of every Live Photo at the copy that
+// sits next to the still's chosen copy, so the pair keeps one source folder
+// (and its JSON) instead of each half picking a different duplicate. It
+// returns the videos that were switched; their metadata still comes from
+// the copy used before and has to be read again.
+func AlignLivePairs(photos []*models.Photo) []*models.Photo {
+	stills := make(map[string]bool)
+	for _, p := range photos {
+		if key, video, ok := liveKey(p.SrcPath); ok && !video {
+			stills[key] = true
+		}
+	}
+	var switched []*models.Photo
+	for _, p := range photos {
+		key, video, ok := liveKey(p.SrcPath)
+		if !ok || !video || stills[key] || len(p.Copies) < 2 {
+			continue
+		}
+		for _, c := range p.Copies {
+			if ck, _, _ := liveKey(c.MediaPath); stills[ck] {
+				p.SrcPath = c.MediaPath
+				p.JsonPath = c.JsonPath
+				switched = append(switched, p)
+				break
+			}
+		}
+	}
+	return switched
+}
+
+// LivePairs maps the video half of every Live Photo in photos to its still.
+func LivePairs(photos []*models.Photo) map[*models.Photo]*models.Photo {
+	stills := make(map[string]*models.Photo)
+	for _, p := range photos {
+		if key, video, ok := liveKey(p.SrcPath); ok && !video {
+			stills[key] = p
+		}
+	}
+	pairs := make(map[*models.Photo]*models.Photo)
+	for _, p := range photos {
+		if key, video, ok := liveKey(p.SrcPath); ok && video && stills[key] != nil {
+			pairs[p] = stills[key]
+		}
+	}
+	return pairs
+}
+
+// KeepLivePairs makes the video half of every pair follow its still through
+// the exclude and route passes: a video whose still is gone is dropped, one
+// excluded on its own is put back after its still, and each video takes the
+// still's route and album. It returns the adjusted list and the number of
+// videos that were dropped, put back or moved.
+func KeepLivePairs(photos []*models.Photo, pairs map[*models.Photo]*models.Photo) ([]*models.Photo, int) {
+	present := make(map[*models.Photo]bool, len(photos))
+	for _, p := range photos {
+		present[p] = true
+	}
+	videoOf := make(map[*models.Photo]*models.Photo, len(pairs))
+	for video, still := range pairs {
+		videoOf[still] = video
+	}
+	changed := 0
+	out := make([]*models.Photo, 0, len(photos))
+	for _, p := range photos {
+		if still, ok := pairs[p]; ok && !present[still] {
+			changed++
+			continue
+		}
+		out = append(out, p)
+		video, ok := videoOf[p]
+		if !ok {
+			continue
+		}
+		if !present[video] {
+			out = append(out, video)
+			changed++
+		}
+		if video.Route != p.Route || video.FinalAlbum != p.FinalAlbum {
+			video.Route, video.FinalAlbum = p.Route, p.FinalAlbum
+			if present[video] {
+				changed++
+			}
+		}
+	}
+	return out, changed
+}
+
+// AlignLiveNames gives the video half of every pair the still's output name
+// with the video's own extension, after renames, so apps that pair Live
+// Photos by name still find both halves.
+func AlignLiveNames(pairs map[*models.Photo]*models.Photo) {
+	for video, still := range pairs {
+		if still.DstName == "" {
+			video.DstName = ""
+			continue
+		}
+		video.DstName = strings.TrimSuffix(still.DstName, filepath.Ext(still.DstName)) + filepath.Ext(video.SrcPath)
+	}
+}
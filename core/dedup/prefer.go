@@ -0,0 +1,71 @@
+package dedup
+
+import "gphotos/core/models"
+
+// Copy preferences for ApplyCopyPreference.
+const (
+	PreferFirst = "first" // keep the first copy scanned
+	PreferAlbum = "album" // prefer a copy inside an album folder
+	PreferYear  = "year"  // prefer the "Photos from YYYY" copy
+)
+
+// CopyChoice records which source copy a photo ended up using when it
+// existed both in an album folder and in a year folder.
+type CopyChoice struct {
+	Kept    models.SourceCopy
+	Dropped models.SourceCopy
+}
+
+// ValidCopyPreference reports whether pref is a known preference.
+func ValidCopyPreference(pref string) bool {
+	switch pref {
+	case PreferFirst, PreferAlbum, PreferYear:
+		return true
+	}
+	return false
+}
+
+// ApplyCopyPreference points every photo that has both album and
+// year-folder copies at the preferred side, switching its source path and
+// JSON sidecar, and returns one choice per such photo. Album memberships
+// are unaffected. Within a side the first copy with a sidecar wins.
+func ApplyCopyPreference(photos []*models.Photo, pref string) []CopyChoice {
+	if pref == PreferFirst {
+		return nil
+	}
+	var choices []CopyChoice
+	for _, p := range photos {
+		if p == nil || len(p.Copies) < 2 {
+			continue
+		}
+		album, year := pickCopy(p.Copies, true), pickCopy(p.Copies, false)
+		if album == nil || year == nil {
+			continue
+		}
+		kept, dropped := *album, *year
+		if pref == PreferYear {
+			kept, dropped = dropped, kept
+		}
+		p.SrcPath = kept.MediaPath
+		p.JsonPath = kept.JsonPath
+		choices = append(choices, CopyChoice{Kept: kept, Dropped: dropped})
+	}
+	return choices
+}
+
+func pickCopy(copies []models.SourceCopy, inAlbum bool) *models.SourceCopy {
+	var first *models.SourceCopy
+	for i := range copies {
+		c := &copies[i]
+		if (c.Album != "") != inAlbum {
+			continue
+		}
+		if c.JsonPath != "" {
+			return c
+		}
+		if first == nil {
+			first = c
+		}
+	}
+	return first
+}
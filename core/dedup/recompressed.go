@@ -0,0 +1,163 @@
+package dedup
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gphotos/core/metadata"
+	"gphotos/core/models"
+)
+
+// RecompressedDrop records a lower-quality copy that was dropped in favour of
+// a larger original with the same name and capture time.
+type RecompressedDrop struct {
+	Kept    *models.Photo
+	Dropped *models.Photo
+}
+
+var copyIndexRe = regexp.MustCompile(`\(\d+\)$`)
+
+// MergeRecompressed finds "Storage saver" copies that Google re-encoded
+// alongside the device original. Two photos are considered the same capture
+// when their base names match, their taken times are within window, and
+// both have known dimensions with the same aspect ratio. The copy with the
+// most pixels wins (the larger file on ties) and inherits the albums of the
+// dropped copies. The halves of a Live Photo are dropped together or not at
+// all.
+func MergeRecompressed(photos []*models.Photo, window time.Duration, progress func(done, total int)) ([]*models.Photo, []RecompressedDrop) {
+	groups := make(map[string][]*models.Photo)
+	var keys []string
+	for _, p := range photos {
+		if p == nil || p.Meta.TakenTime == "" {
+			continue
+		}
+		key := recompressKey(p.SrcPath)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], p)
+	}
+	sort.Strings(keys)
+
+	dropped := make(map[*models.Photo]bool)
+	var drops []RecompressedDrop
+	total := len(keys)
+	for i, key := range keys {
+		group := groups[key]
+		if len(group) > 1 {
+			sort.Slice(group, func(a, b int) bool {
+				pa, pb := pixels(group[a]), pixels(group[b])
+				if pa != pb {
+					return pa > pb
+				}
+				if group[a].Size == group[b].Size {
+					return group[a].SrcPath < group[b].SrcPath
+				}
+				return group[a].Size > group[b].Size
+			})
+			for _, best := range group {
+				if dropped[best] {
+					continue
+				}
+				for _, other := range group {
+					if other == best || dropped[other] || !sameCapture(best, other, window) {
+						continue
+					}
+					dropped[other] = true
+					drops = append(drops, RecompressedDrop{Kept: best, Dropped: other})
+				}
+			}
+		}
+		if progress != nil {
+			progress(i+1, total)
+		}
+	}
+
+	for video, still := range LivePairs(photos) {
+		if dropped[video] != dropped[still] {
+			delete(dropped, video)
+			delete(dropped, still)
+		}
+	}
+	if len(dropped) == 0 {
+		return photos, nil
+	}
+	kept := drops[:0]
+	for _, d := range drops {
+		if !dropped[d.Dropped] {
+			continue
+		}
+		for album, ok := range d.Dropped.Albums {
+			if ok {
+				d.Kept.Albums[album] = true
+			}
+		}
+		kept = append(kept, d)
+	}
+	drops = kept
+	out := make([]*models.Photo, 0, len(photos)-len(dropped))
+	for _, p := range photos {
+		if !dropped[p] {
+			out = append(out, p)
+		}
+	}
+	return out, drops
+}
+
+func recompressKey(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	return copyIndexRe.ReplaceAllString(base, "")
+}
+
+func sameCapture(a, b *models.Photo, window time.Duration) bool {
+	if metadata.IsVideoPath(a.SrcPath) != metadata.IsVideoPath(b.SrcPath) {
+		return false
+	}
+	ta, err := time.Parse(time.RFC3339, a.Meta.TakenTime)
+	if err != nil {
+		return false
+	}
+	tb, err := time.Parse(time.RFC3339, b.Meta.TakenTime)
+	if err != nil {
+		return false
+	}
+	diff := ta.Sub(tb)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > window {
+		return false
+	}
+	// Without dimensions, same-named shots taken in one burst could not
+	// be told apart from a re-encoded copy, so nothing is dropped.
+	wa, ha, okA := dimensions(a)
+	wb, hb, okB := dimensions(b)
+	if !okA || !okB || ha == 0 || hb == 0 {
+		return false
+	}
+	ratioA := float64(wa) / float64(ha)
+	ratioB := float64(wb) / float64(hb)
+	delta := ratioA - ratioB
+	if delta < 0 {
+		delta = -delta
+	}
+	return delta <= 0.01*ratioA
+}
+
+// dimensions prefers the size read during analysis and falls back to
+// decoding the image header.
+func dimensions(p *models.Photo) (int, int, bool) {
+	if p.Meta.Width > 0 && p.Meta.Height > 0 {
+		return p.Meta.Width, p.Meta.Height, true
+	}
+	return metadata.ImageDimensions(p.SrcPath)
+}
+
+func pixels(p *models.Photo) int {
+	w, h, _ := dimensions(p)
+	return w * h
+}
@@ -4,28 +4,117 @@ import (
 	"fmt"
 	"gphotos/core/models"
 	"gphotos/core/scanner"
+	"gphotos/core/spill"
 	"os"
+	"path/filepath"
 )
 
-func BuildRegistry(pairs []scanner.FilePair, cachePath string, verbose bool, progress func(done, total int)) map[string]*models.Photo {
+// spillDir is where BuildRegistry keeps hashed files on disk; empty keeps
+// them in memory.
+var spillDir string
+
+// SetSpillDir makes BuildRegistry write each hashed file to a spill log
+// under dir and build the registry only after the hash cache was saved and
+// released, so the two are never in memory together. Empty turns it off.
+func SetSpillDir(dir string) {
+	spillDir = dir
+}
+
+// hashedPair is one scanned file with its content key, as spilled to disk.
+type hashedPair struct {
+	Key       string
+	Hash      string
+	HashError bool
+	Size      int64
+	Pair      scanner.FilePair
+}
+
+// BuildRegistry hashes every scanned file and groups identical content under
+// one Photo. With trustCache, files that already have a cache entry are not
+// stat'ed at all; the cached size and hash are used as-is.
+func BuildRegistry(pairs []scanner.FilePair, cachePath string, trustCache bool, verbose bool, progress func(done, total int)) map[string]*models.Photo {
+	return buildRegistry(pairs, cachePath, trustCache, verbose, progress, spillDir)
+}
+
+func buildRegistry(pairs []scanner.FilePair, cachePath string, trustCache bool, verbose bool, progress func(done, total int), spillTo string) map[string]*models.Photo {
 	registry := make(map[string]*models.Photo)
+	add := func(h hashedPair) {
+		p := h.Pair
+		photo, exists := registry[h.Key]
+		if !exists {
+			photo = &models.Photo{
+				Hash:      h.Hash,
+				HashError: h.HashError,
+				SrcPath:   p.MediaPath,
+				JsonPath:  p.JsonPath,
+				Imported:  p.Meta,
+				Albums:    make(map[string]bool),
+			}
+			registry[h.Key] = photo
+		}
+
+		if p.Album != "" {
+			photo.Albums[p.Album] = true
+		}
+		for _, album := range p.MoreAlbums {
+			photo.Albums[album] = true
+		}
+		photo.Copies = append(photo.Copies, models.SourceCopy{
+			MediaPath: p.MediaPath,
+			JsonPath:  p.JsonPath,
+			Album:     p.Album,
+		})
+
+		photo.Size = h.Size
+	}
+	var log *spill.Log[hashedPair]
+	if spillTo != "" {
+		var err error
+		if log, err = spill.New[hashedPair](spillTo); err != nil {
+			fmt.Printf("Spill to disk failed, keeping the registry in memory: %v\n", err)
+		} else {
+			defer log.Remove()
+		}
+	}
+
 	cache, _ := LoadHashCache(cachePath)
+	identities := cache.identityIndex()
 	total := len(pairs)
 	processed := 0
 	for _, p := range pairs {
-		info, err := os.Stat(p.MediaPath)
-		if err != nil {
-			continue
-		}
-		size := info.Size()
-		mtime := info.ModTime().UnixNano()
-		var hash string
-		if entry, ok := cache.Files[p.MediaPath]; ok && entry.Size == size && entry.MtimeNs == mtime && entry.Hash != "" {
+		var size, mtime int64
+		var hash, sample string
+		entry, cached := cache.lookup(p.MediaPath)
+		if trustCache && cached && entry.Hash != "" {
+			size = entry.Size
+			mtime = entry.MtimeNs
 			hash = entry.Hash
+			sample = entry.Sample
+		} else {
+			info, err := os.Stat(p.MediaPath)
+			if err != nil {
+				continue
+			}
+			size = info.Size()
+			mtime = info.ModTime().UnixNano()
+			if cached && entry.Size == size && entry.MtimeNs == mtime && entry.Hash != "" {
+				hash = entry.Hash
+				sample = entry.Sample
+			} else if other, ok := identities[identityKey(filepath.Base(p.MediaPath), size, mtime)]; ok {
+				// Name, size and mtime can match for different files, so
+				// the hash is only reused when the content sample agrees.
+				sample, _ = SampleFile(p.MediaPath, size)
+				if sample == other.Sample {
+					hash = other.Hash
+				}
+			}
 		}
 		var hashErr error
 		if hash == "" {
 			hash, hashErr = HashFile(p.MediaPath)
+			if hashErr == nil && sample == "" {
+				sample, _ = SampleFile(p.MediaPath, size)
+			}
 		}
 		key := hash
 		hashError := false
@@ -35,33 +124,27 @@ func BuildRegistry(pairs []scanner.FilePair, cachePath string, verbose bool, pro
 			hashError = true
 			fmt.Printf("Hash failed, keeping file: %s (%v)\n", p.MediaPath, hashErr)
 		} else if hash != "" {
-			cache.Files[p.MediaPath] = hashCacheEntry{
+			cache.store(p.MediaPath, hashCacheEntry{
 				Size:    size,
 				MtimeNs: mtime,
 				Hash:    hash,
-			}
+				Sample:  sample,
+			})
 		}
 
-		photo, exists := registry[key]
-		if !exists {
-			photo = &models.Photo{
-				Hash:      hash,
-				HashError: hashError,
-				SrcPath:   p.MediaPath,
-				JsonPath:  p.JsonPath,
-				Albums:    make(map[string]bool),
-			}
-			registry[key] = photo
-		}
-
-		if p.Album != "" {
-			photo.Albums[p.Album] = true
+		h := hashedPair{Key: key, Hash: hash, HashError: hashError, Size: size, Pair: p}
+		if log == nil {
+			add(h)
+		} else if err := log.Append(h); err != nil {
+			// The hashes so far are kept in the cache, so starting over
+			// in memory does not hash them again.
+			fmt.Printf("Spill to disk failed, keeping the registry in memory: %v\n", err)
+			_ = SaveHashCache(cachePath, cache)
+			return buildRegistry(pairs, cachePath, trustCache, verbose, progress, "")
 		}
 
-		photo.Size = size
-
 		if verbose {
-			fmt.Printf("Hashed: %s\n", photo.SrcPath)
+			fmt.Printf("Hashed: %s\n", p.MediaPath)
 		}
 		processed++
 		if progress != nil {
@@ -70,5 +153,14 @@ func BuildRegistry(pairs []scanner.FilePair, cachePath string, verbose bool, pro
 	}
 
 	_ = SaveHashCache(cachePath, cache)
+	if log != nil {
+		// The cache is no longer referenced, so the collector can free it
+		// while the registry grows.
+		if err := log.Each(func(h hashedPair) error { add(h); return nil }); err != nil {
+			// Every hash is in the saved cache now, so this pass is quick.
+			fmt.Printf("Reading the spilled registry failed, building it in memory: %v\n", err)
+			return buildRegistry(pairs, cachePath, trustCache, verbose, progress, "")
+		}
+	}
 	return registry
 }
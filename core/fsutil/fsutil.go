@@ -0,0 +1,8 @@
+// Package fsutil wraps filesystem capabilities that differ between platforms:
+// free space, extended attributes, copy-on-write clones, and lock files.
+package fsutil
+
+import "errors"
+
+// ErrUnsupported is returned when the platform or filesystem lacks a feature.
+var ErrUnsupported = errors.New("not supported on this platform")
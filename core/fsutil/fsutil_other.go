@@ -0,0 +1,26 @@
+//go:build !unix
+
+package fsutil
+
+import "os"
+
+func FreeSpace(path string) (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+// isTransientErrno has no errno table to consult here; only timeouts (see
+// IsTransient) are retried.
+func isTransientErrno(err error) bool {
+	return false
+}
+
+// processAlive reports whether a process with this pid exists; on Windows
+// FindProcess fails for pids that are not running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package fsutil
+
+import (
+	"errors"
+	"syscall"
+)
+
+// FreeSpace returns the bytes available to unprivileged users on the
+// filesystem holding path.
+func FreeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+func isTransientErrno(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	switch errno {
+	case syscall.EIO, syscall.EAGAIN, syscall.EINTR, syscall.ETIMEDOUT,
+		syscall.ESTALE, syscall.ECONNRESET, syscall.ECONNABORTED,
+		syscall.EHOSTDOWN, syscall.EHOSTUNREACH, syscall.ENETDOWN, syscall.ENETUNREACH:
+		return true
+	}
+	return false
+}
+
+// processAlive reports whether a process with this pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,25 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ProbeLinks creates a scratch file in dir and tries to symlink and
+// hardlink to it, returning why each failed (nil when it works). Windows
+// refuses symlinks without Developer Mode or admin rights, and FAT and
+// exFAT volumes refuse both.
+func ProbeLinks(dir string) (symlinkErr, hardlinkErr error) {
+	probe, err := os.MkdirTemp(dir, ".gphotos-links-")
+	if err != nil {
+		return err, err
+	}
+	defer os.RemoveAll(probe)
+	src := filepath.Join(probe, "src")
+	if err := os.WriteFile(src, []byte("x"), 0o644); err != nil {
+		return err, err
+	}
+	symlinkErr = os.Symlink("src", filepath.Join(probe, "sym"))
+	hardlinkErr = os.Link(src, filepath.Join(probe, "hard"))
+	return symlinkErr, hardlinkErr
+}
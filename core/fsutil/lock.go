@@ -0,0 +1,92 @@
+package fsutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LockInfo identifies the process holding a lock file.
+type LockInfo struct {
+	PID     int    `json:"pid"`
+	Host    string `json:"host"`
+	Started string `json:"started"` // RFC3339
+	Command string `json:"command"`
+}
+
+// LockedError is returned by AcquireLock when another process holds the
+// lock.
+type LockedError struct {
+	Path   string
+	Holder LockInfo
+}
+
+func (e *LockedError) Error() string {
+	h := e.Holder
+	return fmt.Sprintf("%s is locked by pid %d on %s since %s (%s)", e.Path, h.PID, h.Host, h.Started, h.Command)
+}
+
+// Lock is a lock file created by AcquireLock.
+type Lock struct {
+	path string
+}
+
+// AcquireLock creates the lock file at path, recording this process as the
+// holder. A lock left behind by a process that no longer runs on this host
+// is taken over; any other existing lock fails with a *LockedError.
+func AcquireLock(path string) (*Lock, error) {
+	if err := MkdirAll(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	info := LockInfo{
+		PID:     os.Getpid(),
+		Host:    host,
+		Started: time.Now().Format(time.RFC3339),
+		Command: strings.Join(os.Args, " "),
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, err = f.Write(data)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &Lock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		var holder LockInfo
+		if raw, rerr := os.ReadFile(path); rerr == nil {
+			_ = json.Unmarshal(raw, &holder)
+		}
+		stale := holder.Host == host && holder.PID > 0 && !processAlive(holder.PID)
+		if !stale || attempt > 0 {
+			return nil, &LockedError{Path: path, Holder: holder}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// Release removes the lock file.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	return os.Remove(l.path)
+}
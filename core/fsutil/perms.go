@@ -0,0 +1,152 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// Permissions is the mode and ownership given to files and folders created
+// in the output. UID and GID are -1 to keep the creating user's.
+type Permissions struct {
+	DirMode  os.FileMode
+	FileMode os.FileMode
+	UID, GID int
+}
+
+var (
+	perms = Permissions{DirMode: 0o755, FileMode: 0o644, UID: -1, GID: -1}
+	// explicit is set once SetPermissions is called; until then the
+	// umask decides the final modes, as os.MkdirAll and os.WriteFile do.
+	explicit bool
+)
+
+// SetPermissions sets the modes and ownership used by MkdirAll, WriteFile,
+// Create and ApplyPermissions.
+func SetPermissions(p Permissions) {
+	perms = p
+	explicit = true
+}
+
+// LookupOwner resolves user and group names (or numeric ids) to ids; an
+// empty name resolves to -1.
+func LookupOwner(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		id := owner
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, err
+			}
+			id = u.Uid
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return -1, -1, fmt.Errorf("user %s has no numeric id", owner)
+		}
+		uid = n
+	}
+	if group != "" {
+		id := group
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, err
+			}
+			id = g.Gid
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return -1, -1, fmt.Errorf("group %s has no numeric id", group)
+		}
+		gid = n
+	}
+	return uid, gid, nil
+}
+
+// MkdirAll creates path and any missing parents with the configured
+// directory mode and ownership. Existing folders are left alone.
+func MkdirAll(path string) error {
+	if err := checkWritable(path); err != nil {
+		return err
+	}
+	// Find the topmost missing folder so only new ones are touched.
+	first := ""
+	for dir := filepath.Clean(path); ; {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		first = dir
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	if err := os.MkdirAll(path, perms.DirMode); err != nil {
+		return err
+	}
+	if first == "" || !explicit {
+		return nil
+	}
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if err := apply(dir, perms.DirMode); err != nil {
+			return err
+		}
+		if dir == first || filepath.Dir(dir) == dir {
+			return nil
+		}
+	}
+}
+
+// WriteFile writes data to path with the configured file mode and
+// ownership.
+func WriteFile(path string, data []byte) error {
+	if err := checkWritable(path); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, perms.FileMode); err != nil {
+		return err
+	}
+	return ApplyPermissions(path)
+}
+
+// Create creates or truncates path with the configured file mode and
+// ownership.
+func Create(path string) (*os.File, error) {
+	if err := checkWritable(path); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perms.FileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := ApplyPermissions(path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// ApplyPermissions gives an existing file the configured mode and
+// ownership, e.g. after a tool rewrote it. Without SetPermissions it does
+// nothing.
+func ApplyPermissions(path string) error {
+	if !explicit {
+		return nil
+	}
+	return apply(path, perms.FileMode)
+}
+
+func apply(path string, mode os.FileMode) error {
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	if perms.UID >= 0 || perms.GID >= 0 {
+		return os.Chown(path, perms.UID, perms.GID)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package fsutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	protectedMu sync.RWMutex
+	protected   []string
+)
+
+// ProtectTree makes MkdirAll, WriteFile, Create and AcquireLock refuse to
+// write anywhere inside root.
+func ProtectTree(root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	protectedMu.Lock()
+	protected = append(protected, abs)
+	protectedMu.Unlock()
+	return nil
+}
+
+// checkWritable fails for paths inside a tree passed to ProtectTree.
+func checkWritable(path string) error {
+	protectedMu.RLock()
+	defer protectedMu.RUnlock()
+	if len(protected) == 0 {
+		return nil
+	}
+	for _, root := range protected {
+		if IsWithin(root, path) {
+			return fmt.Errorf("refusing to write %s: %s is read-only", path, root)
+		}
+	}
+	return nil
+}
+
+// IsWithin reports whether path is root or lies below it.
+func IsWithin(root, path string) bool {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
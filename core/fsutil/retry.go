@@ -0,0 +1,48 @@
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// retryDelays is the backoff between attempts for transient errors.
+var retryDelays = []time.Duration{100 * time.Millisecond, 500 * time.Millisecond, 2 * time.Second}
+
+// Retry runs fn until it succeeds, fails with a non-transient error, or the
+// backoff schedule is exhausted, and returns the last error.
+func Retry(fn func() error) error {
+	err := fn()
+	for _, delay := range retryDelays {
+		if err == nil || !IsTransient(err) {
+			return err
+		}
+		time.Sleep(delay)
+		err = fn()
+	}
+	return err
+}
+
+// ReadFile is os.ReadFile with Retry.
+func ReadFile(path string) ([]byte, error) {
+	var data []byte
+	err := Retry(func() error {
+		var err error
+		data, err = os.ReadFile(path)
+		return err
+	})
+	return data, err
+}
+
+// IsTransient reports whether err looks like a network filesystem hiccup
+// (SMB/NFS timeouts, stale handles, I/O errors) that may succeed on retry.
+// Missing files and permission errors are never transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return false
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	return isTransientErrno(err)
+}
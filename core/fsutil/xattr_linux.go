@@ -0,0 +1,51 @@
+//go:build linux
+
+package fsutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// SetXattr stores value in the extended attribute name on path.
+func SetXattr(path, name, value string) error {
+	return syscall.Setxattr(path, name, []byte(value), 0)
+}
+
+// GetXattr reads the extended attribute name from path.
+func GetXattr(path, name string) (string, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := syscall.Getxattr(path, name, buf)
+		if err == syscall.ERANGE {
+			buf = make([]byte, len(buf)*2)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+}
+
+const ficlone = 0x40049409
+
+// Reflink creates dst as a copy-on-write clone of src (btrfs, XFS, ...).
+func Reflink(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	closeErr := out.Close()
+	if errno != 0 {
+		_ = os.Remove(dst)
+		return errno
+	}
+	return closeErr
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package fsutil
+
+func SetXattr(path, name, value string) error {
+	return ErrUnsupported
+}
+
+func GetXattr(path, name string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func Reflink(src, dst string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,130 @@
+package i18n
+
+var catalogs = map[string]map[string]string{
+	"de": {
+		"Enter path to Takeout root": "Pfad zum Takeout-Ordner eingeben",
+		"Enter output folder":        "Ausgabeordner eingeben",
+		"No media files found.":      "Keine Mediendateien gefunden.",
+		"Scan summary: %d media files, %d with album, %d with JSON\n": "Scan-Übersicht: %d Mediendateien, %d mit Album, %d mit JSON\n",
+		"Unique files (by hash): %d\n":                                "Eindeutige Dateien (nach Hash): %d\n",
+		"Duplicates merged: %d -> %d\n":                               "Duplikate zusammengeführt: %d -> %d\n",
+		"Distinct albums detected: %d\n":                              "Gefundene Alben: %d\n",
+		"Date review:":                                                "Datumsprüfung:",
+		"Overrides (filename older than JSON)":                        "Ersetzungen (Dateiname älter als JSON)",
+		"Filename-only dates":                                         "Nur Datum aus Dateiname",
+		"EXIF-only dates":                                             "Nur EXIF-Datum",
+		"Unknown dates":                                               "Unbekanntes Datum",
+		"Review is required before applying date changes.":            "Vor dem Übernehmen der Datumsänderungen ist eine Prüfung erforderlich.",
+		"Type APPLY to continue, or anything else to cancel.":         "APPLY eingeben zum Fortfahren, alles andere bricht ab.",
+		"Confirmation":                                                "Bestätigung",
+		"Unknown date files detected. You can add custom date regex patterns.\n": "Dateien ohne Datum gefunden. Sie können eigene Regex-Muster für Daten hinzufügen.\n",
+		"Patterns will be saved to %s\n":                                         "Muster werden gespeichert unter %s\n",
+		"Unknown file groups (by name pattern):":                                 "Dateigruppen ohne Datum (nach Namensmuster):",
+		"Enter a regex that matches only the date portion.":                      "Regex eingeben, die nur den Datumsteil erfasst.",
+		"If you include a capture group, group 1 will be parsed as the date.":    "Bei einer Erfassungsgruppe wird Gruppe 1 als Datum gelesen.",
+		"Date regex (blank to stop)":                                             "Datums-Regex (leer zum Beenden)",
+		"Time layout for regex match (example: 20060102_150405)":                 "Zeitformat für den Treffer (Beispiel: 20060102_150405)",
+		"Layout is required.":                                                    "Ein Zeitformat ist erforderlich.",
+		"Pattern matched %d files, parsed %d dates.\n":                           "Muster traf %d Dateien, %d Daten gelesen.\n",
+		"Preview of parsed dates:":                                               "Vorschau der gelesenen Daten:",
+		"Keep this pattern anyway":                                               "Muster trotzdem behalten",
+		"Accept? all / none / exclude 1,2,3":                                     "Übernehmen? all / none / exclude 1,2,3",
+		"Albums found:":                                                          "Gefundene Alben:",
+		"Enter album numbers or names in priority order.":                        "Albumnummern oder -namen in Prioritätsreihenfolge eingeben.",
+		"Selection: ":                            "Auswahl: ",
+		"Selected albums (priority order): %s\n": "Ausgewählte Alben (Priorität): %s\n",
+		"No albums selected. All photos will go to the main library.": "Keine Alben ausgewählt. Alle Fotos kommen in die Hauptbibliothek.",
+		"Album assignment summary:":                                   "Albumzuordnung:",
+		"Done.":                                                       "Fertig.",
+		"Dry run complete.":                                           "Probelauf abgeschlossen.",
+
+		"Timezone of the timestamp (blank for local, e.g. UTC, +05:30, Europe/Berlin)":   "Zeitzone des Zeitstempels (leer für lokal, z. B. UTC, +05:30, Europe/Berlin)",
+		"Proposed names for untitled albums:":                                            "Vorgeschlagene Namen für unbenannte Alben:",
+		"Use these album names?":                                                         "Diese Albumnamen verwenden?",
+		"Enter a name for each album: empty accepts the proposal, - keeps the old name.": "Namen für jedes Album eingeben: leer übernimmt den Vorschlag, - behält den alten Namen.",
+	},
+	"fr": {
+		"Enter path to Takeout root": "Chemin du dossier Takeout",
+		"Enter output folder":        "Dossier de sortie",
+		"No media files found.":      "Aucun fichier média trouvé.",
+		"Scan summary: %d media files, %d with album, %d with JSON\n": "Analyse : %d fichiers média, %d avec album, %d avec JSON\n",
+		"Unique files (by hash): %d\n":                                "Fichiers uniques (par empreinte) : %d\n",
+		"Duplicates merged: %d -> %d\n":                               "Doublons fusionnés : %d -> %d\n",
+		"Distinct albums detected: %d\n":                              "Albums détectés : %d\n",
+		"Date review:":                                                "Vérification des dates :",
+		"Overrides (filename older than JSON)":                        "Remplacements (nom de fichier plus ancien que le JSON)",
+		"Filename-only dates":                                         "Dates issues du nom de fichier uniquement",
+		"EXIF-only dates":                                             "Dates EXIF uniquement",
+		"Unknown dates":                                               "Dates inconnues",
+		"Review is required before applying date changes.":            "Une vérification est nécessaire avant d'appliquer les dates.",
+		"Type APPLY to continue, or anything else to cancel.":         "Tapez APPLY pour continuer, toute autre saisie annule.",
+		"Confirmation":                                                "Confirmation",
+		"Unknown date files detected. You can add custom date regex patterns.\n": "Fichiers sans date détectés. Vous pouvez ajouter des motifs regex personnalisés.\n",
+		"Patterns will be saved to %s\n":                                         "Les motifs seront enregistrés dans %s\n",
+		"Unknown file groups (by name pattern):":                                 "Groupes de fichiers sans date (par motif de nom) :",
+		"Enter a regex that matches only the date portion.":                      "Saisissez une regex qui ne capture que la date.",
+		"If you include a capture group, group 1 will be parsed as the date.":    "Si un groupe de capture est présent, le groupe 1 est lu comme date.",
+		"Date regex (blank to stop)":                                             "Regex de date (vide pour arrêter)",
+		"Time layout for regex match (example: 20060102_150405)":                 "Format horaire de la correspondance (exemple : 20060102_150405)",
+		"Layout is required.":                                                    "Le format est obligatoire.",
+		"Pattern matched %d files, parsed %d dates.\n":                           "Le motif correspond à %d fichiers, %d dates lues.\n",
+		"Preview of parsed dates:":                                               "Aperçu des dates lues :",
+		"Keep this pattern anyway":                                               "Conserver ce motif quand même",
+		"Accept? all / none / exclude 1,2,3":                                     "Accepter ? all / none / exclude 1,2,3",
+		"Albums found:":                                                          "Albums trouvés :",
+		"Enter album numbers or names in priority order.":                        "Saisissez les numéros ou noms d'albums par ordre de priorité.",
+		"Selection: ":                            "Sélection : ",
+		"Selected albums (priority order): %s\n": "Albums sélectionnés (priorité) : %s\n",
+		"No albums selected. All photos will go to the main library.": "Aucun album sélectionné. Toutes les photos iront dans la bibliothèque principale.",
+		"Album assignment summary:":                                   "Répartition par album :",
+		"Done.":                                                       "Terminé.",
+		"Dry run complete.":                                           "Simulation terminée.",
+
+		"Timezone of the timestamp (blank for local, e.g. UTC, +05:30, Europe/Berlin)":   "Fuseau horaire de l'horodatage (vide pour local, p. ex. UTC, +05:30, Europe/Berlin)",
+		"Proposed names for untitled albums:":                                            "Noms proposés pour les albums sans titre :",
+		"Use these album names?":                                                         "Utiliser ces noms d'album ?",
+		"Enter a name for each album: empty accepts the proposal, - keeps the old name.": "Saisissez un nom pour chaque album : vide accepte la proposition, - garde l'ancien nom.",
+	},
+	"es": {
+		"Enter path to Takeout root": "Ruta de la carpeta de Takeout",
+		"Enter output folder":        "Carpeta de salida",
+		"No media files found.":      "No se encontraron archivos multimedia.",
+		"Scan summary: %d media files, %d with album, %d with JSON\n": "Resumen del escaneo: %d archivos multimedia, %d con álbum, %d con JSON\n",
+		"Unique files (by hash): %d\n":                                "Archivos únicos (por hash): %d\n",
+		"Duplicates merged: %d -> %d\n":                               "Duplicados fusionados: %d -> %d\n",
+		"Distinct albums detected: %d\n":                              "Álbumes detectados: %d\n",
+		"Date review:":                                                "Revisión de fechas:",
+		"Overrides (filename older than JSON)":                        "Sustituciones (nombre de archivo más antiguo que el JSON)",
+		"Filename-only dates":                                         "Fechas solo del nombre de archivo",
+		"EXIF-only dates":                                             "Fechas solo de EXIF",
+		"Unknown dates":                                               "Fechas desconocidas",
+		"Review is required before applying date changes.":            "Es necesario revisar antes de aplicar los cambios de fecha.",
+		"Type APPLY to continue, or anything else to cancel.":         "Escriba APPLY para continuar o cualquier otra cosa para cancelar.",
+		"Confirmation":                                                "Confirmación",
+		"Unknown date files detected. You can add custom date regex patterns.\n": "Se detectaron archivos sin fecha. Puede añadir patrones regex personalizados.\n",
+		"Patterns will be saved to %s\n":                                         "Los patrones se guardarán en %s\n",
+		"Unknown file groups (by name pattern):":                                 "Grupos de archivos sin fecha (por patrón de nombre):",
+		"Enter a regex that matches only the date portion.":                      "Introduzca una regex que capture solo la fecha.",
+		"If you include a capture group, group 1 will be parsed as the date.":    "Si incluye un grupo de captura, el grupo 1 se interpretará como fecha.",
+		"Date regex (blank to stop)":                                             "Regex de fecha (vacío para terminar)",
+		"Time layout for regex match (example: 20060102_150405)":                 "Formato de hora de la coincidencia (ejemplo: 20060102_150405)",
+		"Layout is required.":                                                    "El formato es obligatorio.",
+		"Pattern matched %d files, parsed %d dates.\n":                           "El patrón coincidió con %d archivos, %d fechas leídas.\n",
+		"Preview of parsed dates:":                                               "Vista previa de las fechas leídas:",
+		"Keep this pattern anyway":                                               "Conservar el patrón de todos modos",
+		"Accept? all / none / exclude 1,2,3":                                     "¿Aceptar? all / none / exclude 1,2,3",
+		"Albums found:":                                                          "Álbumes encontrados:",
+		"Enter album numbers or names in priority order.":                        "Introduzca números o nombres de álbum por orden de prioridad.",
+		"Selection: ":                            "Selección: ",
+		"Selected albums (priority order): %s\n": "Álbumes seleccionados (prioridad): %s\n",
+		"No albums selected. All photos will go to the main library.": "No se seleccionaron álbumes. Todas las fotos irán a la biblioteca principal.",
+		"Album assignment summary:":                                   "Resumen de asignación de álbumes:",
+		"Done.":                                                       "Hecho.",
+		"Dry run complete.":                                           "Simulación completada.",
+
+		"Timezone of the timestamp (blank for local, e.g. UTC, +05:30, Europe/Berlin)":   "Zona horaria de la marca de tiempo (vacío para local, p. ej. UTC, +05:30, Europe/Berlin)",
+		"Proposed names for untitled albums:":                                            "Nombres propuestos para álbumes sin título:",
+		"Use these album names?":                                                         "¿Usar estos nombres de álbum?",
+		"Enter a name for each album: empty accepts the proposal, - keeps the old name.": "Introduzca un nombre para cada álbum: vacío acepta la propuesta, - mantiene el nombre anterior.",
+	},
+}
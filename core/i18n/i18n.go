@@ -0,0 +1,69 @@
+// Package i18n translates interactive prompts and report lines. Messages are
+// keyed by their English text, so untranslated strings fall back to English.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var current map[string]string
+
+// Supported lists the language codes that have a catalog.
+func Supported() []string {
+	return []string{"en", "de", "fr", "es"}
+}
+
+// SetLanguage selects the catalog for lang ("de", "fr_FR.UTF-8", ...).
+// An empty lang falls back to LC_ALL, LC_MESSAGES, and LANG.
+func SetLanguage(lang string) error {
+	explicit := strings.TrimSpace(lang) != ""
+	if !explicit {
+		for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if v := os.Getenv(env); v != "" {
+				lang = v
+				break
+			}
+		}
+	}
+	code := normalize(lang)
+	if code == "" || code == "en" || code == "c" || code == "posix" {
+		current = nil
+		return nil
+	}
+	cat, ok := catalogs[code]
+	if !ok {
+		current = nil
+		if !explicit {
+			// Unknown system locale: stay in English rather than failing.
+			return nil
+		}
+		return fmt.Errorf("unsupported language: %s (supported: %s)", lang, strings.Join(Supported(), ", "))
+	}
+	current = cat
+	return nil
+}
+
+func normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "_.-@"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// T returns the translation of msg, or msg itself when none exists.
+func T(msg string) string {
+	if current != nil {
+		if tr, ok := current[msg]; ok {
+			return tr
+		}
+	}
+	return msg
+}
+
+// Tf translates format and then formats it with args.
+func Tf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
@@ -0,0 +1,128 @@
+package metadata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gphotos/core/fsutil"
+)
+
+// ArgFileWriter records planned metadata updates as an exiftool -@ argfile
+// instead of running exiftool. Each file becomes one command ended by
+// -execute, so the result runs with:
+//
+//	exiftool -config <argfile>.config -@ <argfile>
+type ArgFileWriter struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	base  string
+	count int
+}
+
+// CreateArgFile creates the argfile at path plus the XMP-gphotos config
+// exiftool needs next to it. Paths inside base are written relative to it,
+// so the commands can be run from that folder on another machine; with an
+// empty base they are written as given.
+func CreateArgFile(path, base string) (*ArgFileWriter, error) {
+	if err := fsutil.WriteFile(path+".config", []byte(gphotosConfig)); err != nil {
+		return nil, err
+	}
+	f, err := fsutil.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &ArgFileWriter{f: f, w: bufio.NewWriter(f), base: base}
+	fmt.Fprintln(a.w, "# exiftool argfile written by gphotos")
+	if base != "" {
+		fmt.Fprintf(a.w, "# Run from %s:\n", base)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	fmt.Fprintf(a.w, "#   exiftool -config %s.config -@ %s\n", path, path)
+	return a, nil
+}
+
+// Write appends one command per item that has metadata to write.
+func (a *ArgFileWriter) Write(items []WriteItem) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, item := range items {
+		if item.Path == "" || !HasWritableMeta(item.Meta) {
+			continue
+		}
+		tags, files, ok := planItem(item)
+		if !ok {
+			continue
+		}
+		for i := range tags {
+			tags[i].value = escapeArgValue(tags[i].value)
+		}
+		a.relocate(files, item.Path)
+		// -E decodes the HTML entities escapeArgValue writes.
+		args := append([]string{"-overwrite_original", "-m", "-E"}, tagArgs(tags)...)
+		args = append(args, files...)
+		for _, arg := range append(args, "-execute") {
+			if _, err := fmt.Fprintln(a.w, arg); err != nil {
+				return err
+			}
+		}
+		a.count++
+	}
+	return nil
+}
+
+// relocate rewrites the file and sidecar arguments relative to the base.
+func (a *ArgFileWriter) relocate(files []string, path string) {
+	if a.base == "" {
+		return
+	}
+	xmp := SidecarPath(path)
+	for i, arg := range files {
+		if arg != path && arg != xmp {
+			continue
+		}
+		if rel, err := filepath.Rel(a.base, arg); err == nil && fsutil.IsWithin(a.base, arg) {
+			rel = filepath.ToSlash(rel)
+			// A name starting with "-" would be read as an option.
+			if strings.HasPrefix(rel, "-") {
+				rel = "./" + rel
+			}
+			files[i] = rel
+		}
+	}
+}
+
+// escapeArgValue encodes what an argfile cannot hold on one line as HTML
+// entities: line breaks, and the leading and trailing spaces exiftool
+// trims from each line. "&" is encoded too so existing entities survive.
+func escapeArgValue(v string) string {
+	v = strings.NewReplacer("&", "&amp;", "\n", "&#xa;", "\r", "&#xd;", "\t", "&#x9;").Replace(v)
+	trimmed := strings.TrimLeft(v, " ")
+	v = strings.Repeat("&#x20;", len(v)-len(trimmed)) + trimmed
+	trimmed = strings.TrimRight(v, " ")
+	return trimmed + strings.Repeat("&#x20;", len(v)-len(trimmed))
+}
+
+// Count returns the number of commands written so far.
+func (a *ArgFileWriter) Count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.count
+}
+
+// Close flushes and closes the argfile.
+func (a *ArgFileWriter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.w.Flush(); err != nil {
+		a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
@@ -0,0 +1,128 @@
+package metadata
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gphotos/core/models"
+)
+
+func TestEscapeArgValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"a\nb", "a&#xa;b"},
+		{"a\r\nb", "a&#xd;&#xa;b"},
+		{"a\tb", "a&#x9;b"},
+		{"a=b", "a=b"},
+		{"-dash", "-dash"},
+		{"  both  ", "&#x20;&#x20;both&#x20;&#x20;"},
+		{" ", "&#x20;"},
+		{"Fish &amp; Chips", "Fish &amp;amp; Chips"},
+		{"Fish & Chips", "Fish &amp; Chips"},
+		{"base64:SGVsbG8=", "base64:SGVsbG8="},
+		{"Zoë 東京", "Zoë 東京"},
+		{"", ""},
+	}
+	for _, tc := range tests {
+		if got := escapeArgValue(tc.in); got != tc.want {
+			t.Errorf("escapeArgValue(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+// TestEscapeArgValueRoundTrip checks that every value fits on one argfile
+// line that exiftool -E decodes back to the original.
+func TestEscapeArgValueRoundTrip(t *testing.T) {
+	for _, tc := range tricky {
+		t.Run(tc.name, func(t *testing.T) {
+			got := escapeArgValue(tc.value)
+			if strings.ContainsAny(got, "\r\n") {
+				t.Errorf("escapeArgValue(%q) = %q spans lines", tc.value, got)
+			}
+			if strings.TrimSpace(got) != got {
+				t.Errorf("escapeArgValue(%q) = %q has spaces exiftool trims", tc.value, got)
+			}
+			if back := html.UnescapeString(got); back != tc.value {
+				t.Errorf("escapeArgValue(%q) = %q, decodes to %q", tc.value, got, back)
+			}
+		})
+	}
+}
+
+// TestArgFileRoundTrip writes one command per tricky value and reads the
+// description back from the argfile the way exiftool does.
+func TestArgFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "update.args")
+	a, err := CreateArgFile(path, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range tricky {
+		if tc.value == "" {
+			continue
+		}
+		err := a.Write([]WriteItem{{
+			Path: filepath.Join(dir, "IMG_0001.jpg"),
+			Meta: models.MetaData{Description: tc.value},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, line := range strings.Split(string(data), "\n") {
+		// exiftool ignores comments and trims each line.
+		line = strings.TrimSpace(line)
+		if tag, value, ok := strings.Cut(line, "="); ok && tag == "-XMP:Description" {
+			got = append(got, html.UnescapeString(value))
+		}
+	}
+	var want []string
+	for _, tc := range tricky {
+		if tc.value != "" {
+			want = append(want, tc.value)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("argfile holds %d descriptions, want %d:\n%s", len(got), len(want), data)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("description %d reads back as %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestArgFileRelocate(t *testing.T) {
+	base := filepath.Join(string(filepath.Separator), "out")
+	tests := []struct {
+		path, want string
+	}{
+		{filepath.Join(base, "Library", "IMG_0001.jpg"), "Library/IMG_0001.jpg"},
+		{filepath.Join(base, "-dash.jpg"), "./-dash.jpg"},
+		{filepath.Join(base, "Albums", "Zoë", "東京 (1).jpg"), "Albums/Zoë/東京 (1).jpg"},
+		{filepath.Join(string(filepath.Separator), "elsewhere", "IMG.jpg"), filepath.Join(string(filepath.Separator), "elsewhere", "IMG.jpg")},
+	}
+	a := &ArgFileWriter{base: base}
+	for _, tc := range tests {
+		files := []string{tc.path}
+		a.relocate(files, tc.path)
+		if files[0] != tc.want {
+			t.Errorf("relocate(%q) = %q, want %q", tc.path, files[0], tc.want)
+		}
+	}
+}
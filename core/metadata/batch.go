@@ -0,0 +1,351 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWriteTimeout is how long a batch may wait for exiftool before the
+// process is considered hung and restarted.
+const DefaultWriteTimeout = 2 * time.Minute
+
+const (
+	doneMarker   = "{gphotos-done "
+	readyPrefix  = "{ready"
+	retryPerItem = 2
+)
+
+type BatchWriter struct {
+	// Timeout bounds the wait for each file's {readyN} acknowledgement.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	ready chan struct{}
+	acked int64
+	dead  chan struct{}
+	seq   int
+
+	paths    map[int]string
+	resultMu sync.Mutex
+	failures []WriteFailure
+	readers  sync.WaitGroup
+}
+
+// WriteFailure holds the exiftool errors and warnings reported for one file.
+type WriteFailure struct {
+	Path     string
+	Messages []string
+	// Fatal is set when exiftool reported an error, meaning the file was not
+	// updated; warnings alone leave Fatal false.
+	Fatal bool
+}
+
+// StartBatchWriter launches a persistent exiftool process for fast batched writes.
+func StartBatchWriter() (*BatchWriter, error) {
+	if !hasExiftool() {
+		return nil, fmt.Errorf("exiftool not available")
+	}
+	w := &BatchWriter{Timeout: DefaultWriteTimeout, paths: make(map[int]string)}
+	if err := w.start(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *BatchWriter) start() error {
+	// A single -q keeps warnings on stderr so they can be attributed to files.
+	args := append(exiftoolConfigArgs(), "-stay_open", "True", "-@", "-", "-common_args", "-overwrite_original", "-q", "-m")
+	cmd := exec.Command("exiftool", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	w.cmd = cmd
+	w.stdin = stdin
+	w.ready = make(chan struct{}, 1)
+	w.dead = make(chan struct{})
+	w.readers.Add(2)
+	go w.watchReady(stdout, w.ready, w.dead)
+	go w.collectDiagnostics(stderr)
+	return nil
+}
+
+// watchReady forwards the {readyN} acknowledgements exiftool prints after
+// each -executeN, and closes dead when the process goes away.
+func (w *BatchWriter) watchReady(r io.Reader, ready chan<- struct{}, dead chan<- struct{}) {
+	defer w.readers.Done()
+	defer close(dead)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, readyPrefix) {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, readyPrefix), "}"))
+		if err != nil {
+			continue
+		}
+		// Only the highest acknowledgement matters, so never block exiftool's
+		// stdout on a slow reader.
+		atomic.StoreInt64(&w.acked, int64(id))
+		select {
+		case ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// collectDiagnostics reads exiftool's stderr and attributes the messages
+// printed before each "{gphotos-done N}" marker to the Nth file.
+func (w *BatchWriter) collectDiagnostics(r io.Reader) {
+	defer w.readers.Done()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var pending []string
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, doneMarker) {
+			pending = append(pending, line)
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, doneMarker), "}"))
+		if err != nil || len(pending) == 0 {
+			pending = nil
+			continue
+		}
+		fatal := false
+		for _, msg := range pending {
+			if strings.HasPrefix(msg, "Error") {
+				fatal = true
+			}
+		}
+		w.resultMu.Lock()
+		w.failures = append(w.failures, WriteFailure{Path: w.paths[id], Messages: pending, Fatal: fatal})
+		w.resultMu.Unlock()
+		pending = nil
+	}
+}
+
+// Failures returns the per-file problems reported so far. Call it after
+// Close to get the complete list.
+func (w *BatchWriter) Failures() []WriteFailure {
+	if w == nil {
+		return nil
+	}
+	w.resultMu.Lock()
+	defer w.resultMu.Unlock()
+	return append([]WriteFailure(nil), w.failures...)
+}
+
+type pendingWrite struct {
+	seq     int
+	path    string
+	args    []string
+	retries int
+}
+
+// Write sends a batch of metadata updates to the persistent exiftool process
+// and waits until exiftool acknowledges each file. The tag values go
+// through a temporary -json+= import file rather than the argument
+// protocol, so descriptions and names may hold any text. If the process
+// dies or stops responding within Timeout, it is restarted and the
+// unacknowledged files are sent again.
+func (w *BatchWriter) Write(items []WriteItem) error {
+	if w == nil || w.stdin == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var queue []*pendingWrite
+	var entries []map[string]any
+	for _, item := range items {
+		if item.Path == "" || !HasWritableMeta(item.Meta) {
+			continue
+		}
+		tags, files, ok := planItem(item)
+		if !ok {
+			continue
+		}
+		entries = append(entries, jsonEntry(files[len(files)-1], tags))
+		queue = append(queue, &pendingWrite{path: item.Path, args: files})
+	}
+	if len(queue) == 0 {
+		return nil
+	}
+	importPath, err := writeImportFile(entries)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(importPath)
+	for _, pw := range queue {
+		pw.args = append([]string{"-json+=" + importPath}, pw.args...)
+	}
+
+	for len(queue) > 0 {
+		if err := w.send(queue); err != nil {
+			if rerr := w.restart(err); rerr != nil {
+				return rerr
+			}
+			queue = w.requeue(queue, "exiftool pipe failed: "+err.Error())
+			continue
+		}
+		acked, err := w.awaitAcks(queue)
+		queue = queue[acked:]
+		if err == nil {
+			continue
+		}
+		if rerr := w.restart(err); rerr != nil {
+			return rerr
+		}
+		queue = w.requeue(queue, err.Error())
+	}
+	return nil
+}
+
+// writeImportFile stores entries in a temporary exiftool JSON import file
+// and returns its path.
+func writeImportFile(entries []map[string]any) (string, error) {
+	f, err := os.CreateTemp("", "gphotos-meta-*.json")
+	if err != nil {
+		return "", err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(entries); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (w *BatchWriter) send(queue []*pendingWrite) error {
+	for _, pw := range queue {
+		w.seq++
+		pw.seq = w.seq
+		w.resultMu.Lock()
+		w.paths[pw.seq] = pw.path
+		w.resultMu.Unlock()
+		args := append(append([]string(nil), pw.args...),
+			"-echo4", fmt.Sprintf("%s%d}", doneMarker, pw.seq),
+			fmt.Sprintf("-execute%d", pw.seq))
+		for _, a := range args {
+			if _, err := fmt.Fprintln(w.stdin, a); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// awaitAcks waits for the {readyN} of every queued write in order and
+// returns how many were acknowledged.
+func (w *BatchWriter) awaitAcks(queue []*pendingWrite) (int, error) {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWriteTimeout
+	}
+	acked := 0
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for acked < len(queue) {
+		select {
+		case <-w.ready:
+			id := int(atomic.LoadInt64(&w.acked))
+			for acked < len(queue) && queue[acked].seq <= id {
+				acked++
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(timeout)
+		case <-w.dead:
+			return acked, fmt.Errorf("exiftool exited unexpectedly")
+		case <-timer.C:
+			return acked, fmt.Errorf("exiftool did not respond within %s", timeout)
+		}
+	}
+	return acked, nil
+}
+
+// restart kills the current exiftool process and starts a fresh one. Every
+// restart counts against the retries of the write in flight, so a file that
+// hangs exiftool is dropped after retryPerItem attempts instead of using up
+// a budget shared by the whole run.
+func (w *BatchWriter) restart(cause error) error {
+	if w.cmd != nil && w.cmd.Process != nil {
+		_ = w.cmd.Process.Kill()
+	}
+	_ = w.stdin.Close()
+	_ = w.cmd.Wait()
+	if err := w.start(); err != nil {
+		return fmt.Errorf("restarting exiftool after %v: %w", cause, err)
+	}
+	return nil
+}
+
+// requeue returns the writes that should be retried after a restart. The
+// write that was in flight when exiftool hung is the likely culprit; after
+// retryPerItem attempts it is recorded as a failure and dropped.
+func (w *BatchWriter) requeue(queue []*pendingWrite, reason string) []*pendingWrite {
+	if len(queue) == 0 {
+		return nil
+	}
+	head := queue[0]
+	head.retries++
+	if head.retries < retryPerItem {
+		return queue
+	}
+	w.resultMu.Lock()
+	w.failures = append(w.failures, WriteFailure{
+		Path:     head.path,
+		Messages: []string{"Error: " + reason},
+		Fatal:    true,
+	})
+	w.resultMu.Unlock()
+	return queue[1:]
+}
+
+// Close shuts down the persistent exiftool process.
+func (w *BatchWriter) Close() error {
+	if w == nil || w.stdin == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, _ = fmt.Fprintln(w.stdin, "-stay_open")
+	_, _ = fmt.Fprintln(w.stdin, "False")
+	_ = w.stdin.Close()
+	w.readers.Wait()
+	return w.cmd.Wait()
+}
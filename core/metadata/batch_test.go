@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gphotos/core/models"
+)
+
+// fakeExiftool speaks just enough of the -stay_open protocol for
+// BatchWriter: it logs the file of every -executeN and acknowledges it,
+// except for files whose name contains "hang", where it stops responding.
+const fakeExiftool = `#!/bin/sh
+file=
+while IFS= read -r line; do
+	case "$line" in
+	False)
+		exit 0 ;;
+	-execute*)
+		case "$file" in
+		*hang*) exec sleep 60 ;;
+		esac
+		echo "$file" >> "$FAKE_EXIFTOOL_LOG"
+		echo "{ready${line#-execute}}"
+		file= ;;
+	-*|{*) ;;
+	*)
+		file=$line ;;
+	esac
+done
+`
+
+func TestBatchWriterSurvivesRepeatedHangs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake exiftool is a shell script")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "exiftool"), []byte(fakeExiftool), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "written.log")
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_EXIFTOOL_LOG", logPath)
+
+	photo := func(name string) WriteItem {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte{0xFF, 0xD8, 0xFF, 0xE0}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return WriteItem{Path: path, Meta: models.MetaData{Description: name}}
+	}
+
+	w := &BatchWriter{Timeout: 200 * time.Millisecond, paths: make(map[int]string)}
+	if err := w.start(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"hang1.jpg", "hang2.jpg", "later.jpg"} {
+		if err := w.Write([]WriteItem{photo(name)}); err != nil {
+			t.Fatalf("Write(%s): %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != filepath.Join(dir, "later.jpg") {
+		t.Errorf("written files = %q, want only later.jpg", got)
+	}
+	failed := map[string]bool{}
+	for _, f := range w.Failures() {
+		failed[filepath.Base(f.Path)] = f.Fatal
+	}
+	if !failed["hang1.jpg"] || !failed["hang2.jpg"] || len(failed) != 2 {
+		t.Errorf("failures = %v, want hang1.jpg and hang2.jpg", failed)
+	}
+}
@@ -5,12 +5,22 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"time"
 )
 
 type CustomPattern struct {
+	// Name, Examples and Priority come from shared pattern packs.
+	Name   string `json:"name,omitempty"`
 	Regex  string `json:"regex"`
 	Layout string `json:"layout"`
+	// Timezone is the zone the matched timestamp is in (see ParseTimezone);
+	// empty means local time.
+	Timezone string   `json:"timezone,omitempty"`
+	Examples []string `json:"examples,omitempty"`
+	// Priority orders patterns: higher values are tried first, ties keep
+	// file order.
+	Priority int `json:"priority,omitempty"`
 }
 
 func LoadCustomPatterns(path string) ([]CustomPattern, error) {
@@ -96,7 +106,7 @@ func guessWithPatterns(path string, patterns []datePattern) (time.Time, bool) {
 		if len(sub) > 1 {
 			target = sub[1]
 		}
-		if t, ok := pat.parse(target); ok {
+		if t, ok := pat.parse(target, pat.location()); ok {
 			return t, true
 		}
 	}
@@ -107,8 +117,13 @@ func buildCustomPatterns(custom []CustomPattern) []datePattern {
 	if len(custom) == 0 {
 		return nil
 	}
+	ordered := make([]CustomPattern, len(custom))
+	copy(ordered, custom)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority > ordered[j].Priority
+	})
 	out := make([]datePattern, 0, len(custom))
-	for _, c := range custom {
+	for _, c := range ordered {
 		if c.Regex == "" || c.Layout == "" {
 			continue
 		}
@@ -116,9 +131,14 @@ func buildCustomPatterns(custom []CustomPattern) []datePattern {
 		if err != nil {
 			continue
 		}
+		loc, err := ParseTimezone(c.Timezone)
+		if err != nil {
+			continue
+		}
 		out = append(out, datePattern{
 			re:    re,
 			parse: parseLayout(c.Layout),
+			loc:   loc,
 		})
 	}
 	return out
@@ -3,55 +3,142 @@ package metadata
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"gphotos/core/fsutil"
 )
 
 const (
 	DateAccuracyJSON     = 1
 	DateAccuracyFilename = 2
 	DateAccuracyExif     = 3
-	DateAccuracyNone     = 99
+	// DateAccuracyInterpolated dates are inferred from neighboring files.
+	DateAccuracyInterpolated = 4
+	// DateAccuracyManual dates were entered by the user during review.
+	DateAccuracyManual = 5
+	DateAccuracyNone   = 99
 )
 
 type datePattern struct {
+	// name identifies built-in patterns for SetPatternTimezones.
+	name  string
 	re    *regexp.Regexp
-	parse func(string) (time.Time, bool)
+	parse func(s string, loc *time.Location) (time.Time, bool)
+	// loc is the zone the embedded timestamp is in; nil means the zone set
+	// for name, or time.Local.
+	loc *time.Location
 }
 
 var datePatterns = []datePattern{
+	// Prefixed names come before the generic patterns that would also match
+	// them, so their timezone setting applies.
+	// Pixel: PXL_20210102_123456.jpg
+	{name: "pixel", re: regexp.MustCompile(`(?i)PXL_\d{8}_\d{6}`), parse: parseLayout("PXL_20060102_150405")},
+	// Pixel with millis: PXL_20210102_123456789.jpg (take first 6 after date)
+	{name: "pixel", re: regexp.MustCompile(`(?i)PXL_\d{8}_\d{9}`), parse: parsePixelMillis()},
+	// Android: IMG_20210102_123456.jpg / VID_20210102_123456.mp4
+	{name: "android", re: regexp.MustCompile(`(?i)(IMG|VID)_\d{8}_\d{6}`), parse: parseLayout("IMG_20060102_150405")},
 	// Screenshot_20190919-053857.jpg
-	{regexp.MustCompile(`(?i)(20|19|18)\d{2}(0[1-9]|1[0-2])[0-3]\d-\d{6}`), parseLayout("20060102-150405")},
+	{name: "dash", re: regexp.MustCompile(`(?i)(20|19|18)\d{2}(0[1-9]|1[0-2])[0-3]\d-\d{6}`), parse: parseLayout("20060102-150405")},
 	// IMG_20190509_154733.jpg
-	{regexp.MustCompile(`(?i)(20|19|18)\d{2}(0[1-9]|1[0-2])[0-3]\d_\d{6}`), parseLayout("20060102_150405")},
+	{name: "underscore", re: regexp.MustCompile(`(?i)(20|19|18)\d{2}(0[1-9]|1[0-2])[0-3]\d_\d{6}`), parse: parseLayout("20060102_150405")},
 	// Screenshot_2019-04-16-11-19-37.jpg
-	{regexp.MustCompile(`(?i)(20|19|18)\d{2}-(0[1-9]|1[0-2])-[0-3]\d-\d{2}-\d{2}-\d{2}`), parseLayout("2006-01-02-15-04-05")},
+	{name: "dashed", re: regexp.MustCompile(`(?i)(20|19|18)\d{2}-(0[1-9]|1[0-2])-[0-3]\d-\d{2}-\d{2}-\d{2}`), parse: parseLayout("2006-01-02-15-04-05")},
 	// signal-2020-10-26-163832.jpg
-	{regexp.MustCompile(`(?i)(20|19|18)\d{2}-(0[1-9]|1[0-2])-[0-3]\d-\d{6}`), parseLayout("2006-01-02-150405")},
+	{name: "signal", re: regexp.MustCompile(`(?i)(20|19|18)\d{2}-(0[1-9]|1[0-2])-[0-3]\d-\d{6}`), parse: parseLayout("2006-01-02-150405")},
 	// 201801261147521000.jpg (use first 14 digits)
-	{regexp.MustCompile(`(?i)(20|19|18)\d{2}(0[1-9]|1[0-2])[0-3]\d\d{7,}`), parseDigitsFirst14()},
+	{name: "digits", re: regexp.MustCompile(`(?i)(20|19|18)\d{2}(0[1-9]|1[0-2])[0-3]\d\d{7,}`), parse: parseDigitsFirst14()},
 	// 2016_01_30_11_49_15.mp4
-	{regexp.MustCompile(`(?i)(20|19|18)\d{2}_(0[1-9]|1[0-2])_[0-3]\d_\d{2}_\d{2}_\d{2}`), parseLayout("2006_01_02_15_04_05")},
+	{name: "underscored", re: regexp.MustCompile(`(?i)(20|19|18)\d{2}_(0[1-9]|1[0-2])_[0-3]\d_\d{2}_\d{2}_\d{2}`), parse: parseLayout("2006_01_02_15_04_05")},
 	// WhatsApp: IMG-20201231-WA0001.jpg / VID-20201231-WA0001.mp4
-	{regexp.MustCompile(`(?i)(IMG|VID)-\d{8}-WA\d+`), parseWhatsApp()},
+	{name: "whatsapp", re: regexp.MustCompile(`(?i)(IMG|VID)-\d{8}-WA\d+`), parse: parseWhatsApp()},
 	// Snapchat: Snapchat-1699999999.jpg (Unix seconds)
-	{regexp.MustCompile(`(?i)Snapchat-(\d{10})`), parseSnapchatUnix()},
+	{name: "snapchat", re: regexp.MustCompile(`(?i)Snapchat-(\d{10})`), parse: parseSnapchatUnix()},
 	// Snapchat edited: Snapchat-1699999999-edited.jpg (Unix seconds)
-	{regexp.MustCompile(`(?i)Snapchat-(\d{10})-edited`), parseSnapchatUnix()},
+	{name: "snapchat", re: regexp.MustCompile(`(?i)Snapchat-(\d{10})-edited`), parse: parseSnapchatUnix()},
 	// Snapchat: Snapchat-1699999999999.jpg (Unix milliseconds)
-	{regexp.MustCompile(`(?i)Snapchat-(\d{13})`), parseSnapchatUnixMillis()},
+	{name: "snapchat", re: regexp.MustCompile(`(?i)Snapchat-(\d{13})`), parse: parseSnapchatUnixMillis()},
 	// Snapchat edited: Snapchat-1699999999999-edited.jpg (Unix milliseconds)
-	{regexp.MustCompile(`(?i)Snapchat-(\d{13})-edited`), parseSnapchatUnixMillis()},
-	// Pixel: PXL_20210102_123456.jpg
-	{regexp.MustCompile(`(?i)PXL_\d{8}_\d{6}`), parseLayout("PXL_20060102_150405")},
-	// Pixel with millis: PXL_20210102_123456789.jpg (take first 6 after date)
-	{regexp.MustCompile(`(?i)PXL_\d{8}_\d{9}`), parsePixelMillis()},
-	// Android: IMG_20210102_123456.jpg / VID_20210102_123456.mp4
-	{regexp.MustCompile(`(?i)(IMG|VID)_\d{8}_\d{6}`), parseLayout("IMG_20060102_150405")},
+	{name: "snapchat", re: regexp.MustCompile(`(?i)Snapchat-(\d{13})-edited`), parse: parseSnapchatUnixMillis()},
+}
+
+// patternZones holds the zones configured with SetPatternTimezones.
+var patternZones = map[string]*time.Location{}
+
+// SetPatternTimezones sets the zone of built-in filename patterns by name
+// (e.g. "pixel" -> "UTC"). Zones are "Local", "UTC", an offset like
+// "+05:30", or an IANA name.
+func SetPatternTimezones(zones map[string]string) error {
+	known := make(map[string]bool)
+	for _, pat := range datePatterns {
+		known[pat.name] = true
+	}
+	out := make(map[string]*time.Location, len(zones))
+	for name, zone := range zones {
+		if !known[name] {
+			return fmt.Errorf("unknown filename pattern %q", name)
+		}
+		loc, err := ParseTimezone(zone)
+		if err != nil {
+			return err
+		}
+		out[name] = loc
+	}
+	patternZones = out
+	return nil
+}
+
+// PatternNames lists the names of the built-in filename patterns.
+func PatternNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, pat := range datePatterns {
+		if !seen[pat.name] {
+			seen[pat.name] = true
+			names = append(names, pat.name)
+		}
+	}
+	return names
+}
+
+// ParseTimezone resolves "Local" (or empty), "UTC", a fixed offset such as
+// "+05:30" or "-0800", or an IANA zone name.
+func ParseTimezone(zone string) (*time.Location, error) {
+	zone = strings.TrimSpace(zone)
+	switch strings.ToLower(zone) {
+	case "", "local":
+		return time.Local, nil
+	case "utc", "z":
+		return time.UTC, nil
+	}
+	if zone[0] == '+' || zone[0] == '-' {
+		for _, layout := range []string{"-07:00", "-0700", "-07"} {
+			if t, err := time.Parse(layout, zone); err == nil {
+				_, offset := t.Zone()
+				return time.FixedZone(zone, offset), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid timezone offset %q", zone)
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", zone)
+	}
+	return loc, nil
+}
+
+func (p datePattern) location() *time.Location {
+	if p.loc != nil {
+		return p.loc
+	}
+	if loc, ok := patternZones[p.name]; ok {
+		return loc
+	}
+	return time.Local
 }
 
 // GuessDateFromFilename tries to extract a date from the file name.
@@ -62,7 +149,7 @@ func GuessDateFromFilename(path string) (time.Time, bool) {
 		if match == "" {
 			continue
 		}
-		if t, ok := pat.parse(match); ok {
+		if t, ok := pat.parse(match, pat.location()); ok {
 			return t, true
 		}
 	}
@@ -74,7 +161,7 @@ func ParseJSONTakenTime(jsonPath string) (time.Time, bool) {
 	if jsonPath == "" {
 		return time.Time{}, false
 	}
-	data, err := os.ReadFile(jsonPath)
+	data, err := fsutil.ReadFile(jsonPath)
 	if err != nil {
 		return time.Time{}, false
 	}
@@ -125,10 +212,32 @@ func ExtractBestDate(srcPath, jsonPath string) (time.Time, int, bool) {
 	return time.Time{}, DateAccuracyNone, false
 }
 
+// OverridePolicy controls when a filename date may replace the JSON date.
+type OverridePolicy struct {
+	// Threshold is how much older the filename date must be before it wins,
+	// so small clock skew does not override the authoritative JSON timestamp.
+	Threshold time.Duration
+	// Never disables filename overrides entirely.
+	Never bool
+}
+
+var overridePolicy OverridePolicy
+
+// SetOverridePolicy configures shouldOverrideJSON for the rest of the run.
+func SetOverridePolicy(policy OverridePolicy) {
+	overridePolicy = policy
+}
+
 func shouldOverrideJSON(jsonTime, fileTime time.Time) bool {
+	if overridePolicy.Never {
+		return false
+	}
 	if !fileTime.Before(jsonTime) {
 		return false
 	}
+	if jsonTime.Sub(fileTime) < overridePolicy.Threshold {
+		return false
+	}
 	if !isReasonable(fileTime) {
 		return false
 	}
@@ -166,20 +275,20 @@ func parseTimestamp(v any) (int64, bool) {
 	}
 }
 
-func parseLayout(layout string) func(string) (time.Time, bool) {
-	return func(s string) (time.Time, bool) {
-		return ParseWithLayout(layout, s)
+func parseLayout(layout string) func(string, *time.Location) (time.Time, bool) {
+	return func(s string, loc *time.Location) (time.Time, bool) {
+		return ParseWithLayoutIn(layout, s, loc)
 	}
 }
 
-func parseDigitsFirst14() func(string) (time.Time, bool) {
-	return func(s string) (time.Time, bool) {
+func parseDigitsFirst14() func(string, *time.Location) (time.Time, bool) {
+	return func(s string, loc *time.Location) (time.Time, bool) {
 		digits := regexp.MustCompile(`\d+`).FindString(s)
 		if len(digits) < 14 {
 			return time.Time{}, false
 		}
 		digits = digits[:14]
-		t, err := time.ParseInLocation("20060102150405", digits, time.Local)
+		t, err := time.ParseInLocation("20060102150405", digits, loc)
 		if err != nil {
 			return time.Time{}, false
 		}
@@ -187,14 +296,14 @@ func parseDigitsFirst14() func(string) (time.Time, bool) {
 	}
 }
 
-func parseWhatsApp() func(string) (time.Time, bool) {
-	return func(s string) (time.Time, bool) {
+func parseWhatsApp() func(string, *time.Location) (time.Time, bool) {
+	return func(s string, loc *time.Location) (time.Time, bool) {
 		re := regexp.MustCompile(`(?i)(IMG|VID)-(\d{8})-WA\d+`)
 		m := re.FindStringSubmatch(s)
 		if len(m) < 3 {
 			return time.Time{}, false
 		}
-		t, err := time.ParseInLocation("20060102", m[2], time.Local)
+		t, err := time.ParseInLocation("20060102", m[2], loc)
 		if err != nil {
 			return time.Time{}, false
 		}
@@ -202,8 +311,8 @@ func parseWhatsApp() func(string) (time.Time, bool) {
 	}
 }
 
-func parsePixelMillis() func(string) (time.Time, bool) {
-	return func(s string) (time.Time, bool) {
+func parsePixelMillis() func(string, *time.Location) (time.Time, bool) {
+	return func(s string, loc *time.Location) (time.Time, bool) {
 		re := regexp.MustCompile(`(?i)PXL_(\d{8})_(\d{9})`)
 		m := re.FindStringSubmatch(s)
 		if len(m) < 3 {
@@ -215,12 +324,12 @@ func parsePixelMillis() func(string) (time.Time, bool) {
 			return time.Time{}, false
 		}
 		ts := fmt.Sprintf("PXL_%s_%s", m[1], timePart[:6])
-		return parseLayout("PXL_20060102_150405")(ts)
+		return ParseWithLayoutIn("PXL_20060102_150405", ts, loc)
 	}
 }
 
-func parseSnapchatUnix() func(string) (time.Time, bool) {
-	return func(s string) (time.Time, bool) {
+func parseSnapchatUnix() func(string, *time.Location) (time.Time, bool) {
+	return func(s string, _ *time.Location) (time.Time, bool) {
 		re := regexp.MustCompile(`(?i)Snapchat-(\d{10})`)
 		m := re.FindStringSubmatch(s)
 		if len(m) < 2 {
@@ -230,8 +339,8 @@ func parseSnapchatUnix() func(string) (time.Time, bool) {
 	}
 }
 
-func parseSnapchatUnixMillis() func(string) (time.Time, bool) {
-	return func(s string) (time.Time, bool) {
+func parseSnapchatUnixMillis() func(string, *time.Location) (time.Time, bool) {
+	return func(s string, _ *time.Location) (time.Time, bool) {
 		re := regexp.MustCompile(`(?i)Snapchat-(\d{13})`)
 		m := re.FindStringSubmatch(s)
 		if len(m) < 2 {
@@ -242,6 +351,12 @@ func parseSnapchatUnixMillis() func(string) (time.Time, bool) {
 }
 
 func ParseWithLayout(layout, value string) (time.Time, bool) {
+	return ParseWithLayoutIn(layout, value, time.Local)
+}
+
+// ParseWithLayoutIn is ParseWithLayout for timestamps in loc. UNIX layouts
+// are absolute and ignore it.
+func ParseWithLayoutIn(layout, value string, loc *time.Location) (time.Time, bool) {
 	switch strings.ToUpper(strings.TrimSpace(layout)) {
 	case "UNIXMS":
 		ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
@@ -256,7 +371,7 @@ func ParseWithLayout(layout, value string) (time.Time, bool) {
 		}
 		return time.Unix(sec, 0), true
 	default:
-		t, err := time.ParseInLocation(layout, value, time.Local)
+		t, err := time.ParseInLocation(layout, value, loc)
 		if err != nil {
 			return time.Time{}, false
 		}
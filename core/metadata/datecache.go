@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DateCacheEntry is the outcome of date analysis for one file, keyed by the
+// file's content hash, or by its path, size and mtime when the run does not
+// hash. Name, HasSidecar, the sidecar's size and mtime, and Settings must
+// match for the entry to be reused, since the filename, the JSON sidecar and
+// the date settings all feed into the result.
+type DateCacheEntry struct {
+	Name           string `json:"name"`
+	HasSidecar     bool   `json:"has_sidecar"`
+	SidecarSize    int64  `json:"sidecar_size"`
+	SidecarMtimeNs int64  `json:"sidecar_mtime_ns"`
+	Settings       string `json:"settings"`
+
+	JSON        JSONMeta  `json:"json"`
+	HasJSONMeta bool      `json:"has_json_meta"`
+	JSONTime    time.Time `json:"json_time"`
+	HasJSON     bool      `json:"has_json"`
+	FileTime    time.Time `json:"file_time"`
+	HasFile     bool      `json:"has_file"`
+	ExifTime    time.Time `json:"exif_time"`
+	HasExif     bool      `json:"has_exif"`
+	Proposed    time.Time `json:"proposed"`
+	Accuracy    int       `json:"accuracy"`
+}
+
+// dateCacheVersion is bumped whenever JSONMeta or the entry gains fields, so
+// entries cached before that no longer match.
+const dateCacheVersion = 3
+
+func LoadDateCache(path string) (map[string]DateCacheEntry, error) {
+	if path == "" {
+		return map[string]DateCacheEntry{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]DateCacheEntry{}, nil
+		}
+		return nil, err
+	}
+	var cache map[string]DateCacheEntry
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	}
+	if cache == nil {
+		cache = map[string]DateCacheEntry{}
+	}
+	return cache, nil
+}
+
+func SaveDateCache(path string, cache map[string]DateCacheEntry) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// DateSettingsFingerprint identifies the custom patterns, exclusions,
+// pattern timezones and override policy that date analysis ran with.
+func DateSettingsFingerprint(custom []CustomPattern, exclude map[string]bool) string {
+	excluded := make([]string, 0, len(exclude))
+	for k, v := range exclude {
+		if v {
+			excluded = append(excluded, k)
+		}
+	}
+	sort.Strings(excluded)
+	zones := make(map[string]string, len(patternZones))
+	for name, loc := range patternZones {
+		zones[name] = loc.String()
+	}
+	data, _ := json.Marshal(struct {
+		Version  int
+		Custom   []CustomPattern
+		Excluded []string
+		Zones    map[string]string
+		Policy   OverridePolicy
+	}{dateCacheVersion, custom, excluded, zones, overridePolicy})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
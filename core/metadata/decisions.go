@@ -0,0 +1,49 @@
+package metadata
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// DateDecision is a reviewed and applied date for one file, keyed by the
+// file's content hash so it survives renames and new Takeout exports.
+type DateDecision struct {
+	TakenTime string `json:"taken_time"`
+	Accuracy  int    `json:"accuracy"`
+}
+
+func LoadDateDecisions(path string) (map[string]DateDecision, error) {
+	if path == "" {
+		return map[string]DateDecision{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]DateDecision{}, nil
+		}
+		return nil, err
+	}
+	var decisions map[string]DateDecision
+	if err := json.Unmarshal(data, &decisions); err != nil {
+		return nil, err
+	}
+	if decisions == nil {
+		decisions = map[string]DateDecision{}
+	}
+	return decisions, nil
+}
+
+func SaveDateDecisions(path string, decisions map[string]DateDecision) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(decisions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,107 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	"io"
+	"os"
+)
+
+// ImageDimensions returns the pixel size of JPEG, PNG, and GIF files by
+// decoding only the image header.
+func ImageDimensions(path string) (int, int, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, false
+	}
+	return cfg.Width, cfg.Height, true
+}
+
+// ImageOrientation returns the EXIF Orientation (1-8) of a JPEG file by
+// reading only its APP1 segment, or 1 when there is none.
+func ImageOrientation(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var marker [2]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil || marker != [2]byte{0xFF, 0xD8} {
+		return 1
+	}
+	for {
+		if _, err := io.ReadFull(r, marker[:]); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+		// Start of scan or end of image: the metadata segments are over.
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return 1
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return 1
+		}
+		n := int(binary.BigEndian.Uint16(size[:])) - 2
+		if n < 0 {
+			return 1
+		}
+		if marker[1] != 0xE1 {
+			if _, err := r.Discard(n); err != nil {
+				return 1
+			}
+			continue
+		}
+		seg := make([]byte, n)
+		if _, err := io.ReadFull(r, seg); err != nil {
+			return 1
+		}
+		if o, ok := exifOrientation(seg); ok {
+			return o
+		}
+	}
+}
+
+// exifOrientation reads the Orientation tag from IFD0 of an APP1 segment
+// that starts with the Exif header.
+func exifOrientation(seg []byte) (int, bool) {
+	if len(seg) < 14 || string(seg[:6]) != "Exif\x00\x00" {
+		return 0, false
+	}
+	tiff := seg[6:]
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0, false
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 {
+			continue
+		}
+		o := int(order.Uint16(tiff[entry+8:]))
+		if o < 1 || o > 8 {
+			return 0, false
+		}
+		return o, true
+	}
+	return 0, false
+}
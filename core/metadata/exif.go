@@ -9,6 +9,7 @@ import (
 )
 
 type exifResult struct {
+	SourceFile       string `json:"SourceFile"`
 	DateTimeOriginal string `json:"DateTimeOriginal"`
 	CreateDate       string `json:"CreateDate"`
 	MediaCreateDate  string `json:"MediaCreateDate"`
@@ -29,25 +30,54 @@ func hasExiftool() bool {
 	return exiftoolAvailable
 }
 
+// ExiftoolVersion returns the installed exiftool version string.
+func ExiftoolVersion() (string, bool) {
+	if !hasExiftool() {
+		return "", false
+	}
+	out, err := exec.Command("exiftool", "-ver").Output()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(out)), true
+}
+
+// exifPrefetchBatch is how many files one exiftool invocation reads during
+// PrefetchExifTakenTimes.
+const exifPrefetchBatch = 100
+
+type exifCacheEntry struct {
+	t  time.Time
+	ok bool
+}
+
+// exifCache holds taken times read ahead of time by PrefetchExifTakenTimes,
+// including misses, keyed by path.
+var exifCache sync.Map
+
+var exifDateArgs = []string{
+	"-j",
+	"-DateTimeOriginal",
+	"-CreateDate",
+	"-MediaCreateDate",
+	"-TrackCreateDate",
+	"-d",
+	"%Y-%m-%dT%H:%M:%S%z",
+}
+
 func ParseExifTakenTime(path string) (time.Time, bool) {
 	if path == "" {
 		return time.Time{}, false
 	}
+	if cached, ok := exifCache.Load(path); ok {
+		entry := cached.(exifCacheEntry)
+		return entry.t, entry.ok
+	}
 	if !hasExiftool() {
 		return time.Time{}, false
 	}
 
-	out, err := exec.Command(
-		"exiftool",
-		"-j",
-		"-DateTimeOriginal",
-		"-CreateDate",
-		"-MediaCreateDate",
-		"-TrackCreateDate",
-		"-d",
-		"%Y-%m-%dT%H:%M:%S%z",
-		path,
-	).Output()
+	out, err := exec.Command("exiftool", append(append([]string{}, exifDateArgs...), path)...).Output()
 	if err != nil {
 		return time.Time{}, false
 	}
@@ -59,12 +89,78 @@ func ParseExifTakenTime(path string) (time.Time, bool) {
 	if len(rows) == 0 {
 		return time.Time{}, false
 	}
+	return rows[0].takenTime()
+}
+
+// PrefetchExifTakenTimes reads the taken time of many files with a few
+// exiftool invocations running in parallel, so later ParseExifTakenTime calls
+// for those paths are answered from memory.
+func PrefetchExifTakenTimes(paths []string, workers int, progress func(done, total int)) {
+	if len(paths) == 0 || !hasExiftool() {
+		return
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	batches := make(chan []string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				prefetchExifBatch(batch)
+				if progress != nil {
+					mu.Lock()
+					done += len(batch)
+					progress(done, len(paths))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for start := 0; start < len(paths); start += exifPrefetchBatch {
+		end := start + exifPrefetchBatch
+		if end > len(paths) {
+			end = len(paths)
+		}
+		batches <- paths[start:end]
+	}
+	close(batches)
+	wg.Wait()
+}
+
+func prefetchExifBatch(paths []string) {
+	// exiftool exits non-zero when any file in the batch fails, but still
+	// prints the rows it could read.
+	out, _ := exec.Command("exiftool", append(append([]string{}, exifDateArgs...), paths...)...).Output()
+	var rows []exifResult
+	if len(out) > 0 {
+		_ = json.Unmarshal(out, &rows)
+	}
+	found := make(map[string]exifResult, len(rows))
+	for _, row := range rows {
+		found[row.SourceFile] = row
+	}
+	for _, path := range paths {
+		row, ok := found[path]
+		if !ok {
+			// Leave unread files to the per-file fallback.
+			continue
+		}
+		t, ok := row.takenTime()
+		exifCache.Store(path, exifCacheEntry{t: t, ok: ok})
+	}
+}
 
+func (r exifResult) takenTime() (time.Time, bool) {
 	for _, v := range []string{
-		rows[0].DateTimeOriginal,
-		rows[0].CreateDate,
-		rows[0].MediaCreateDate,
-		rows[0].TrackCreateDate,
+		r.DateTimeOriginal,
+		r.CreateDate,
+		r.MediaCreateDate,
+		r.TrackCreateDate,
 	} {
 		if t, ok := parseExifTime(v); ok {
 			return t, true
@@ -0,0 +1,100 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckMediaIntegrity performs a cheap structural check on a media file.
+// It returns a human-readable reason and false when the file is empty,
+// truncated, or does not decode as the format its extension claims.
+func CheckMediaIntegrity(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("unreadable: %v", err), false
+	}
+	if info.Size() == 0 {
+		return "zero-byte file", false
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		if kind, ok := DetectFileKind(path); ok && kind != "jpeg" && kind != "png" && kind != "gif" {
+			// Mislabelled but otherwise valid; the output stage fixes the extension.
+			return "", true
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Sprintf("unreadable: %v", err), false
+		}
+		defer f.Close()
+		if _, _, err := image.DecodeConfig(f); err != nil {
+			return fmt.Sprintf("image header does not decode: %v", err), false
+		}
+	case ".heic", ".heif", ".mp4", ".mov", ".m4v":
+		if reason, ok := checkBoxStructure(path, info.Size()); !ok {
+			return reason, false
+		}
+	}
+	return "", true
+}
+
+// firstBoxTypes are the box types a valid file can start with: ftyp, or in
+// older QuickTime files padding (wide, free, skip), a placeholder (pnot)
+// or the media and movie boxes themselves.
+var firstBoxTypes = map[string]bool{
+	"ftyp": true, "wide": true, "free": true, "skip": true, "pnot": true, "mdat": true, "moov": true,
+}
+
+// checkBoxStructure walks the top-level ISO BMFF boxes and reports files
+// whose boxes run past the end of the file.
+func checkBoxStructure(path string, size int64) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Sprintf("unreadable: %v", err), false
+	}
+	defer f.Close()
+
+	var offset int64
+	header := make([]byte, 16)
+	for boxes := 0; offset < size; boxes++ {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			if err == io.EOF && boxes > 0 {
+				return "truncated box header", false
+			}
+			return fmt.Sprintf("unreadable box header: %v", err), false
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		if boxes == 0 && !firstBoxTypes[boxType] {
+			return "missing ftyp box", false
+		}
+		switch boxSize {
+		case 0:
+			// Box extends to end of file.
+			return "", true
+		case 1:
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return "truncated box header", false
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+		if boxSize < 8 {
+			return fmt.Sprintf("invalid %q box size", boxType), false
+		}
+		if offset+boxSize > size {
+			return fmt.Sprintf("truncated %q box", boxType), false
+		}
+		offset += boxSize
+	}
+	return "", true
+}
@@ -2,9 +2,11 @@ package metadata
 
 import (
 	"encoding/json"
-	"os"
 	"strings"
 	"time"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
 )
 
 type JSONMeta struct {
@@ -20,10 +22,18 @@ type JSONMeta struct {
 	Origin         JSONOrigin
 	Geo            JSONGeo
 	HasGeo         bool
+	LastModified   time.Time
+	HasModified    bool
+	Archived       bool
+	Trashed        bool
+	HasArchived    bool
+	HasTrashed     bool
+	ImageViews     int
 }
 
 type JSONOrigin struct {
 	FromSharedAlbum          bool
+	FromPartnerSharing       bool
 	WebUpload                bool
 	MobileUpload             bool
 	MobileUploadDeviceType   string
@@ -75,6 +85,7 @@ type jsonComposition struct {
 type jsonOrigin struct {
 	Composition     jsonComposition  `json:"composition"`
 	FromSharedAlbum map[string]any   `json:"fromSharedAlbum"`
+	FromPartner     map[string]any   `json:"fromPartnerSharing"`
 	MobileUpload    jsonMobileUpload `json:"mobileUpload"`
 	WebUpload       map[string]any   `json:"webUpload"`
 }
@@ -89,13 +100,18 @@ type jsonMeta struct {
 	URL                string        `json:"url"`
 	AppSource          jsonAppSource `json:"appSource"`
 	GooglePhotosOrigin jsonOrigin    `json:"googlePhotosOrigin"`
+	// Fields only present in newer exports.
+	PhotoLastModifiedTime jsonTime `json:"photoLastModifiedTime"`
+	Archived              *bool    `json:"archived"`
+	Trashed               *bool    `json:"trashed"`
+	ImageViews            any      `json:"imageViews"`
 }
 
 func ParseJSONMeta(jsonPath string) (JSONMeta, bool) {
 	if jsonPath == "" {
 		return JSONMeta{}, false
 	}
-	data, err := os.ReadFile(jsonPath)
+	data, err := fsutil.ReadFile(jsonPath)
 	if err != nil {
 		return JSONMeta{}, false
 	}
@@ -120,6 +136,19 @@ func ParseJSONMeta(jsonPath string) (JSONMeta, bool) {
 		out.CreationTime = time.Unix(ts, 0)
 		out.HasCreation = true
 	}
+	if ts, ok := parseTimestamp(raw.PhotoLastModifiedTime.Timestamp); ok {
+		out.LastModified = time.Unix(ts, 0)
+		out.HasModified = true
+	}
+	if raw.Archived != nil {
+		out.Archived, out.HasArchived = *raw.Archived, true
+	}
+	if raw.Trashed != nil {
+		out.Trashed, out.HasTrashed = *raw.Trashed, true
+	}
+	if views, ok := parseTimestamp(raw.ImageViews); ok {
+		out.ImageViews = int(views)
+	}
 
 	for _, p := range raw.People {
 		name := strings.TrimSpace(p.Name)
@@ -143,6 +172,9 @@ func ParseJSONMeta(jsonPath string) (JSONMeta, bool) {
 	if raw.GooglePhotosOrigin.FromSharedAlbum != nil {
 		out.Origin.FromSharedAlbum = true
 	}
+	if raw.GooglePhotosOrigin.FromPartner != nil {
+		out.Origin.FromPartnerSharing = true
+	}
 	if raw.GooglePhotosOrigin.WebUpload != nil {
 		out.Origin.WebUpload = true
 	}
@@ -157,3 +189,38 @@ func ParseJSONMeta(jsonPath string) (JSONMeta, bool) {
 
 	return out, true
 }
+
+// JSONMetaFromImport converts metadata an importer read from a non-Google
+// export into the sidecar form the date analysis works with.
+func JSONMetaFromImport(meta models.MetaData) JSONMeta {
+	out := JSONMeta{
+		Description: meta.Description,
+		Favorited:   meta.Favorited,
+		People:      meta.People,
+		URL:         meta.URL,
+		AppSource:   meta.AppSource,
+		Archived:    meta.Archived,
+		Trashed:     meta.Trashed,
+		HasArchived: meta.HasArchived,
+		HasTrashed:  meta.HasTrashed,
+		ImageViews:  meta.ImageViews,
+		HasGeo:      meta.HasGeo,
+		Geo: JSONGeo{
+			Latitude:      meta.GPSLat,
+			Longitude:     meta.GPSLon,
+			Altitude:      meta.GPSAlt,
+			LatitudeSpan:  meta.GPSSpanLat,
+			LongitudeSpan: meta.GPSSpanLon,
+		},
+	}
+	if t, err := time.Parse(time.RFC3339, meta.TakenTime); err == nil {
+		out.PhotoTakenTime, out.HasPhotoTaken = t, true
+	}
+	if t, err := time.Parse(time.RFC3339, meta.CreationTime); err == nil {
+		out.CreationTime, out.HasCreation = t, true
+	}
+	if t, err := time.Parse(time.RFC3339, meta.ModifyTime); err == nil {
+		out.LastModified, out.HasModified = t, true
+	}
+	return out
+}
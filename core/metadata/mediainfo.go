@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"gphotos/core/models"
+)
+
+// mediaInfoResult mirrors exiftool -j -n output. Make and Model are untyped
+// because exiftool prints numeric-looking strings (a model "6") as numbers.
+type mediaInfoResult struct {
+	SourceFile  string  `json:"SourceFile"`
+	ImageWidth  int     `json:"ImageWidth"`
+	ImageHeight int     `json:"ImageHeight"`
+	Duration    float64 `json:"Duration"`
+	Make        any     `json:"Make"`
+	Model       any     `json:"Model"`
+}
+
+func exifString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
+
+// LoadMediaInfo fills Width, Height, Duration, CameraMake and CameraModel
+// for every photo. It reads them with batched exiftool calls running on
+// workers goroutines; without exiftool only image dimensions are filled,
+// from the JPEG/PNG/GIF header.
+func LoadMediaInfo(photos []*models.Photo, workers int, progress func(done, total int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	byPath := make(map[string][]*models.Photo, len(photos))
+	paths := make([]string, 0, len(photos))
+	for _, p := range photos {
+		if p == nil || p.SrcPath == "" {
+			continue
+		}
+		if _, ok := byPath[p.SrcPath]; !ok {
+			paths = append(paths, p.SrcPath)
+		}
+		byPath[p.SrcPath] = append(byPath[p.SrcPath], p)
+	}
+
+	batches := make(chan []string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				rows := readMediaInfo(batch)
+				for _, path := range batch {
+					row, ok := rows[path]
+					if !ok {
+						if w, h, ok := ImageDimensions(path); ok {
+							row = mediaInfoResult{ImageWidth: w, ImageHeight: h}
+						}
+					}
+					for _, p := range byPath[path] {
+						p.Meta.Width = row.ImageWidth
+						p.Meta.Height = row.ImageHeight
+						p.Meta.Duration = row.Duration
+						p.Meta.CameraMake = exifString(row.Make)
+						p.Meta.CameraModel = exifString(row.Model)
+					}
+				}
+				if progress != nil {
+					mu.Lock()
+					done += len(batch)
+					progress(done, len(paths))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for start := 0; start < len(paths); start += exifPrefetchBatch {
+		end := start + exifPrefetchBatch
+		if end > len(paths) {
+			end = len(paths)
+		}
+		batches <- paths[start:end]
+	}
+	close(batches)
+	wg.Wait()
+}
+
+func readMediaInfo(paths []string) map[string]mediaInfoResult {
+	rows := make(map[string]mediaInfoResult, len(paths))
+	if !hasExiftool() {
+		return rows
+	}
+	args := append([]string{"-j", "-n", "-fast", "-ImageWidth", "-ImageHeight", "-Duration", "-Make", "-Model"}, paths...)
+	// As with date prefetching, a failing file does not stop the others.
+	out, _ := exec.Command("exiftool", args...).Output()
+	var results []mediaInfoResult
+	if len(out) > 0 {
+		_ = json.Unmarshal(out, &results)
+	}
+	for _, r := range results {
+		rows[r.SourceFile] = r
+	}
+	return rows
+}
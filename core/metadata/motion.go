@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// Older MVIMG files give the video's distance from the end of the file.
+	microVideoOffsetRe = regexp.MustCompile(`GCamera:MicroVideoOffset(?:="|>)(\d+)`)
+	// Newer motion photos list the video as an item of the XMP container.
+	motionItemRe   = regexp.MustCompile(`<Container:Item\b[^>]*Item:Semantic="MotionPhoto"[^>]*>`)
+	itemLengthRe   = regexp.MustCompile(`Item:Length="(\d+)"`)
+	samsungTrailer = []byte("MotionPhoto_Data")
+	// motionFlags turn a still back into an ordinary photo when the video is
+	// removed; "0" keeps the XMP packet the same length.
+	motionFlags = [][2][]byte{
+		{[]byte(`GCamera:MicroVideo="1"`), []byte(`GCamera:MicroVideo="0"`)},
+		{[]byte(`GCamera:MotionPhoto="1"`), []byte(`GCamera:MotionPhoto="0"`)},
+		{[]byte(`<GCamera:MicroVideo>1<`), []byte(`<GCamera:MicroVideo>0<`)},
+		{[]byte(`<GCamera:MotionPhoto>1<`), []byte(`<GCamera:MotionPhoto>0<`)},
+	}
+)
+
+// MotionVideo finds the MP4 a motion photo (Google MVIMG_/PXL_*.MP.jpg or
+// Samsung) carries after its JPEG data. It returns the whole file and the
+// offset of the video, or ok false for ordinary JPEGs.
+func MotionVideo(path string) (data []byte, offset int, ok bool) {
+	if kind, known := DetectFileKind(path); !known || kind != "jpeg" {
+		return nil, 0, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, false
+	}
+	isVideo := func(off int) bool {
+		return off > 0 && off+8 <= len(data) && bytes.Equal(data[off+4:off+8], []byte("ftyp"))
+	}
+	if m := microVideoOffsetRe.FindSubmatch(data); m != nil {
+		if n, err := strconv.Atoi(string(m[1])); err == nil && isVideo(len(data)-n) {
+			return data, len(data) - n, true
+		}
+	}
+	if item := motionItemRe.Find(data); item != nil {
+		if m := itemLengthRe.FindSubmatch(item); m != nil {
+			if n, err := strconv.Atoi(string(m[1])); err == nil && isVideo(len(data)-n) {
+				return data, len(data) - n, true
+			}
+		}
+	}
+	if i := bytes.LastIndex(data, samsungTrailer); i >= 0 && isVideo(i+len(samsungTrailer)) {
+		return data, i + len(samsungTrailer), true
+	}
+	return nil, 0, false
+}
+
+// StillOnly returns the JPEG part of a motion photo with its motion flags
+// cleared, so viewers show it as a plain photo.
+func StillOnly(data []byte, offset int) []byte {
+	still := bytes.Clone(data[:offset])
+	if i := bytes.LastIndex(still, samsungTrailer); i >= 0 && i+len(samsungTrailer) == len(still) {
+		still = still[:i]
+	}
+	for _, f := range motionFlags {
+		still = bytes.ReplaceAll(still, f[0], f[1])
+	}
+	return still
+}
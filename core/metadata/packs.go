@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// PatternPack is a shareable set of custom filename patterns.
+type PatternPack struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Patterns    []CustomPattern `json:"patterns"`
+}
+
+func LoadPatternPack(path string) (PatternPack, error) {
+	var pack PatternPack
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pack, err
+	}
+	if err := json.Unmarshal(data, &pack); err != nil {
+		return pack, fmt.Errorf("%s: %w", path, err)
+	}
+	return pack, nil
+}
+
+func SavePatternPack(path string, pack PatternPack) error {
+	data, err := json.MarshalIndent(pack, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// CheckPattern verifies that a pattern compiles, has a valid layout and
+// timezone, and parses each of its examples.
+func CheckPattern(c CustomPattern) error {
+	if c.Regex == "" || c.Layout == "" {
+		return fmt.Errorf("regex and layout are required")
+	}
+	re, err := regexp.Compile(c.Regex)
+	if err != nil {
+		return err
+	}
+	loc, err := ParseTimezone(c.Timezone)
+	if err != nil {
+		return err
+	}
+	parse := parseLayout(c.Layout)
+	for _, example := range c.Examples {
+		if _, ok := guessWithPatterns(example, []datePattern{{re: re, parse: parse, loc: loc}}); !ok {
+			return fmt.Errorf("example %q does not parse", example)
+		}
+	}
+	return nil
+}
+
+// MergePatterns adds incoming patterns to existing ones. A pattern replaces
+// an existing one with the same name, or with the same regex and layout;
+// otherwise it is appended. It returns the merged list and how many were
+// added and replaced.
+func MergePatterns(existing, incoming []CustomPattern) ([]CustomPattern, int, int) {
+	out := make([]CustomPattern, len(existing))
+	copy(out, existing)
+	added, replaced := 0, 0
+	for _, in := range incoming {
+		found := false
+		for i, cur := range out {
+			if (in.Name != "" && cur.Name == in.Name) || (cur.Regex == in.Regex && cur.Layout == in.Layout) {
+				out[i] = in
+				found = true
+				replaced++
+				break
+			}
+		}
+		if !found {
+			out = append(out, in)
+			added++
+		}
+	}
+	return out, added, replaced
+}
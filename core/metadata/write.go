@@ -1,13 +1,13 @@
 package metadata
 
 import (
+	"encoding/base64"
 	"fmt"
-	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"gphotos/core/models"
@@ -35,12 +35,21 @@ var supportedWriteExt = map[string]bool{
 type WriteItem struct {
 	Path string
 	Meta models.MetaData
+	// Sidecar writes the tags to an XMP sidecar next to Path instead of
+	// touching the file itself.
+	Sidecar bool
 }
 
-type BatchWriter struct {
-	cmd   *exec.Cmd
-	stdin io.WriteCloser
-	mu    sync.Mutex
+// SidecarPath returns the XMP sidecar used for path (NAME.ext.xmp).
+func SidecarPath(path string) string {
+	return path + ".xmp"
+}
+
+// CanWriteInPlace reports whether exiftool can safely write into the file
+// itself: the extension is supported and matches the file contents.
+func CanWriteInPlace(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return supportedWriteExt[ext] && matchesExtension(path, ext)
 }
 
 func CanWriteMeta() bool {
@@ -48,7 +57,7 @@ func CanWriteMeta() bool {
 }
 
 func HasWritableMeta(meta models.MetaData) bool {
-	if meta.TakenTime != "" || meta.CreationTime != "" || meta.HasGeo || meta.Description != "" || meta.Favorited || meta.URL != "" || meta.AppSource != "" {
+	if meta.TakenTime != "" || meta.CreationTime != "" || meta.ModifyTime != "" || meta.HasGeo || meta.Description != "" || meta.Favorited || meta.URL != "" || meta.AppSource != "" || meta.SourceJSON != "" || meta.SourcePath != "" {
 		return true
 	}
 	if len(meta.People) > 0 {
@@ -67,11 +76,12 @@ func WriteMetaToFile(path string, meta models.MetaData) error {
 	if !hasExiftool() {
 		return fmt.Errorf("exiftool not available")
 	}
-	itemArgs, ok := buildArgsForMeta(path, meta)
+	itemArgs, ok := buildArgsForItem(WriteItem{Path: path, Meta: meta})
 	if !ok {
 		return nil
 	}
-	args := append([]string{"-overwrite_original", "-q", "-q", "-m"}, itemArgs...)
+	args := append(exiftoolConfigArgs(), "-overwrite_original", "-q", "-q", "-m")
+	args = append(args, itemArgs...)
 	cmd := exec.Command("exiftool", args...)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("exiftool failed: %v (%s)", err, strings.TrimSpace(string(out)))
@@ -87,13 +97,13 @@ func WriteMetaBatch(items []WriteItem) error {
 		return fmt.Errorf("exiftool not available")
 	}
 
-	args := []string{"-overwrite_original", "-q", "-q", "-m"}
+	args := append(exiftoolConfigArgs(), "-overwrite_original", "-q", "-q", "-m")
 	wrote := 0
 	for _, item := range items {
 		if item.Path == "" || !HasWritableMeta(item.Meta) {
 			continue
 		}
-		itemArgs, ok := buildArgsForMeta(item.Path, item.Meta)
+		itemArgs, ok := buildArgsForItem(item)
 		if !ok {
 			continue
 		}
@@ -133,76 +143,237 @@ func isVideoExt(ext string) bool {
 	}
 }
 
-func buildArgsForMeta(path string, meta models.MetaData) ([]string, bool) {
-	ext := strings.ToLower(filepath.Ext(path))
-	if !supportedWriteExt[ext] {
+// IsVideoPath reports whether the path has one of the video extensions.
+func IsVideoPath(path string) bool {
+	return isVideoExt(strings.ToLower(filepath.Ext(path)))
+}
+
+func buildArgsForItem(item WriteItem) ([]string, bool) {
+	tags, files, ok := planItem(item)
+	if !ok {
 		return nil, false
 	}
-	if !matchesExtension(path, ext) {
+	return append(tagArgs(tags), files...), true
+}
+
+// PlannedTags returns the assignments a write of item would make, as
+// TAG=VALUE or TAG+=VALUE, and false when nothing would be written.
+func PlannedTags(item WriteItem) ([]string, bool) {
+	tags, _, ok := planItem(item)
+	if !ok {
 		return nil, false
 	}
-	args := []string{}
+	args := tagArgs(tags)
+	for i, arg := range args {
+		args[i] = strings.TrimPrefix(arg, "-")
+	}
+	return args, true
+}
+
+// planItem returns the tag assignments for item and the file arguments
+// exiftool applies them with; the last file argument is the file exiftool
+// reads.
+func planItem(item WriteItem) ([]tagValue, []string, bool) {
+	ext := strings.ToLower(filepath.Ext(item.Path))
+	if !item.Sidecar && (!supportedWriteExt[ext] || !matchesExtension(item.Path, ext)) {
+		return nil, nil, false
+	}
+	tags := buildTags(ext, item.Meta)
+	if len(tags) == 0 {
+		return nil, nil, false
+	}
+	if !item.Sidecar {
+		return tags, []string{item.Path}, true
+	}
+	xmp := SidecarPath(item.Path)
+	if _, err := os.Stat(xmp); err == nil {
+		// Update the existing sidecar from an earlier run.
+		return tags, []string{xmp}, true
+	}
+	return tags, []string{"-o", xmp, item.Path}, true
+}
+
+// tagValue is one exiftool tag assignment. List tags such as
+// PersonInImage are added to instead of replaced.
+type tagValue struct {
+	tag   string
+	value string
+	add   bool
+}
+
+// tagArgs renders tags as -TAG=VALUE command-line arguments.
+func tagArgs(tags []tagValue) []string {
+	args := make([]string, 0, len(tags))
+	for _, t := range tags {
+		op := "="
+		if t.add {
+			op = "+="
+		}
+		args = append(args, "-"+t.tag+op+t.value)
+	}
+	return args
+}
+
+// jsonEntry renders tags as one object of an exiftool -json+= import file.
+// exiftool applies it to the file named source.
+func jsonEntry(source string, tags []tagValue) map[string]any {
+	entry := map[string]any{"SourceFile": source}
+	for _, t := range tags {
+		value := jsonValue(t.value)
+		if !t.add {
+			entry[t.tag] = value
+			continue
+		}
+		list, _ := entry[t.tag].([]string)
+		entry[t.tag] = append(list, value)
+	}
+	return entry
+}
+
+// jsonValue protects a value exiftool would otherwise decode on import:
+// strings starting with "base64:" are taken as encoded binary, so such a
+// value is itself base64-encoded and decodes back to the original text.
+func jsonValue(v string) string {
+	if strings.HasPrefix(v, "base64:") {
+		return "base64:" + base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	return v
+}
+
+// TagPolicy selects optional tags for apps that index other fields than the
+// ones written by default.
+type TagPolicy struct {
+	// FileDateFromTaken sets the file modification date to the taken date
+	// instead of Google's last-modified time, for apps that date videos and
+	// files without EXIF by it.
+	FileDateFromTaken bool
+	// IPTC also writes the description and people as IPTC Caption-Abstract
+	// and Keywords in JPEG and TIFF files.
+	IPTC bool
+}
+
+var tagPolicy TagPolicy
+
+// SetTagPolicy configures buildTags for the rest of the run.
+func SetTagPolicy(policy TagPolicy) {
+	tagPolicy = policy
+}
+
+var iptcExt = map[string]bool{".jpg": true, ".jpeg": true, ".tif": true, ".tiff": true}
+
+// buildTags returns the exiftool tag assignments for meta on a file with
+// the given extension.
+func buildTags(ext string, meta models.MetaData) []tagValue {
+	var tags []tagValue
+	set := func(tag, value string) {
+		tags = append(tags, tagValue{tag: tag, value: value})
+	}
 
 	if meta.TakenTime != "" {
 		if t, err := time.Parse(time.RFC3339, meta.TakenTime); err == nil {
 			ts := t.Format("2006:01:02 15:04:05-07:00")
-			args = append(args,
-				"-DateTimeOriginal="+ts,
-				"-CreateDate="+ts,
-			)
+			set("DateTimeOriginal", ts)
+			set("CreateDate", ts)
 			if isVideoExt(ext) {
-				args = append(args,
-					"-MediaCreateDate="+ts,
-					"-TrackCreateDate="+ts,
-				)
+				set("MediaCreateDate", ts)
+				set("TrackCreateDate", ts)
 			}
 		}
 	}
 	if meta.CreationTime != "" {
 		if t, err := time.Parse(time.RFC3339, meta.CreationTime); err == nil {
-			ts := t.Format("2006:01:02 15:04:05-07:00")
-			args = append(args, "-XMP:CreateDate="+ts)
+			set("XMP:CreateDate", t.Format("2006:01:02 15:04:05-07:00"))
+		}
+	}
+	fileDate := meta.ModifyTime
+	if tagPolicy.FileDateFromTaken && meta.TakenTime != "" {
+		fileDate = meta.TakenTime
+	}
+	if fileDate != "" {
+		if t, err := time.Parse(time.RFC3339, fileDate); err == nil {
+			set("FileModifyDate", t.Format("2006:01:02 15:04:05-07:00"))
 		}
 	}
 	if meta.HasGeo {
-		args = append(args,
-			fmt.Sprintf("-GPSLatitude=%f", meta.GPSLat),
-			fmt.Sprintf("-GPSLongitude=%f", meta.GPSLon),
-			fmt.Sprintf("-GPSAltitude=%f", meta.GPSAlt),
-		)
+		set("GPSLatitude", fmt.Sprintf("%f", meta.GPSLat))
+		set("GPSLongitude", fmt.Sprintf("%f", meta.GPSLon))
+		set("GPSAltitude", fmt.Sprintf("%f", meta.GPSAlt))
+		// Google gives approximate locations as an area; keep it, and turn
+		// it into a radius for viewers that understand positioning errors.
+		if meta.GPSSpanLat > 0 || meta.GPSSpanLon > 0 {
+			set("XMP-gphotos:LatitudeSpan", fmt.Sprintf("%f", meta.GPSSpanLat))
+			set("XMP-gphotos:LongitudeSpan", fmt.Sprintf("%f", meta.GPSSpanLon))
+			set("GPSHPositioningError", fmt.Sprintf("%.0f", spanRadius(meta.GPSLat, meta.GPSSpanLat, meta.GPSSpanLon)))
+		}
+	}
+	if tagPolicy.IPTC && iptcExt[ext] && hasIPTCValues(meta) {
+		// IPTC has no default encoding; without this, readers take
+		// non-ASCII descriptions and names for Latin-1.
+		set("IPTC:CodedCharacterSet", "UTF8")
 	}
 	if meta.Description != "" {
-		args = append(args,
-			"-ImageDescription="+meta.Description,
-			"-XMP:Description="+meta.Description,
-		)
+		set("ImageDescription", meta.Description)
+		set("XMP:Description", meta.Description)
+		if tagPolicy.IPTC && iptcExt[ext] {
+			set("IPTC:Caption-Abstract", meta.Description)
+		}
 	}
 	if meta.Favorited {
-		args = append(args, "-XMP:Rating=5")
+		set("XMP:Rating", "5")
 	}
 	for _, name := range meta.People {
 		if strings.TrimSpace(name) == "" {
 			continue
 		}
-		args = append(args,
-			"-XMP:PersonInImage+="+name,
-			"-XMP:Subject+="+name,
+		tags = append(tags,
+			tagValue{tag: "XMP:PersonInImage", value: name, add: true},
+			tagValue{tag: "XMP:Subject", value: name, add: true},
 		)
+		if tagPolicy.IPTC && iptcExt[ext] {
+			tags = append(tags, tagValue{tag: "IPTC:Keywords", value: name, add: true})
+		}
 	}
 	if meta.URL != "" {
-		args = append(args, "-XMP:Source="+meta.URL)
+		set("XMP:Source", meta.URL)
+		set("XMP-gphotos:URL", meta.URL)
 	}
 	if meta.AppSource != "" {
-		args = append(args, "-XMP:CreatorTool="+meta.AppSource)
+		set("XMP:CreatorTool", meta.AppSource)
 	}
 	if label := buildOriginLabel(meta.Origin); label != "" {
-		args = append(args, "-XMP:Label="+label)
+		set("XMP:Label", label)
 	}
-	if len(args) == 0 {
-		return nil, false
+	if meta.SourceJSON != "" {
+		set("XMP-gphotos:SourceJSON", meta.SourceJSON)
 	}
-	args = append(args, path)
-	return args, true
+	if meta.SourcePath != "" {
+		set("XMP-gphotos:SourcePath", meta.SourcePath)
+	}
+	return tags
+}
+
+// hasIPTCValues reports whether buildTags writes any IPTC tag for meta.
+func hasIPTCValues(meta models.MetaData) bool {
+	if meta.Description != "" {
+		return true
+	}
+	for _, name := range meta.People {
+		if strings.TrimSpace(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// metersPerDegree is the length of one degree of latitude.
+const metersPerDegree = 111320
+
+// spanRadius approximates the area of a latitude/longitude span at lat as
+// the radius in meters of the circle around its corners.
+func spanRadius(lat, spanLat, spanLon float64) float64 {
+	h := spanLat * metersPerDegree
+	w := spanLon * metersPerDegree * math.Cos(lat*math.Pi/180)
+	return math.Hypot(h, w) / 2
 }
 
 func matchesExtension(path string, ext string) bool {
@@ -241,11 +412,18 @@ func sniffFileKind(path string) (string, bool) {
 		buf[4] == 0x0D && buf[5] == 0x0A && buf[6] == 0x1A && buf[7] == 0x0A {
 		return "png", true
 	}
+	if string(buf[0:6]) == "GIF87a" || string(buf[0:6]) == "GIF89a" {
+		return "gif", true
+	}
 	if string(buf[4:8]) == "ftyp" {
 		brand := string(buf[8:12])
 		switch brand {
 		case "heic", "heix", "heif", "hevc", "heim", "heis":
 			return "heic", true
+		case "qt  ":
+			return "mov", true
+		case "isom", "iso2", "mp41", "mp42", "avc1", "M4V ", "dash", "3gp4", "3gp5", "3g2a":
+			return "mp4", true
 		}
 	}
 	if string(buf[0:4]) == "RIFF" && string(buf[8:12]) == "WEBP" {
@@ -270,76 +448,32 @@ func PreferredExtension(kind string) string {
 		return ".heic"
 	case "webp":
 		return ".webp"
+	case "gif":
+		return ".gif"
+	case "mp4":
+		return ".mp4"
+	case "mov":
+		return ".mov"
 	default:
 		return ""
 	}
 }
 
-// StartBatchWriter launches a persistent exiftool process for fast batched writes.
-func StartBatchWriter() (*BatchWriter, error) {
-	if !hasExiftool() {
-		return nil, fmt.Errorf("exiftool not available")
-	}
-	cmd := exec.Command("exiftool", "-stay_open", "True", "-common_args", "-overwrite_original", "-q", "-q", "-m", "-@", "-")
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, err
-	}
-	// Drain output to avoid blocking.
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
-	}
-	if err := cmd.Start(); err != nil {
-		return nil, err
-	}
-	go io.Copy(io.Discard, stdout)
-	go io.Copy(io.Discard, stderr)
-	return &BatchWriter{cmd: cmd, stdin: stdin}, nil
-}
-
-// Write sends a batch of metadata updates to the persistent exiftool process.
-func (w *BatchWriter) Write(items []WriteItem) error {
-	if w == nil || w.stdin == nil {
-		return nil
-	}
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	for _, item := range items {
-		if item.Path == "" || !HasWritableMeta(item.Meta) {
-			continue
-		}
-		args, ok := buildArgsForMeta(item.Path, item.Meta)
-		if !ok {
-			continue
-		}
-		for _, a := range args {
-			if _, err := fmt.Fprintln(w.stdin, a); err != nil {
-				return err
-			}
-		}
-		if _, err := fmt.Fprintln(w.stdin, "-execute"); err != nil {
-			return err
+// ExtensionFits reports whether ext is acceptable for a file of the given
+// kind. Images must use their canonical extension; videos keep any of the
+// container's usual extensions (Pixel .MP motion clips, .m4v, ...).
+func ExtensionFits(kind, ext string) bool {
+	ext = strings.ToLower(ext)
+	switch kind {
+	case "mp4", "mov":
+		switch ext {
+		case ".mp4", ".m4v", ".mov", ".qt", ".3gp", ".mp", ".mv", ".mp~2", ".mp~3":
+			return true
 		}
+		return false
 	}
-	return nil
-}
-
-// Close shuts down the persistent exiftool process.
-func (w *BatchWriter) Close() error {
-	if w == nil || w.stdin == nil {
-		return nil
-	}
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	_, _ = fmt.Fprintln(w.stdin, "-stay_open")
-	_, _ = fmt.Fprintln(w.stdin, "False")
-	_ = w.stdin.Close()
-	return w.cmd.Wait()
+	pref := PreferredExtension(kind)
+	return pref == "" || pref == ext
 }
 
 func buildOriginLabel(origin models.GooglePhotosOrigin) string {
@@ -347,6 +481,9 @@ func buildOriginLabel(origin models.GooglePhotosOrigin) string {
 	if origin.FromSharedAlbum {
 		parts = append(parts, "fromSharedAlbum")
 	}
+	if origin.FromPartnerSharing {
+		parts = append(parts, "fromPartnerSharing")
+	}
 	if origin.WebUpload {
 		parts = append(parts, "webUpload")
 	}
@@ -0,0 +1,156 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gphotos/core/models"
+)
+
+// tricky holds values that exiftool's argument and import formats treat
+// specially.
+var tricky = []struct {
+	name  string
+	value string
+}{
+	{"plain", "Holiday at the lake"},
+	{"newline", "first line\nsecond line"},
+	{"carriage return", "first line\r\nsecond line"},
+	{"tab", "a\tb"},
+	{"equals", "a=b=c"},
+	{"leading dash", "-overwrite_original"},
+	{"leading and trailing spaces", "  padded  "},
+	{"entity", "Fish &amp; Chips"},
+	{"ampersand", "Fish & Chips"},
+	{"base64 prefix", "base64:SGVsbG8="},
+	{"non-ASCII", "Zoë, Łukasz, 東京, 🎉"},
+	{"empty", ""},
+}
+
+// decodeJSONValue undoes jsonValue the way exiftool reads an import file.
+func decodeJSONValue(t *testing.T, v string) string {
+	t.Helper()
+	if !strings.HasPrefix(v, "base64:") {
+		return v
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, "base64:"))
+	if err != nil {
+		t.Fatalf("decode %q: %v", v, err)
+	}
+	return string(data)
+}
+
+func TestJSONValue(t *testing.T) {
+	for _, tc := range tricky {
+		t.Run(tc.name, func(t *testing.T) {
+			got := jsonValue(tc.value)
+			if back := decodeJSONValue(t, got); back != tc.value {
+				t.Errorf("jsonValue(%q) = %q, decodes to %q", tc.value, got, back)
+			}
+			if !strings.HasPrefix(tc.value, "base64:") && got != tc.value {
+				t.Errorf("jsonValue(%q) = %q, want it unchanged", tc.value, got)
+			}
+		})
+	}
+}
+
+func TestJSONEntryRoundTrip(t *testing.T) {
+	for _, tc := range tricky {
+		t.Run(tc.name, func(t *testing.T) {
+			tags := []tagValue{
+				{tag: "XMP-dc:Description", value: tc.value},
+				{tag: "XMP-dc:Subject", value: tc.value, add: true},
+				{tag: "XMP-dc:Subject", value: "second", add: true},
+			}
+			data, err := json.Marshal([]map[string]any{jsonEntry("IMG_0001.jpg", tags)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var entries []map[string]any
+			if err := json.Unmarshal(data, &entries); err != nil {
+				t.Fatal(err)
+			}
+			entry := entries[0]
+			if entry["SourceFile"] != "IMG_0001.jpg" {
+				t.Errorf("SourceFile = %v", entry["SourceFile"])
+			}
+			desc, _ := entry["XMP-dc:Description"].(string)
+			if back := decodeJSONValue(t, desc); back != tc.value {
+				t.Errorf("Description round-trips to %q, want %q", back, tc.value)
+			}
+			list, _ := entry["XMP-dc:Subject"].([]any)
+			if len(list) != 2 {
+				t.Fatalf("Subject = %v, want two values", entry["XMP-dc:Subject"])
+			}
+			first, _ := list[0].(string)
+			if back := decodeJSONValue(t, first); back != tc.value {
+				t.Errorf("Subject[0] round-trips to %q, want %q", back, tc.value)
+			}
+			if list[1] != "second" {
+				t.Errorf("Subject[1] = %v, want second", list[1])
+			}
+		})
+	}
+}
+
+func TestTagArgs(t *testing.T) {
+	for _, tc := range tricky {
+		t.Run(tc.name, func(t *testing.T) {
+			args := tagArgs([]tagValue{
+				{tag: "XMP-dc:Title", value: tc.value},
+				{tag: "XMP-dc:Subject", value: tc.value, add: true},
+			})
+			want := []string{"-XMP-dc:Title=" + tc.value, "-XMP-dc:Subject+=" + tc.value}
+			if len(args) != len(want) {
+				t.Fatalf("tagArgs = %q, want %q", args, want)
+			}
+			for i := range want {
+				if args[i] != want[i] {
+					t.Errorf("arg %d = %q, want %q", i, args[i], want[i])
+				}
+			}
+			// exiftool splits an assignment at the first "=", so the
+			// value comes back whole even when it holds "=" itself.
+			for _, arg := range args {
+				_, value, ok := strings.Cut(arg, "=")
+				if !ok || value != tc.value {
+					t.Errorf("%q splits to value %q, want %q", arg, value, tc.value)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildTagsIPTCCharset(t *testing.T) {
+	defer SetTagPolicy(TagPolicy{})
+	tests := []struct {
+		name string
+		iptc bool
+		ext  string
+		meta models.MetaData
+		want bool
+	}{
+		{"description", true, ".jpg", models.MetaData{Description: "Zoë"}, true},
+		{"people", true, ".tif", models.MetaData{People: []string{"Łukasz"}}, true},
+		{"blank people", true, ".jpg", models.MetaData{People: []string{" "}}, false},
+		{"nothing for IPTC", true, ".jpg", models.MetaData{Favorited: true}, false},
+		{"no IPTC support", true, ".png", models.MetaData{Description: "Zoë"}, false},
+		{"IPTC off", false, ".jpg", models.MetaData{Description: "Zoë"}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			SetTagPolicy(TagPolicy{IPTC: tc.iptc})
+			got := false
+			for _, tag := range buildTags(tc.ext, tc.meta) {
+				if tag.tag == "IPTC:CodedCharacterSet" {
+					got = tag.value == "UTF8"
+				}
+			}
+			if got != tc.want {
+				t.Errorf("IPTC:CodedCharacterSet=UTF8 written: %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
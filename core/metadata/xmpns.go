@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"os"
+	"sync"
+)
+
+// gphotosConfig defines the XMP-gphotos namespace so provenance fields such
+// as SourceJSON, SourcePath and URL, and the span of approximate locations,
+// can be written by exiftool.
+const gphotosConfig = `%Image::ExifTool::UserDefined = (
+    'Image::ExifTool::XMP::Main' => {
+        gphotos => {
+            SubDirectory => {
+                TagTable => 'Image::ExifTool::UserDefined::gphotos',
+            },
+        },
+    },
+);
+%Image::ExifTool::UserDefined::gphotos = (
+    GROUPS => { 0 => 'XMP', 1 => 'XMP-gphotos', 2 => 'Image' },
+    NAMESPACE => { 'gphotos' => 'https://github.com/Navknight/gphotos/ns/1.0/' },
+    WRITABLE => 'string',
+    SourceJSON => { },
+    SourcePath => { },
+    URL => { },
+    LatitudeSpan => { Writable => 'real' },
+    LongitudeSpan => { Writable => 'real' },
+);
+1;
+`
+
+var (
+	configOnce sync.Once
+	configPath string
+)
+
+// exiftoolConfigArgs returns the leading -config arguments that register the
+// XMP-gphotos namespace. exiftool requires -config to come first. The config
+// is written once to a private temp file, removed by RemoveConfig.
+func exiftoolConfigArgs() []string {
+	configOnce.Do(func() {
+		f, err := os.CreateTemp("", "gphotos-exiftool-*.config")
+		if err != nil {
+			return
+		}
+		_, err = f.WriteString(gphotosConfig)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return
+		}
+		configPath = f.Name()
+	})
+	if configPath == "" {
+		return nil
+	}
+	return []string{"-config", configPath}
+}
+
+// RemoveConfig deletes the exiftool config written by this process, if
+// any. Call it once no exiftool process is running.
+func RemoveConfig() {
+	// Spend the Once so a later write cannot recreate the file.
+	configOnce.Do(func() {})
+	if configPath != "" {
+		os.Remove(configPath)
+		configPath = ""
+	}
+}
@@ -15,10 +15,26 @@ type MetaData struct {
 	URL          string
 	AppSource    string
 	Origin       GooglePhotosOrigin
+	SourceJSON   string
+	SourcePath   string // media path relative to the Takeout root
+	Width        int
+	Height       int
+	Duration     float64 // seconds, videos only
+	CameraMake   string
+	CameraModel  string
+	ModifyTime   string // photoLastModifiedTime, written as FileModifyDate
+	Archived     bool
+	Trashed      bool
+	// HasArchived and HasTrashed are set when the export states the
+	// archived and trashed flags; older Takeout JSON has neither.
+	HasArchived bool
+	HasTrashed  bool
+	ImageViews  int
 }
 
 type GooglePhotosOrigin struct {
 	FromSharedAlbum          bool
+	FromPartnerSharing       bool
 	WebUpload                bool
 	MobileUpload             bool
 	MobileUploadDeviceType   string
@@ -26,14 +42,33 @@ type GooglePhotosOrigin struct {
 	CompositionType          string
 }
 
+// SourceCopy is one place an identical file was found in the Takeout.
+type SourceCopy struct {
+	MediaPath string
+	JsonPath  string
+	Album     string // empty for "Photos from YYYY" folders
+}
+
 type Photo struct {
-	Hash         string
-	HashError    bool
-	SrcPath      string
-	JsonPath     string
-	Meta         MetaData
-	Albums       map[string]bool
-	FinalAlbum   string
-	DateAccuracy int
-	Size         int64
+	Hash      string
+	HashError bool
+	SrcPath   string
+	JsonPath  string
+	// Imported is metadata an importer read from a non-JSON export, used
+	// in place of a JSON sidecar.
+	Imported   *MetaData
+	Meta       MetaData
+	Albums     map[string]bool
+	FinalAlbum string
+	Route      string
+	DstPath    string
+	DstName    string // output file name; empty keeps the source name
+	// MotionVideo is the output path of the video extracted from a motion
+	// photo; MotionStripped is set when the still no longer carries it.
+	MotionVideo    string
+	MotionStripped bool
+	DateAccuracy   int
+	Size           int64
+	// Copies lists every source location of this content, in scan order.
+	Copies []SourceCopy
 }
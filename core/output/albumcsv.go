@@ -0,0 +1,96 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+const albumCSVName = "album.csv"
+
+// WriteAlbumCSV writes an album.csv into every Albums/<name>/ folder listing
+// each file's name, taken date, people, and description, for readers without
+// metadata-aware software. Photos must already have DstPath set by
+// OrganizePhotos.
+func WriteAlbumCSV(photos []*models.Photo, dryRun bool) (int, error) {
+	byDir := make(map[string][]*models.Photo)
+	for _, p := range photos {
+		if p == nil || p.DstPath == "" || strings.TrimSpace(p.FinalAlbum) == "" || strings.TrimSpace(p.Route) != "" {
+			continue
+		}
+		dir := filepath.Dir(p.DstPath)
+		byDir[dir] = append(byDir[dir], p)
+	}
+
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	written := 0
+	for _, dir := range dirs {
+		group := byDir[dir]
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].Meta.TakenTime != group[j].Meta.TakenTime {
+				return group[i].Meta.TakenTime < group[j].Meta.TakenTime
+			}
+			return group[i].DstPath < group[j].DstPath
+		})
+
+		var buf bytes.Buffer
+		// The byte order mark makes Excel read the file as UTF-8.
+		buf.WriteString("\ufeff")
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"filename", "taken", "people", "description"})
+		for _, p := range group {
+			_ = w.Write([]string{
+				csvCell(filepath.Base(p.DstPath)),
+				csvTaken(p.Meta.TakenTime),
+				csvCell(strings.Join(p.Meta.People, "; ")),
+				csvCell(strings.TrimSpace(p.Meta.Description)),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return written, err
+		}
+		path := filepath.Join(dir, albumCSVName)
+		if dryRun {
+			fmt.Printf("DRY RUN: write %s\n", path)
+			written++
+			continue
+		}
+		if err := fsutil.WriteFile(path, buf.Bytes()); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// csvCell keeps spreadsheets from running a cell as a formula: text that
+// starts with "=", "+", "-" or "@" (or a tab or carriage return, which
+// some apps skip) gets a leading "'".
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvTaken formats an RFC3339 taken date for people rather than programs.
+func csvTaken(taken string) string {
+	t, err := time.Parse(time.RFC3339, taken)
+	if err != nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
@@ -0,0 +1,52 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+// LinkAlbums fills Albums/<Name>/ with links to the files in Library/, one
+// per album membership, so every album is browsable without storing a file
+// twice. mode is "symlink" or "hardlink". Photos must already have DstPath
+// set by OrganizePhotos.
+func LinkAlbums(photos []*models.Photo, outRoot string, mode string, dryRun bool) (int, error) {
+	if mode != "symlink" && mode != "hardlink" {
+		return 0, fmt.Errorf("unknown link mode: %s", mode)
+	}
+	root := filepath.Join(outRoot, albumsFolder)
+	linked := 0
+	for _, p := range photos {
+		if p == nil || p.DstPath == "" || len(p.Albums) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(p.Albums))
+		for name, ok := range p.Albums {
+			if ok && strings.TrimSpace(name) != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			dir := filepath.Join(root, sanitizeFolder(name))
+			if dryRun {
+				fmt.Printf("DRY RUN: link %s -> %s\n", filepath.Join(dir, filepath.Base(p.DstPath)), p.DstPath)
+				linked++
+				continue
+			}
+			if err := fsutil.MkdirAll(dir); err != nil {
+				return linked, err
+			}
+			if done, err := placeLink(p.DstPath, dir, p.Hash, mode); err != nil {
+				return linked, err
+			} else if done {
+				linked++
+			}
+		}
+	}
+	return linked, nil
+}
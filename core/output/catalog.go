@@ -0,0 +1,168 @@
+package output
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+const catalogFile = "catalog.json"
+
+// CatalogEntry traces one output file back to where it came from.
+type CatalogEntry struct {
+	Path   string   `json:"path"`             // relative to the output root
+	Source string   `json:"source"`           // relative to the Takeout root
+	JSON   string   `json:"json,omitempty"`   // relative to the Takeout root
+	URL    string   `json:"url,omitempty"`    // Google Photos URL
+	Hash   string   `json:"hash,omitempty"`   // source content hash
+	Taken  string   `json:"taken,omitempty"`  // RFC3339
+	Albums []string `json:"albums,omitempty"` // every album the content was in
+	People []string `json:"people,omitempty"` // people tagged in Google Photos
+	GPS    bool     `json:"gps,omitempty"`    // location known
+	// MotionVideo is the video extracted from a motion photo, relative to
+	// the output root; MotionStripped records that the output still no
+	// longer embeds it.
+	MotionVideo    string `json:"motion_video,omitempty"`
+	MotionStripped bool   `json:"motion_stripped,omitempty"`
+}
+
+// RecordCatalog adds every copied photo to <outRoot>/.gphotos/catalog.json.
+// Entries from earlier runs are kept unless the same output path is
+// written again.
+func RecordCatalog(outRoot, inRoot string, photos []*models.Photo) error {
+	entries, err := LoadCatalog(outRoot)
+	if err != nil {
+		return err
+	}
+	byPath := make(map[string]CatalogEntry, len(entries))
+	for _, e := range entries {
+		byPath[e.Path] = e
+	}
+	for _, p := range photos {
+		if p == nil || p.DstPath == "" {
+			continue
+		}
+		rel, err := filepath.Rel(outRoot, p.DstPath)
+		if err != nil {
+			continue
+		}
+		entry := CatalogEntry{
+			Path:   filepath.ToSlash(rel),
+			Source: p.Meta.SourcePath,
+			URL:    p.Meta.URL,
+			Hash:   p.Hash,
+			Taken:  p.Meta.TakenTime,
+			People: p.Meta.People,
+			GPS:    p.Meta.HasGeo,
+		}
+		if entry.Source == "" {
+			entry.Source = relSlash(inRoot, p.SrcPath)
+		}
+		if p.JsonPath != "" {
+			entry.JSON = relSlash(inRoot, p.JsonPath)
+		}
+		if p.MotionVideo != "" {
+			entry.MotionVideo = relSlash(outRoot, p.MotionVideo)
+		}
+		entry.MotionStripped = p.MotionStripped
+		for album := range p.Albums {
+			entry.Albums = append(entry.Albums, album)
+		}
+		sort.Strings(entry.Albums)
+		byPath[entry.Path] = entry
+	}
+
+	entries = entries[:0]
+	for _, e := range byPath {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	path := filepath.Join(outRoot, ".gphotos", catalogFile)
+	if err := fsutil.MkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(path, data)
+}
+
+// LoadCatalog reads the catalog of an output root; a missing catalog is
+// empty.
+func LoadCatalog(outRoot string) ([]CatalogEntry, error) {
+	data, err := os.ReadFile(filepath.Join(outRoot, ".gphotos", catalogFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []CatalogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// CatalogQuery filters catalog entries; empty fields match everything.
+type CatalogQuery struct {
+	Person string // case-insensitive name
+	Album  string // case-insensitive glob, e.g. "Vacation*"
+	Year   string
+	// GPS selects entries with ("with") or without ("without") a location.
+	GPS string
+}
+
+// Match reports whether e passes every filter of q.
+func (q CatalogQuery) Match(e CatalogEntry) bool {
+	if q.Person != "" && !anyMatch(e.People, func(name string) bool { return strings.EqualFold(name, q.Person) }) {
+		return false
+	}
+	if q.Album != "" {
+		pattern := strings.ToLower(q.Album)
+		if !anyMatch(e.Albums, func(album string) bool {
+			ok, _ := path.Match(pattern, strings.ToLower(album))
+			return ok
+		}) {
+			return false
+		}
+	}
+	if q.Year != "" && !strings.HasPrefix(e.Taken, q.Year+"-") {
+		return false
+	}
+	switch q.GPS {
+	case "with":
+		return e.GPS
+	case "without":
+		return !e.GPS
+	}
+	return true
+}
+
+func anyMatch(values []string, match func(string) bool) bool {
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// relSlash returns path relative to root with forward slashes, or path
+// unchanged when it is not below root.
+func relSlash(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
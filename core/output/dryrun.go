@@ -0,0 +1,70 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"gphotos/core/metadata"
+)
+
+// Values of Options.DryRunMeta.
+const (
+	DryRunMetaSummary = "" // count the planned writes per tag
+	DryRunMetaList    = "list"
+	DryRunMetaOff     = "off"
+)
+
+// dryRunValueLimit keeps long values such as an embedded source JSON from
+// flooding a -dry-run listing.
+const dryRunValueLimit = 80
+
+// tagPreview collects the metadata writes a dry run would have made.
+type tagPreview struct {
+	mode   string
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newTagPreview(mode string) *tagPreview {
+	return &tagPreview{mode: mode, counts: make(map[string]int)}
+}
+
+// add records the writes planned for item and, in list mode, prints them
+// below the file's DRY RUN line.
+func (t *tagPreview) add(item metadata.WriteItem) {
+	if t.mode == DryRunMetaOff {
+		return
+	}
+	tags, ok := metadata.PlannedTags(item)
+	if !ok {
+		return
+	}
+	var b strings.Builder
+	target := item.Path
+	if item.Sidecar {
+		target = metadata.SidecarPath(item.Path)
+	}
+	fmt.Fprintf(&b, "DRY RUN: tag %s\n", target)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tag := range tags {
+		name, _, _ := strings.Cut(tag, "=")
+		t.counts[strings.TrimSuffix(name, "+")]++
+		if len(tag) > dryRunValueLimit {
+			tag = tag[:dryRunValueLimit] + "..."
+		}
+		fmt.Fprintf(&b, "  %s\n", strings.ReplaceAll(tag, "\n", `\n`))
+	}
+	if t.mode == DryRunMetaList {
+		fmt.Print(b.String())
+	}
+}
+
+// Counts returns the number of planned writes per tag.
+func (t *tagPreview) Counts() map[string]int {
+	if t == nil || len(t.counts) == 0 {
+		return nil
+	}
+	return t.counts
+}
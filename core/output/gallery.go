@@ -0,0 +1,328 @@
+package output
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/metadata"
+)
+
+const (
+	galleryFolder = "gallery"
+	thumbsFolder  = "thumbs"
+)
+
+// GalleryStats summarizes what WriteGallery produced.
+type GalleryStats struct {
+	Pages  int
+	Thumbs int
+	// NoThumb counts files shown as a placeholder because their format
+	// (HEIC, WebP, video, ...) cannot be decoded without extra tools.
+	NoThumb int
+}
+
+type galleryItem struct {
+	Href  string
+	Thumb string
+	Label string
+	Date  string
+	taken time.Time
+}
+
+type galleryPage struct {
+	Title string
+	File  string
+	Items []galleryItem
+}
+
+// WriteGallery builds a static HTML gallery of the catalogued files under
+// <outRoot>/gallery: index.html links to one page per year and per album,
+// each a grid of thumbnails with dates. Thumbnails that already exist are
+// reused, so re-running after another import only adds the new ones.
+func WriteGallery(outRoot string, thumbSize int, progress func(done, total int)) (GalleryStats, error) {
+	var stats GalleryStats
+	entries, err := LoadCatalog(outRoot)
+	if err != nil {
+		return stats, err
+	}
+	if len(entries) == 0 {
+		return stats, fmt.Errorf("no catalog in %s; run gphotos into this folder first", outRoot)
+	}
+	dir := filepath.Join(outRoot, galleryFolder)
+	if err := fsutil.MkdirAll(filepath.Join(dir, thumbsFolder)); err != nil {
+		return stats, err
+	}
+
+	years := make(map[string]*galleryPage)
+	albums := make(map[string]*galleryPage)
+	for i, e := range entries {
+		if progress != nil {
+			progress(i+1, len(entries))
+		}
+		src := filepath.Join(outRoot, filepath.FromSlash(e.Path))
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		item := galleryItem{Href: "../" + escapePath(e.Path), Label: path.Base(e.Path)}
+		year := "Unknown date"
+		if t, err := time.Parse(time.RFC3339, e.Taken); err == nil {
+			item.taken = t
+			item.Date = t.Format("2006-01-02 15:04")
+			year = t.Format("2006")
+		}
+		thumb, err := galleryThumb(src, dir, thumbSize)
+		switch {
+		case err != nil:
+			return stats, err
+		case thumb == "":
+			stats.NoThumb++
+		default:
+			stats.Thumbs++
+			item.Thumb = thumbsFolder + "/" + thumb
+		}
+
+		addGalleryItem(years, year, "year-", item)
+		for _, album := range e.Albums {
+			addGalleryItem(albums, album, "album-", item)
+		}
+	}
+
+	yearPages := sortedPages(years)
+	albumPages := sortedPages(albums)
+	for _, pages := range [][]*galleryPage{yearPages, albumPages} {
+		for _, page := range pages {
+			sort.SliceStable(page.Items, func(i, j int) bool {
+				return page.Items[i].taken.Before(page.Items[j].taken)
+			})
+			if err := renderGalleryPage(filepath.Join(dir, page.File), galleryPageTemplate, page); err != nil {
+				return stats, err
+			}
+			stats.Pages++
+		}
+	}
+	index := struct {
+		Files  int
+		Years  []*galleryPage
+		Albums []*galleryPage
+	}{len(entries), yearPages, albumPages}
+	if err := renderGalleryPage(filepath.Join(dir, "index.html"), galleryIndexTemplate, index); err != nil {
+		return stats, err
+	}
+	stats.Pages++
+	return stats, nil
+}
+
+func addGalleryItem(pages map[string]*galleryPage, title, prefix string, item galleryItem) {
+	page := pages[title]
+	if page == nil {
+		sum := sha256.Sum256([]byte(title))
+		page = &galleryPage{Title: title, File: prefix + hex.EncodeToString(sum[:6]) + ".html"}
+		pages[title] = page
+	}
+	page.Items = append(page.Items, item)
+}
+
+func sortedPages(pages map[string]*galleryPage) []*galleryPage {
+	out := make([]*galleryPage, 0, len(pages))
+	for _, page := range pages {
+		out = append(out, page)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Title < out[j].Title
+	})
+	return out
+}
+
+// escapePath URL-escapes each segment of a slash-separated relative path.
+func escapePath(rel string) string {
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// galleryThumb writes a JPEG thumbnail of src into <dir>/thumbs and returns
+// its file name, or "" when src cannot be decoded.
+func galleryThumb(src, dir string, size int) (string, error) {
+	kind, _ := metadata.DetectFileKind(src)
+	switch kind {
+	case "jpeg", "png", "gif":
+	default:
+		return "", nil
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", nil
+	}
+	// The trailing field keeps thumbnails made before orientation was
+	// applied from being reused.
+	key := fmt.Sprintf("%s|%d|%d|%d|oriented", src, info.Size(), info.ModTime().UnixNano(), size)
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:10]) + ".jpg"
+	dst := filepath.Join(dir, thumbsFolder, name)
+	if _, err := os.Stat(dst); err == nil {
+		return name, nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return "", nil
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return "", nil
+	}
+	thumb := scaleDown(img, size)
+	if kind == "jpeg" {
+		thumb = orient(thumb, metadata.ImageOrientation(src))
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return "", err
+	}
+	return name, fsutil.WriteFile(dst, buf.Bytes())
+}
+
+// thumbSamples is how many source pixels per axis scaleDown averages for
+// each thumbnail pixel; reading every pixel of a 12 MP photo through
+// image.Image is too slow.
+const thumbSamples = 4
+
+// scaleDown shrinks img to fit within size x size by averaging a grid of
+// samples from each block of source pixels. Images that already fit are
+// returned unchanged.
+func scaleDown(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+	out := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		ystep := max(1, (y1-y0)/thumbSamples)
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			xstep := max(1, (x1-x0)/thumbSamples)
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy += ystep {
+				for sx := x0; sx < x1; sx += xstep {
+					cr, cg, cb, _ := img.At(sx, sy).RGBA()
+					r, g, bl = r+uint64(cr), g+uint64(cg), bl+uint64(cb)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			out.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: 0xffff})
+		}
+	}
+	return out
+}
+
+// orient turns img the way the EXIF Orientation o says it should be shown.
+func orient(img image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // flipped
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // needs 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			out.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return out
+}
+
+func renderGalleryPage(dst string, tmpl *template.Template, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	return fsutil.WriteFile(dst, buf.Bytes())
+}
+
+const galleryStyle = `<style>
+body { font-family: sans-serif; margin: 1.5em; background: #fafafa; color: #222; }
+a { color: #1a5fb4; text-decoration: none; }
+.grid { display: flex; flex-wrap: wrap; gap: 10px; }
+.tile { width: 200px; text-align: center; font-size: 12px; }
+.tile img, .tile .none { width: 200px; height: 200px; object-fit: cover; background: #ddd; display: block; }
+.tile .none { line-height: 200px; color: #666; }
+.tile span { display: block; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+</style>`
+
+var galleryIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Photo library</title>` + galleryStyle + `</head>
+<body>
+<h1>Photo library</h1>
+<p>{{.Files}} files</p>
+<h2>Years</h2>
+<ul>{{range .Years}}<li><a href="{{.File}}">{{.Title}}</a> ({{len .Items}})</li>{{end}}</ul>
+{{if .Albums}}<h2>Albums</h2>
+<ul>{{range .Albums}}<li><a href="{{.File}}">{{.Title}}</a> ({{len .Items}})</li>{{end}}</ul>{{end}}
+</body></html>
+`))
+
+var galleryPageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>` + galleryStyle + `</head>
+<body>
+<p><a href="index.html">&larr; All years and albums</a></p>
+<h1>{{.Title}}</h1>
+<div class="grid">
+{{range .Items}}<div class="tile"><a href="{{.Href}}">{{if .Thumb}}<img src="{{.Thumb}}" loading="lazy" alt="{{.Label}}">{{else}}<span class="none">{{.Label}}</span>{{end}}</a><span>{{.Label}}</span><span>{{if .Date}}{{.Date}}{{else}}no date{{end}}</span></div>
+{{end}}</div>
+</body></html>
+`))
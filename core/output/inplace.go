@@ -0,0 +1,82 @@
+package output
+
+import (
+	"fmt"
+
+	"gphotos/core/metadata"
+	"gphotos/core/models"
+)
+
+// TagStats summarizes what TagInPlace did.
+type TagStats struct {
+	Tagged           int
+	Sidecars         int
+	MetadataFailures int
+	MetadataReport   []metadata.WriteFailure
+	// Exported counts the commands written to Options.ArgFile.
+	Exported int
+	// PlannedTags counts, on a dry run, the files each tag would be written
+	// to.
+	PlannedTags map[string]int
+}
+
+// TagInPlace writes each photo's metadata straight into its source file
+// without copying anything. Files exiftool cannot safely modify (unsupported
+// or mislabelled formats) get an XMP sidecar next to them instead.
+func TagInPlace(photos []*models.Photo, opts Options, progress func(done, total int)) (TagStats, error) {
+	var stats TagStats
+	var meta *metaWriters
+	switch {
+	case opts.DryRun:
+	case opts.ArgFile != "":
+		var err error
+		if meta, err = startArgFileWriter(opts.ArgFile, "", opts.ExifBatch*2); err != nil {
+			return stats, err
+		}
+	case !metadata.CanWriteMeta():
+		return stats, fmt.Errorf("exiftool not available")
+	default:
+		meta = startMetaWriters(opts.ExifWorkers, opts.ExifBatch, opts.ExifBatch*2, opts.Verbose)
+	}
+
+	var preview *tagPreview
+	if opts.DryRun {
+		preview = newTagPreview(opts.DryRunMeta)
+	}
+	total := len(photos)
+	for i, p := range photos {
+		if p != nil && p.SrcPath != "" && metadata.HasWritableMeta(p.Meta) {
+			sidecar := !metadata.CanWriteInPlace(p.SrcPath)
+			p.DstPath = p.SrcPath
+			if sidecar {
+				stats.Sidecars++
+			} else {
+				stats.Tagged++
+			}
+			item := metadata.WriteItem{Path: p.SrcPath, Meta: p.Meta, Sidecar: sidecar}
+			switch {
+			case opts.DryRun && opts.DryRunMeta == DryRunMetaList:
+				// The listing names the file itself.
+				preview.add(item)
+			case opts.DryRun && sidecar:
+				fmt.Printf("DRY RUN: tag %s\n", metadata.SidecarPath(p.SrcPath))
+				preview.add(item)
+			case opts.DryRun:
+				fmt.Printf("DRY RUN: tag %s\n", p.SrcPath)
+				preview.add(item)
+			default:
+				meta.Send(item)
+			}
+		}
+		if progress != nil {
+			progress(i+1, total)
+		}
+	}
+
+	if meta != nil {
+		stats.MetadataFailures, stats.MetadataReport = meta.Close()
+		stats.Exported = meta.Exported()
+	}
+	stats.PlannedTags = preview.Counts()
+	return stats, nil
+}
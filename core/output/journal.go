@@ -0,0 +1,150 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gphotos/core/metadata"
+)
+
+// JournalEntry records one rename done by a reorganize run.
+type JournalEntry struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Journal is an append-only log of renames. Each entry is synced before the
+// rename happens so an interrupted run can still be undone.
+type Journal struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenJournal opens (or creates) the journal at path for appending.
+func OpenJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{f: f}, nil
+}
+
+// Rename records the move in the journal and then performs it.
+func (j *Journal) Rename(from, to string) error {
+	line, err := json.Marshal(JournalEntry{From: from, To: to})
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		j.mu.Unlock()
+		return err
+	}
+	err = j.f.Sync()
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.f.Close()
+}
+
+// UndoJournal replays the journal at path backwards, moving every file back
+// to where it came from. Entries whose destination is gone or whose source
+// has been re-created are skipped and counted. Sidecars written next to a
+// moved file by older runs are deleted, as are the folders (Library/,
+// Albums/...) left empty below the Takeout root. On success the journal is
+// renamed to path+".undone" so it cannot be applied twice.
+func UndoJournal(path string, dryRun bool) (restored, skipped int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var entries []JournalEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e JournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// A torn final line from an interrupted run has no matching rename.
+			continue
+		}
+		entries = append(entries, e)
+	}
+	f.Close()
+	if err := sc.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	// The journal lives in <root>/.gphotos, and every destination is below
+	// root. Files the run moved are never mistaken for its sidecars.
+	root := filepath.Dir(filepath.Dir(path))
+	moved := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		moved[e.To] = true
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if _, err := os.Stat(e.To); err != nil {
+			skipped++
+			continue
+		}
+		if _, err := os.Stat(e.From); err == nil {
+			skipped++
+			continue
+		}
+		if dryRun {
+			fmt.Printf("DRY RUN: restore %s -> %s\n", e.To, e.From)
+			restored++
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(e.From), 0o755); err != nil {
+			return restored, skipped, err
+		}
+		if err := os.Rename(e.To, e.From); err != nil {
+			return restored, skipped, err
+		}
+		restored++
+		// NAME.txt is where older runs wrote the description.
+		legacyTxt := strings.TrimSuffix(e.To, filepath.Ext(e.To)) + ".txt"
+		for _, sidecar := range []string{metadata.SidecarPath(e.To), descriptionSidecarPath(e.To), legacyTxt, e.To + ".json"} {
+			if !moved[sidecar] {
+				os.Remove(sidecar)
+			}
+		}
+		removeEmptyParents(filepath.Dir(e.To), root)
+	}
+	if dryRun {
+		return restored, skipped, nil
+	}
+	return restored, skipped, os.Rename(path, path+".undone")
+}
+
+// removeEmptyParents removes dir and its parents while they are empty,
+// stopping below root.
+func removeEmptyParents(dir, root string) {
+	for {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,31 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gphotos/core/fsutil"
+)
+
+// LinkMode probes outRoot and returns the link mode album and people links
+// can use there. "symlink" falls back to "hardlink" where symlinks cannot be
+// made, such as Windows without Developer Mode; NTFS junctions only link
+// folders, so hardlinks are the fallback for files. reason is set when the
+// mode changed, and err when no link of either kind can be made.
+func LinkMode(outRoot, mode string) (effective string, reason error, err error) {
+	dir := filepath.Join(outRoot, ".gphotos")
+	if err := fsutil.MkdirAll(dir); err != nil {
+		return "", nil, err
+	}
+	symErr, hardErr := fsutil.ProbeLinks(dir)
+	switch {
+	case mode == "hardlink" && hardErr != nil:
+		return "", nil, fmt.Errorf("cannot hardlink in %s: %v", outRoot, hardErr)
+	case mode == "hardlink" || symErr == nil:
+		return mode, nil, nil
+	case hardErr == nil:
+		return "hardlink", symErr, nil
+	default:
+		return "", nil, fmt.Errorf("cannot symlink or hardlink in %s: %v", outRoot, symErr)
+	}
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gphotos/core/dedup"
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+const checksumFile = "SHA256SUMS"
+
+// WriteChecksums hashes every copied file and writes sha256sum-compatible
+// SHA256SUMS manifests. With perFolder, each output folder gets its own
+// manifest with bare file names; otherwise one manifest at outRoot lists
+// paths relative to it. It must run after metadata writes have finished,
+// since those rewrite the files.
+func WriteChecksums(photos []*models.Photo, outRoot string, perFolder bool, progress func(done, total int)) (int, error) {
+	manifests := make(map[string][]string)
+	total := len(photos)
+	hashed := 0
+	for i, p := range photos {
+		if p == nil || p.DstPath == "" {
+			continue
+		}
+		sum, err := dedup.HashFile(p.DstPath)
+		if err != nil {
+			return hashed, err
+		}
+		dir := outRoot
+		if perFolder {
+			dir = filepath.Dir(p.DstPath)
+		}
+		rel, err := filepath.Rel(dir, p.DstPath)
+		if err != nil {
+			return hashed, err
+		}
+		manifests[dir] = append(manifests[dir], fmt.Sprintf("%s  %s", sum, filepath.ToSlash(rel)))
+		hashed++
+		if progress != nil {
+			progress(i+1, total)
+		}
+	}
+
+	for dir, lines := range manifests {
+		sort.Slice(lines, func(i, j int) bool {
+			return lines[i][66:] < lines[j][66:]
+		})
+		data := strings.Join(lines, "\n") + "\n"
+		if err := fsutil.WriteFile(filepath.Join(dir, checksumFile), []byte(data)); err != nil {
+			return hashed, err
+		}
+	}
+	return hashed, nil
+}
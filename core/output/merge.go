@@ -0,0 +1,235 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gphotos/core/dedup"
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+	"gphotos/core/scanner"
+)
+
+// Library layouts recognized by IndexLibrary.
+const (
+	LayoutLibrary   = "library"    // Library/ and Albums/ as written by gphotos
+	LayoutYearMonth = "year-month" // {year}/{month}/
+	LayoutYear      = "year"       // {year}/
+)
+
+const libraryIndexFile = "library_index.json"
+
+var (
+	yearDirRe  = regexp.MustCompile(`^(19|20)\d\d$`)
+	monthDirRe = regexp.MustCompile(`^(0[1-9]|1[0-2])$`)
+	ymDirRe    = regexp.MustCompile(`^(19|20)\d\d-(0[1-9]|1[0-2])$`)
+)
+
+// LibraryIndex describes an existing organized library that new files are
+// merged into.
+type LibraryIndex struct {
+	Root   string
+	Layout string
+	// MonthStyle is "MM" or "YYYY-MM" for year-month layouts.
+	MonthStyle string
+	// Hashes holds the content hash of every media file in the library plus
+	// the source hashes recorded by earlier gphotos runs, since written files
+	// no longer hash like their Takeout originals.
+	Hashes map[string]string
+}
+
+// IndexLibrary detects the layout of an existing library and hashes its
+// media files.
+func IndexLibrary(root string, progress func(done, total int)) (*LibraryIndex, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	idx := &LibraryIndex{Root: root, Hashes: make(map[string]string)}
+	idx.Layout, idx.MonthStyle = detectLayout(root)
+
+	var files []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != root && (strings.HasPrefix(name, ".") || name == quarantineFolder) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink == 0 && scanner.IsMediaFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	for i, path := range files {
+		if sum, err := dedup.HashFile(path); err == nil {
+			idx.Hashes[sum] = path
+		}
+		if progress != nil {
+			progress(i+1, len(files))
+		}
+	}
+
+	recorded, err := loadLibraryIndex(root)
+	if err != nil {
+		return nil, err
+	}
+	for hash, rel := range recorded {
+		if _, ok := idx.Hashes[hash]; ok {
+			continue
+		}
+		path := filepath.Join(root, rel)
+		if _, err := os.Stat(path); err == nil {
+			idx.Hashes[hash] = path
+		}
+	}
+	return idx, nil
+}
+
+func detectLayout(root string) (string, string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return LayoutLibrary, ""
+	}
+	years := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if e.Name() == libraryFolder || e.Name() == albumsFolder {
+			return LayoutLibrary, ""
+		}
+		if yearDirRe.MatchString(e.Name()) {
+			years++
+		}
+	}
+	if years == 0 {
+		return LayoutLibrary, ""
+	}
+	mm, ym := 0, 0
+	for _, e := range entries {
+		if !e.IsDir() || !yearDirRe.MatchString(e.Name()) {
+			continue
+		}
+		subs, _ := os.ReadDir(filepath.Join(root, e.Name()))
+		for _, s := range subs {
+			switch {
+			case !s.IsDir():
+			case monthDirRe.MatchString(s.Name()):
+				mm++
+			case ymDirRe.MatchString(s.Name()):
+				ym++
+			}
+		}
+	}
+	switch {
+	case ym > mm:
+		return LayoutYearMonth, "YYYY-MM"
+	case mm > 0:
+		return LayoutYearMonth, "MM"
+	default:
+		return LayoutYear, ""
+	}
+}
+
+// Merge drops photos already present in the library and, for dated
+// layouts, routes the rest into the matching {year}/{month} folder. It
+// returns the photos to copy and the number skipped as already present.
+func (idx *LibraryIndex) Merge(photos []*models.Photo) ([]*models.Photo, int) {
+	out := make([]*models.Photo, 0, len(photos))
+	skipped := 0
+	for _, p := range photos {
+		if p == nil {
+			continue
+		}
+		if p.Hash == "" {
+			// -no-dedup skips hashing; a merge still needs it.
+			p.Hash, _ = dedup.HashFile(p.SrcPath)
+		}
+		if p.Hash != "" {
+			if _, ok := idx.Hashes[p.Hash]; ok {
+				skipped++
+				continue
+			}
+		}
+		if idx.Layout != LayoutLibrary && strings.TrimSpace(p.Route) == "" {
+			p.Route = idx.datedFolder(p.Meta.TakenTime)
+		}
+		out = append(out, p)
+	}
+	return out, skipped
+}
+
+func (idx *LibraryIndex) datedFolder(taken string) string {
+	t, err := time.Parse(time.RFC3339, taken)
+	if err != nil {
+		return "Undated"
+	}
+	year := t.Format("2006")
+	if idx.Layout == LayoutYear {
+		return year
+	}
+	if idx.MonthStyle == "YYYY-MM" {
+		return year + "/" + t.Format("2006-01")
+	}
+	return year + "/" + t.Format("01")
+}
+
+// RecordLibraryIndex remembers the source hash of every copied photo in
+// <outRoot>/.gphotos/library_index.json so a later -merge-into run can tell
+// which Takeout files are already in the library.
+func RecordLibraryIndex(outRoot string, photos []*models.Photo) error {
+	index, err := loadLibraryIndex(outRoot)
+	if err != nil {
+		return err
+	}
+	for _, p := range photos {
+		if p == nil || p.Hash == "" || p.DstPath == "" {
+			continue
+		}
+		rel, err := filepath.Rel(outRoot, p.DstPath)
+		if err != nil {
+			continue
+		}
+		index[p.Hash] = filepath.ToSlash(rel)
+	}
+	path := filepath.Join(outRoot, ".gphotos", libraryIndexFile)
+	if err := fsutil.MkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(path, data)
+}
+
+func loadLibraryIndex(root string) (map[string]string, error) {
+	index := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(root, ".gphotos", libraryIndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	for h, rel := range index {
+		index[h] = filepath.FromSlash(rel)
+	}
+	return index, nil
+}
@@ -0,0 +1,151 @@
+package output
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"gphotos/core/metadata"
+)
+
+// metaWriters fans metadata writes out to a pool of persistent exiftool
+// processes, each flushing in batches of batchSize.
+type metaWriters struct {
+	ch        chan metadata.WriteItem
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	attempted int64
+	failures  int64
+	report    []metadata.WriteFailure
+	// argfile is set when the writes go to an exiftool argfile instead of
+	// exiftool itself.
+	argfile *metadata.ArgFileWriter
+}
+
+func startMetaWriters(workers, batchSize, buffer int, verbose bool) *metaWriters {
+	if workers < 1 {
+		workers = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	m := &metaWriters{ch: make(chan metadata.WriteItem, buffer)}
+	m.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go m.run(batchSize, verbose)
+	}
+	return m
+}
+
+func (m *metaWriters) run(batchSize int, verbose bool) {
+	defer m.wg.Done()
+	writer, err := metadata.StartBatchWriter()
+	if err != nil {
+		if verbose {
+			fmt.Printf("Metadata writer unavailable: %v\n", err)
+		}
+		// Keep draining so producers never block on the channel.
+		for item := range m.ch {
+			if metadata.HasWritableMeta(item.Meta) {
+				atomic.AddInt64(&m.attempted, 1)
+				atomic.AddInt64(&m.failures, 1)
+			}
+		}
+		return
+	}
+	defer func() {
+		_ = writer.Close()
+		report := writer.Failures()
+		m.mu.Lock()
+		m.report = append(m.report, report...)
+		m.mu.Unlock()
+		for _, f := range report {
+			if f.Fatal {
+				atomic.AddInt64(&m.failures, 1)
+			}
+		}
+	}()
+
+	var batch []metadata.WriteItem
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := writer.Write(batch); err != nil {
+			atomic.AddInt64(&m.failures, int64(len(batch)))
+			if verbose {
+				fmt.Printf("Metadata batch failed: %v\n", err)
+			}
+		}
+		batch = batch[:0]
+	}
+	for item := range m.ch {
+		if !metadata.HasWritableMeta(item.Meta) {
+			continue
+		}
+		atomic.AddInt64(&m.attempted, 1)
+		batch = append(batch, item)
+		if len(batch) >= batchSize {
+			flush()
+		}
+	}
+	flush()
+}
+
+// startArgFileWriter records writes in an exiftool argfile at path instead
+// of running exiftool; see metadata.CreateArgFile for base.
+func startArgFileWriter(path, base string, buffer int) (*metaWriters, error) {
+	argfile, err := metadata.CreateArgFile(path, base)
+	if err != nil {
+		return nil, err
+	}
+	m := &metaWriters{ch: make(chan metadata.WriteItem, buffer), argfile: argfile}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		var werr error
+		for item := range m.ch {
+			if werr == nil {
+				werr = argfile.Write([]metadata.WriteItem{item})
+			}
+		}
+		if err := argfile.Close(); werr == nil {
+			werr = err
+		}
+		if werr != nil {
+			m.report = append(m.report, metadata.WriteFailure{
+				Path:     path,
+				Messages: []string{"Error: " + werr.Error()},
+				Fatal:    true,
+			})
+			atomic.AddInt64(&m.failures, 1)
+		}
+	}()
+	return m, nil
+}
+
+// Send queues one write; it blocks while all writers are busy.
+func (m *metaWriters) Send(item metadata.WriteItem) {
+	m.ch <- item
+}
+
+// Close waits for every queued write and returns the number of files whose
+// metadata could not be written plus the per-file diagnostics.
+func (m *metaWriters) Close() (int, []metadata.WriteFailure) {
+	close(m.ch)
+	m.wg.Wait()
+	return int(atomic.LoadInt64(&m.failures)), m.report
+}
+
+// Attempted returns the number of files that had metadata to write.
+func (m *metaWriters) Attempted() int {
+	return int(atomic.LoadInt64(&m.attempted))
+}
+
+// Exported returns the number of commands written to the argfile.
+func (m *metaWriters) Exported() int {
+	if m.argfile == nil {
+		return 0
+	}
+	return m.argfile.Count()
+}
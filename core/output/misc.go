@@ -0,0 +1,59 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gphotos/core/dedup"
+	"gphotos/core/fsutil"
+	"gphotos/core/scanner"
+)
+
+const miscFolder = "Misc"
+
+// CollectArtifacts copies Takeout artifacts (print orders, the index page,
+// ...) into Misc/, keeping their path below inRoot so files of the same
+// name stay apart. Files already there with the same content are skipped.
+// It returns the number copied.
+func CollectArtifacts(artifacts []scanner.Artifact, inRoot, outRoot string, dryRun bool) (int, error) {
+	copied := 0
+	for _, a := range artifacts {
+		rel, err := filepath.Rel(inRoot, a.Path)
+		if err != nil {
+			rel = filepath.Base(a.Path)
+		}
+		dst := filepath.Join(outRoot, miscFolder, rel)
+		if dryRun {
+			fmt.Printf("DRY RUN: %s -> %s\n", a.Path, dst)
+			copied++
+			continue
+		}
+		if sameContent(a.Path, dst) {
+			continue
+		}
+		if err := fsutil.MkdirAll(filepath.Dir(dst)); err != nil {
+			return copied, err
+		}
+		dst, err = uniquePath(filepath.Dir(dst), filepath.Base(dst), "")
+		if err != nil {
+			return copied, err
+		}
+		if err := copyFile(a.Path, dst); err != nil {
+			return copied, err
+		}
+		copied++
+	}
+	return copied, nil
+}
+
+func sameContent(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil || infoA.Size() != infoB.Size() {
+		return false
+	}
+	hashA, errA := dedup.HashFile(a)
+	hashB, errB := dedup.HashFile(b)
+	return errA == nil && errB == nil && hashA == hashB
+}
@@ -0,0 +1,47 @@
+package output
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/metadata"
+)
+
+// Values of Options.MotionPhotos.
+const (
+	MotionKeep    = ""        // copy motion photos unchanged
+	MotionExtract = "extract" // also write the embedded video as NAME.mp4
+	MotionStrip   = "strip"   // drop the embedded video from the still
+	MotionSplit   = "split"   // extract, then strip
+)
+
+// splitMotionPhoto applies mode to the copied file at dstPath. reserve picks
+// a free output path for the extracted video. It returns that path when a
+// video was written and whether the still was stripped; ordinary files are
+// left alone.
+func splitMotionPhoto(dstPath, mode string, reserve func(dir, name string) (string, error)) (string, bool, error) {
+	data, offset, ok := metadata.MotionVideo(dstPath)
+	if !ok {
+		return "", false, nil
+	}
+	var video string
+	if mode == MotionExtract || mode == MotionSplit {
+		base := filepath.Base(dstPath)
+		path, err := reserve(filepath.Dir(dstPath), strings.TrimSuffix(base, filepath.Ext(base))+".mp4")
+		if err != nil {
+			return "", false, err
+		}
+		if err := fsutil.WriteFile(path, data[offset:]); err != nil {
+			return "", false, err
+		}
+		video = path
+	}
+	if mode == MotionStrip || mode == MotionSplit {
+		if err := fsutil.WriteFile(dstPath, metadata.StillOnly(data, offset)); err != nil {
+			return video, false, err
+		}
+		return video, true, nil
+	}
+	return video, false, nil
+}
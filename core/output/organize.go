@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"gphotos/core/dedup"
+	"gphotos/core/fsutil"
 	"gphotos/core/metadata"
 	"gphotos/core/models"
 )
@@ -19,28 +24,103 @@ const (
 	albumsFolder  = "Albums"
 )
 
+// Options controls how OrganizePhotos writes the output tree.
+type Options struct {
+	DryRun    bool
+	Verbose   bool
+	Workers   int
+	ExifBatch int
+	// ExifWorkers is the number of persistent exiftool writers; it is sized
+	// independently of Workers since copying is usually faster than tagging.
+	ExifWorkers int
+	// Journal, when set, moves files with journaled renames instead of
+	// copying them.
+	Journal             *Journal
+	DescriptionSidecars bool
+	JSONSidecars        bool
+	// VerifySample is the fraction (0 to 1) of copied files that are
+	// re-hashed and compared with their source before metadata is written.
+	VerifySample float64
+	// ArgFile, when set, writes the planned metadata updates to this exiftool
+	// argfile instead of running exiftool; exiftool need not be installed.
+	ArgFile string
+	// MotionPhotos is one of the Motion* modes for the video embedded in
+	// motion photos.
+	MotionPhotos string
+	// DryRunMeta is one of the DryRunMeta* modes and controls how a dry run
+	// reports the metadata it would write.
+	DryRunMeta string
+	// Deadline, when set, stops the copy from starting new files once it has
+	// passed; files in flight are finished. PauseFile, while it exists,
+	// holds the copy before the next file.
+	Deadline  time.Time
+	PauseFile string
+}
+
+// Collision is an output name that was already taken and got a suffix.
+type Collision struct {
+	Wanted   string
+	Resolved string
+}
+
+// Stats summarizes what OrganizePhotos did.
+type Stats struct {
+	Copied int
+	// BytesCopied is the size of the copied (or, on a dry run, planned)
+	// files before metadata was written.
+	BytesCopied int64
+	// AlreadyPresent counts files whose destination already held identical
+	// content, so the copy was skipped; they are included in Copied.
+	AlreadyPresent    int
+	Collisions        []Collision
+	MetadataAttempted int
+	MetadataFailures  int
+	MetadataReport    []metadata.WriteFailure
+	// MetadataExported counts the commands written to Options.ArgFile.
+	MetadataExported int
+	// Verified counts re-hashed copies; VerifyFailures lists the output
+	// paths whose content did not match the source.
+	Verified       int
+	VerifyFailures []string
+	// XMPSidecars lists the output files whose format exiftool cannot write
+	// into, so their metadata went to a NAME.ext.xmp sidecar.
+	XMPSidecars []string
+	// MotionExtracted and MotionStripped count motion photos whose video
+	// was written to its own file or removed from the still.
+	MotionExtracted int
+	MotionStripped  int
+	// PlannedTags counts, on a dry run, the files each tag would be written
+	// to.
+	PlannedTags map[string]int
+	// Stopped is set when Options.Deadline ended the copy early.
+	Stopped bool
+}
+
 // OrganizePhotos copies photos into the output folder.
+// Photos with Route set go into <Route>/ (e.g. Screenshots/).
 // Photos with FinalAlbum set go into Albums/<FinalAlbum>/.
 // Others go into Library/.
-func OrganizePhotos(photos []*models.Photo, outRoot string, dryRun bool, verbose bool, workers int, exifBatch int, progress func(done, total int)) error {
+func OrganizePhotos(photos []*models.Photo, outRoot string, opts Options, progress func(done, total int)) (Stats, error) {
+	var stats Stats
 	if outRoot == "" {
-		return fmt.Errorf("output root is empty")
+		return stats, fmt.Errorf("output root is empty")
 	}
 
-	libDir := filepath.Join(outRoot, libraryFolder)
-	albDir := filepath.Join(outRoot, albumsFolder)
+	dryRun := opts.DryRun
+	verbose := opts.Verbose
+	workers := opts.Workers
+	exifBatch := opts.ExifBatch
 
 	if !dryRun {
-		if err := os.MkdirAll(libDir, 0o755); err != nil {
-			return err
-		}
-		if err := os.MkdirAll(albDir, 0o755); err != nil {
-			return err
+		if err := fsutil.MkdirAll(outRoot); err != nil {
+			return stats, err
 		}
 	}
 
 	total := len(photos)
-	if workers < 1 {
+	// A dry run copies nothing, and one worker prints the plan in photo
+	// order.
+	if workers < 1 || dryRun {
 		workers = 1
 	}
 	if exifBatch < 1 {
@@ -50,50 +130,55 @@ func OrganizePhotos(photos []*models.Photo, outRoot string, dryRun bool, verbose
 	var (
 		mu        sync.Mutex
 		processed int64
+		copied    int64
+		existing  int64
 		firstErr  error
 	)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	jobs := make(chan *models.Photo, workers*2)
-	metaCh := make(chan metadata.WriteItem, workers*4)
-	var metaWg sync.WaitGroup
-
-	if !dryRun && metadata.CanWriteMeta() {
-		metaWg.Add(1)
-		go func() {
-			defer metaWg.Done()
-			writer, err := metadata.StartBatchWriter()
-			if err != nil {
-				if verbose {
-					fmt.Printf("Metadata writer unavailable: %v\n", err)
-				}
-				return
-			}
-			defer writer.Close()
+	// Journaled renames leave the content untouched, so only copies are
+	// verified.
+	var verify map[*models.Photo]bool
+	if !dryRun && opts.Journal == nil {
+		verify = verifySample(photos, opts.VerifySample)
+	}
 
-			var batch []metadata.WriteItem
-			flush := func() {
-				if len(batch) == 0 {
-					return
-				}
-				if err := writer.Write(batch); err != nil && verbose {
-					fmt.Printf("Metadata batch failed: %v\n", err)
-				}
-				batch = batch[:0]
+	// Written files are rewritten by exiftool and no longer hash like their
+	// source, so copies made by earlier runs are found through the library
+	// index, which records them by source hash.
+	var recorded map[string]string
+	if opts.Journal == nil {
+		if index, err := loadLibraryIndex(outRoot); err == nil {
+			recorded = make(map[string]string, len(index))
+			for hash, rel := range index {
+				recorded[hash] = filepath.Join(outRoot, rel)
 			}
-			for item := range metaCh {
-				if !metadata.HasWritableMeta(item.Meta) {
-					continue
-				}
-				batch = append(batch, item)
-				if len(batch) >= exifBatch {
-					flush()
-				}
-			}
-			flush()
-		}()
+		}
+	}
+
+	jobs := make(chan organizeJob, workers*2)
+	// Output names are handed out by the loop feeding the workers, in photo
+	// order, so colliding names resolve the same way on every run. taken
+	// holds the ones not written yet.
+	taken := make(map[string]bool)
+	var preview *tagPreview
+	if dryRun {
+		preview = newTagPreview(opts.DryRunMeta)
+	}
+	// Reorganize runs only rename: the originals are left byte for byte as
+	// they were, with no sidecars, so an undo restores the Takeout exactly.
+	var meta *metaWriters
+	switch {
+	case dryRun, opts.Journal != nil:
+	case opts.ArgFile != "":
+		var err error
+		if meta, err = startArgFileWriter(opts.ArgFile, outRoot, workers*4); err != nil {
+			return stats, err
+		}
+	case metadata.CanWriteMeta():
+		meta = startMetaWriters(opts.ExifWorkers, exifBatch, workers*4, verbose)
 	}
 
 	var wg sync.WaitGroup
@@ -103,57 +188,77 @@ func OrganizePhotos(photos []*models.Photo, outRoot string, dryRun bool, verbose
 			select {
 			case <-ctx.Done():
 				return
-			case p, ok := <-jobs:
+			case job, ok := <-jobs:
 				if !ok {
 					return
 				}
-				if p == nil || p.SrcPath == "" {
-					continue
-				}
-
-				dstDir := libDir
-				if strings.TrimSpace(p.FinalAlbum) != "" {
-					dstDir = filepath.Join(albDir, sanitizeFolder(p.FinalAlbum))
-					if !dryRun {
-						if err := os.MkdirAll(dstDir, 0o755); err != nil {
-							mu.Lock()
-							if firstErr == nil {
-								firstErr = err
-								cancel()
-							}
-							mu.Unlock()
-							return
+				p, dstDir, base := job.photo, filepath.Dir(job.dstPath), job.base
+				// Folders are created on demand so merged libraries
+				// with their own layout do not gain empty ones.
+				if !dryRun {
+					if err := fsutil.MkdirAll(dstDir); err != nil {
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = err
+							cancel()
 						}
+						mu.Unlock()
+						return
 					}
 				}
 
-				base := filepath.Base(p.SrcPath)
-				ext := strings.ToLower(filepath.Ext(base))
-				if kind, ok := metadata.DetectFileKind(p.SrcPath); ok {
-					if pref := metadata.PreferredExtension(kind); pref != "" && pref != ext {
-						base = strings.TrimSuffix(base, ext) + pref
-					}
+				var size int64
+				if info, err := os.Stat(p.SrcPath); err == nil {
+					size = info.Size()
 				}
-				mu.Lock()
-				dstPath, err := uniquePath(dstDir, base, p.Hash)
-				mu.Unlock()
-				if err != nil {
-					mu.Lock()
-					if firstErr == nil {
-						firstErr = err
-						cancel()
+				// Moves always happen, so only copies can be found in place.
+				var dstPath string
+				present := false
+				if opts.Journal == nil {
+					dstPath, present = identicalExisting(p, size, dstDir, base, recorded)
+				}
+				if !present {
+					dstPath = job.dstPath
+					if filepath.Base(dstPath) != base {
+						mu.Lock()
+						stats.Collisions = append(stats.Collisions, Collision{
+							Wanted:   filepath.Join(dstDir, base),
+							Resolved: dstPath,
+						})
+						mu.Unlock()
 					}
-					mu.Unlock()
-					return
 				}
 
-				if dryRun {
+				// A copy's DstPath is only set once the file is there, so a
+				// failed transfer never shows up as written.
+				if present || dryRun {
+					p.DstPath = dstPath
+				}
+				if present {
+					atomic.AddInt64(&existing, 1)
+				}
+				if dryRun && present {
+					fmt.Printf("DRY RUN: already present %s\n", dstPath)
+				} else if dryRun {
+					atomic.AddInt64(&copied, size)
 					fmt.Printf("DRY RUN: %s -> %s\n", p.SrcPath, dstPath)
+					if opts.Journal == nil {
+						sidecar := !metadata.CanWriteInPlace(dstPath)
+						preview.add(metadata.WriteItem{Path: dstPath, Meta: p.Meta, Sidecar: sidecar})
+					}
+				} else if present {
+					if verbose {
+						fmt.Printf("Already present: %s\n", dstPath)
+					}
 				} else {
+					transfer, verb := copyFile, "Copy"
+					if opts.Journal != nil {
+						transfer, verb = opts.Journal.Rename, "Move"
+					}
 					if verbose {
-						fmt.Printf("Copy: %s -> %s\n", p.SrcPath, dstPath)
+						fmt.Printf("%s: %s -> %s\n", verb, p.SrcPath, dstPath)
 					}
-					if err := copyFile(p.SrcPath, dstPath); err != nil {
+					if err := transfer(p.SrcPath, dstPath); err != nil {
 						mu.Lock()
 						if firstErr == nil {
 							firstErr = err
@@ -162,10 +267,64 @@ func OrganizePhotos(photos []*models.Photo, outRoot string, dryRun bool, verbose
 						mu.Unlock()
 						return
 					}
-					select {
-					case metaCh <- metadata.WriteItem{Path: dstPath, Meta: p.Meta}:
-					default:
-						metaCh <- metadata.WriteItem{Path: dstPath, Meta: p.Meta}
+					p.DstPath = dstPath
+					atomic.AddInt64(&copied, size)
+					if verify[p] {
+						err := verifyCopy(p, dstPath)
+						mu.Lock()
+						stats.Verified++
+						if err != nil {
+							stats.VerifyFailures = append(stats.VerifyFailures, dstPath)
+						}
+						mu.Unlock()
+						if err != nil && verbose {
+							fmt.Printf("Verify failed: %s (%v)\n", dstPath, err)
+						}
+					}
+				}
+				if !dryRun && opts.MotionPhotos != MotionKeep && !present {
+					video, stripped, err := splitMotionPhoto(dstPath, opts.MotionPhotos, func(dir, name string) (string, error) {
+						mu.Lock()
+						defer mu.Unlock()
+						path, err := freePath(dir, name, p.Hash, taken)
+						taken[path] = true
+						return path, err
+					})
+					if err != nil && verbose {
+						fmt.Printf("Motion photo split failed: %s (%v)\n", dstPath, err)
+					}
+					p.MotionVideo, p.MotionStripped = video, stripped
+					mu.Lock()
+					if video != "" {
+						stats.MotionExtracted++
+					}
+					if stripped {
+						stats.MotionStripped++
+					}
+					mu.Unlock()
+				}
+				if !dryRun && opts.Journal == nil {
+					if opts.DescriptionSidecars && p.Meta.Description != "" {
+						if err := writeDescriptionSidecar(dstPath, p.Meta.Description); err != nil && verbose {
+							fmt.Printf("Description sidecar failed: %s (%v)\n", dstPath, err)
+						}
+					}
+					if opts.JSONSidecars && p.JsonPath != "" {
+						if err := copyFile(p.JsonPath, dstPath+".json"); err != nil && verbose {
+							fmt.Printf("JSON sidecar copy failed: %s (%v)\n", p.JsonPath, err)
+						}
+					}
+					sidecar := !metadata.CanWriteInPlace(dstPath) && metadata.HasWritableMeta(p.Meta)
+					if sidecar && meta != nil {
+						mu.Lock()
+						stats.XMPSidecars = append(stats.XMPSidecars, dstPath)
+						mu.Unlock()
+					}
+					if meta != nil {
+						meta.Send(metadata.WriteItem{Path: dstPath, Meta: p.Meta, Sidecar: sidecar})
+						if p.MotionVideo != "" {
+							meta.Send(metadata.WriteItem{Path: p.MotionVideo, Meta: p.Meta})
+						}
 					}
 				}
 
@@ -182,26 +341,195 @@ func OrganizePhotos(photos []*models.Photo, outRoot string, dryRun bool, verbose
 		go workerFn()
 	}
 
+feed:
 	for _, p := range photos {
+		if p == nil || p.SrcPath == "" {
+			continue
+		}
+		if !mayStart(opts) {
+			stats.Stopped = true
+			break
+		}
+		base := destName(p)
+		mu.Lock()
+		dstPath, err := freePath(destDir(p, outRoot), base, p.Hash, taken)
+		taken[dstPath] = true
+		if err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
 		select {
 		case <-ctx.Done():
-			break
-		default:
-			jobs <- p
+			break feed
+		case jobs <- organizeJob{photo: p, base: base, dstPath: dstPath}:
 		}
 	}
 	close(jobs)
 	wg.Wait()
-	close(metaCh)
-	metaWg.Wait()
+	for _, list := range [][]string{stats.VerifyFailures, stats.XMPSidecars} {
+		sort.Strings(list)
+	}
+	sort.Slice(stats.Collisions, func(i, j int) bool {
+		return stats.Collisions[i].Resolved < stats.Collisions[j].Resolved
+	})
+	if meta != nil {
+		stats.MetadataFailures, stats.MetadataReport = meta.Close()
+		stats.MetadataAttempted = meta.Attempted()
+		stats.MetadataExported = meta.Exported()
+		// exiftool replaces the files it writes, so their mode and
+		// ownership are set again.
+		for _, p := range photos {
+			if p == nil || p.DstPath == "" {
+				continue
+			}
+			for _, path := range []string{p.DstPath, p.MotionVideo, metadata.SidecarPath(p.DstPath)} {
+				if path == "" {
+					continue
+				}
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					continue
+				}
+				if err := fsutil.ApplyPermissions(path); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
 
+	stats.PlannedTags = preview.Counts()
+	stats.Copied = int(processed)
+	stats.BytesCopied = atomic.LoadInt64(&copied)
+	stats.AlreadyPresent = int(atomic.LoadInt64(&existing))
 	if firstErr != nil {
-		return firstErr
+		return stats, firstErr
 	}
 
+	return stats, nil
+}
+
+// organizeJob is one photo for the copy workers, with the name it wants
+// (base) and the path reserved for it should nothing identical be there.
+type organizeJob struct {
+	photo   *models.Photo
+	base    string
+	dstPath string
+}
+
+// destName is the file name p wants in its output folder: DstName or the
+// source name, with the extension corrected to the detected format.
+func destName(p *models.Photo) string {
+	base := filepath.Base(p.SrcPath)
+	if p.DstName != "" {
+		base = p.DstName
+	}
+	ext := strings.ToLower(filepath.Ext(base))
+	if kind, ok := metadata.DetectFileKind(p.SrcPath); ok && !metadata.ExtensionFits(kind, ext) {
+		// Also gives extensionless files a proper extension.
+		base = strings.TrimSuffix(base, ext) + metadata.PreferredExtension(kind)
+	}
+	return base
+}
+
+// destDir is the output folder of p: <Route>/, Albums/<FinalAlbum>/ or
+// Library/.
+func destDir(p *models.Photo, outRoot string) string {
+	if route := strings.TrimSpace(p.Route); route != "" {
+		return filepath.Join(outRoot, sanitizeRoute(route))
+	}
+	if strings.TrimSpace(p.FinalAlbum) != "" {
+		return filepath.Join(outRoot, albumsFolder, sanitizeFolder(p.FinalAlbum))
+	}
+	return filepath.Join(outRoot, libraryFolder)
+}
+
+// identicalExisting looks for a copy of p at the paths uniquePath would try
+// first for it (base, then base-<hash>) and returns the one it finds: either
+// the file recorded for p's source hash by an earlier run, or a file with
+// the same size and content.
+func identicalExisting(p *models.Photo, size int64, dir, base string, recorded map[string]string) (string, bool) {
+	if p.Hash == "" {
+		return "", false
+	}
+	ext := filepath.Ext(base)
+	candidates := []string{filepath.Join(dir, base)}
+	if len(p.Hash) >= 8 {
+		candidates = append(candidates, filepath.Join(dir, strings.TrimSuffix(base, ext)+"-"+p.Hash[:8]+ext))
+	}
+	if path, ok := recorded[p.Hash]; ok {
+		for _, candidate := range candidates {
+			if candidate != path {
+				continue
+			}
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				return path, true
+			}
+		}
+	}
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() != size {
+			continue
+		}
+		if sum, err := dedup.HashFile(path); err == nil && sum == p.Hash {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// verifySample picks a random fraction of photos to verify after copying.
+// At least one photo is picked when fraction is above zero.
+func verifySample(photos []*models.Photo, fraction float64) map[*models.Photo]bool {
+	if fraction <= 0 || len(photos) == 0 {
+		return nil
+	}
+	n := len(photos)
+	if fraction < 1 {
+		n = int(float64(n)*fraction + 0.5)
+		if n < 1 {
+			n = 1
+		}
+	}
+	picked := make(map[*models.Photo]bool, n)
+	for _, i := range rand.Perm(len(photos))[:n] {
+		picked[photos[i]] = true
+	}
+	return picked
+}
+
+// verifyCopy re-hashes dstPath and compares it with the source hash,
+// hashing the source too when it was not hashed during dedup. It must run
+// before metadata is written, since that rewrites the copy.
+func verifyCopy(p *models.Photo, dstPath string) error {
+	want := p.Hash
+	if want == "" {
+		var err error
+		if want, err = dedup.HashFile(p.SrcPath); err != nil {
+			return err
+		}
+	}
+	got, err := dedup.HashFile(dstPath)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("content differs from %s", p.SrcPath)
+	}
 	return nil
 }
 
+// writeDescriptionSidecar writes the description to NAME.ext.txt next to
+// the output file for viewers that ignore embedded EXIF descriptions. The
+// extension stays in the name so IMG_1.jpg and IMG_1.mp4 do not share one.
+func writeDescriptionSidecar(dstPath, description string) error {
+	return fsutil.WriteFile(descriptionSidecarPath(dstPath), []byte(description+"\n"))
+}
+
+func descriptionSidecarPath(dstPath string) string {
+	return dstPath + ".txt"
+}
+
 func copyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -209,7 +537,7 @@ func copyFile(src, dst string) error {
 	}
 	defer in.Close()
 
-	out, err := os.Create(dst)
+	out, err := fsutil.Create(dst)
 	if err != nil {
 		return err
 	}
@@ -224,13 +552,30 @@ func copyFile(src, dst string) error {
 }
 
 func uniquePath(dir, filename, hash string) (string, error) {
+	return freePath(dir, filename, hash, nil)
+}
+
+// freePath is uniquePath that also skips the paths in taken, which are
+// reserved for files not written yet.
+func freePath(dir, filename, hash string, taken map[string]bool) (string, error) {
+	free := func(path string) (bool, error) {
+		if taken[path] {
+			return false, nil
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
 	path := filepath.Join(dir, filename)
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return path, nil
-	} else if err != nil {
+	if ok, err := free(path); err != nil {
 		return "", err
+	} else if ok {
+		return path, nil
 	}
-	fmt.Printf("Name collision detected: %s\n", path)
 
 	ext := filepath.Ext(filename)
 	name := strings.TrimSuffix(filename, ext)
@@ -245,21 +590,19 @@ func uniquePath(dir, filename, hash string) (string, error) {
 
 	if hashPart != "" {
 		path = filepath.Join(dir, fmt.Sprintf("%s-%s%s", name, hashPart, ext))
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			fmt.Printf("Resolved collision with hash: %s\n", path)
-			return path, nil
-		} else if err != nil {
+		if ok, err := free(path); err != nil {
 			return "", err
+		} else if ok {
+			return path, nil
 		}
 	}
 
 	for i := 1; i < 10000; i++ {
 		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, ext))
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			fmt.Printf("Resolved collision with suffix: %s\n", path)
-			return path, nil
-		} else if err != nil {
+		if ok, err := free(path); err != nil {
 			return "", err
+		} else if ok {
+			return path, nil
 		}
 	}
 
@@ -274,3 +617,20 @@ func sanitizeFolder(name string) string {
 	}
 	return name
 }
+
+// sanitizeRoute cleans each segment of a slash-separated route folder.
+func sanitizeRoute(route string) string {
+	segments := strings.Split(filepath.ToSlash(route), "/")
+	out := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		seg = strings.TrimSpace(seg)
+		if seg == "" || seg == "." || seg == ".." {
+			continue
+		}
+		out = append(out, sanitizeFolder(seg))
+	}
+	if len(out) == 0 {
+		return "Untitled"
+	}
+	return filepath.Join(out...)
+}
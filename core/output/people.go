@@ -0,0 +1,114 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+const peopleFolder = "People"
+
+// LinkPeople builds a People/<Name>/ tree of links pointing at the copied
+// files of every photo tagged with that person. mode is "symlink" or
+// "hardlink". Photos must already have DstPath set by OrganizePhotos.
+func LinkPeople(photos []*models.Photo, outRoot string, mode string, dryRun bool) (int, error) {
+	if mode != "symlink" && mode != "hardlink" {
+		return 0, fmt.Errorf("unknown link mode: %s", mode)
+	}
+	root := filepath.Join(outRoot, peopleFolder)
+	linked := 0
+	for _, p := range photos {
+		if p == nil || p.DstPath == "" || len(p.Meta.People) == 0 {
+			continue
+		}
+		for _, name := range p.Meta.People {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			dir := filepath.Join(root, sanitizeFolder(name))
+			if dryRun {
+				fmt.Printf("DRY RUN: link %s -> %s\n", filepath.Join(dir, filepath.Base(p.DstPath)), p.DstPath)
+				linked++
+				continue
+			}
+			if err := fsutil.MkdirAll(dir); err != nil {
+				return linked, err
+			}
+			if done, err := placeLink(p.DstPath, dir, p.Hash, mode); err != nil {
+				return linked, err
+			} else if done {
+				linked++
+			}
+		}
+	}
+	return linked, nil
+}
+
+// linkFile creates linkPath pointing at target. Symlinks are relative so the
+// output tree can be moved as a whole.
+func linkFile(target, linkPath, mode string) error {
+	if mode == "hardlink" {
+		return os.Link(target, linkPath)
+	}
+	rel, err := filepath.Rel(filepath.Dir(linkPath), target)
+	if err != nil {
+		rel = target
+	}
+	return os.Symlink(rel, linkPath)
+}
+
+// placeLink links target into dir under its own name, or a free variant of
+// it as uniquePath picks them. It reports false when a link to target from
+// an earlier run is already there, so reruns do not add duplicates.
+func placeLink(target, dir, hash, mode string) (bool, error) {
+	filename := filepath.Base(target)
+	if linkedBefore(target, dir, filename, hash) {
+		return false, nil
+	}
+	linkPath, err := uniquePath(dir, filename, hash)
+	if err != nil {
+		return false, err
+	}
+	if err := linkFile(target, linkPath, mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// linkedBefore reports whether one of the names uniquePath would try for
+// filename in dir already resolves to target. Stat follows symlinks, so
+// SameFile matches both link modes.
+func linkedBefore(target, dir, filename, hash string) bool {
+	want, err := os.Stat(target)
+	if err != nil {
+		return false
+	}
+	same := func(name string) (exists, linked bool) {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return false, false
+		}
+		return true, os.SameFile(info, want)
+	}
+	if _, linked := same(filename); linked {
+		return true
+	}
+	ext := filepath.Ext(filename)
+	name := strings.TrimSuffix(filename, ext)
+	if hash != "" {
+		if _, linked := same(fmt.Sprintf("%s-%s%s", name, hash[:min(len(hash), 8)], ext)); linked {
+			return true
+		}
+	}
+	for i := 1; ; i++ {
+		exists, linked := same(fmt.Sprintf("%s-%d%s", name, i, ext))
+		if !exists || linked {
+			return linked
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+// picasaIniName is the hidden per-folder file read by Picasa 3 and the
+// viewers that adopted its format.
+const picasaIniName = ".picasa.ini"
+
+// WritePicasaIni emits a .picasa.ini in every output folder listing the album
+// name, favorite stars, and captions of the files copied there. Photos must
+// already have DstPath set by OrganizePhotos.
+func WritePicasaIni(photos []*models.Photo, dryRun bool) (int, error) {
+	byDir := make(map[string][]*models.Photo)
+	for _, p := range photos {
+		if p == nil || p.DstPath == "" {
+			continue
+		}
+		dir := filepath.Dir(p.DstPath)
+		byDir[dir] = append(byDir[dir], p)
+	}
+
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	written := 0
+	for _, dir := range dirs {
+		group := byDir[dir]
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].DstPath < group[j].DstPath
+		})
+
+		var b strings.Builder
+		if album := strings.TrimSpace(group[0].FinalAlbum); album != "" && strings.TrimSpace(group[0].Route) == "" {
+			fmt.Fprintf(&b, "[Picasa]\nname=%s\n", picasaValue(album))
+		}
+		for _, p := range group {
+			caption := picasaValue(p.Meta.Description)
+			if !p.Meta.Favorited && caption == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "[%s]\n", filepath.Base(p.DstPath))
+			if p.Meta.Favorited {
+				b.WriteString("star=yes\n")
+			}
+			if caption != "" {
+				fmt.Fprintf(&b, "caption=%s\n", caption)
+			}
+		}
+		if b.Len() == 0 {
+			continue
+		}
+		path := filepath.Join(dir, picasaIniName)
+		if dryRun {
+			fmt.Printf("DRY RUN: write %s\n", path)
+			written++
+			continue
+		}
+		if err := fsutil.WriteFile(path, []byte(b.String())); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// picasaValue flattens a value onto one line since the ini format has no
+// escaping for newlines.
+func picasaValue(v string) string {
+	v = strings.ReplaceAll(v, "\r\n", " ")
+	v = strings.ReplaceAll(v, "\n", " ")
+	return strings.TrimSpace(v)
+}
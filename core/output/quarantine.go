@@ -0,0 +1,57 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gphotos/core/fsutil"
+)
+
+const quarantineFolder = "Quarantine"
+
+// QuarantineItem is a source file that failed the integrity check.
+type QuarantineItem struct {
+	SrcPath string
+	Reason  string
+}
+
+// QuarantineFiles copies broken media into <outRoot>/Quarantine/ and writes
+// a report.txt listing each file and why it was set aside. With a journal the
+// files are moved instead of copied.
+func QuarantineFiles(items []QuarantineItem, outRoot string, dryRun bool, journal *Journal) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if outRoot == "" {
+		return fmt.Errorf("output root is empty")
+	}
+	dir := filepath.Join(outRoot, quarantineFolder)
+	if dryRun {
+		for _, item := range items {
+			fmt.Printf("DRY RUN: quarantine %s (%s)\n", item.SrcPath, item.Reason)
+		}
+		return nil
+	}
+	if err := fsutil.MkdirAll(dir); err != nil {
+		return err
+	}
+
+	var report strings.Builder
+	for _, item := range items {
+		dstPath, err := uniquePath(dir, filepath.Base(item.SrcPath), "")
+		if err != nil {
+			return err
+		}
+		transfer := copyFile
+		if journal != nil {
+			transfer = journal.Rename
+		}
+		if err := transfer(item.SrcPath, dstPath); err != nil {
+			fmt.Fprintf(&report, "%s\t%s\tcopy failed: %v\n", item.SrcPath, item.Reason, err)
+			continue
+		}
+		fmt.Fprintf(&report, "%s\t%s\t%s\n", item.SrcPath, item.Reason, filepath.Base(dstPath))
+	}
+	return fsutil.WriteFile(filepath.Join(dir, "report.txt"), []byte(report.String()))
+}
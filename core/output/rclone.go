@@ -0,0 +1,137 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/metadata"
+	"gphotos/core/models"
+)
+
+const rcloneListFile = "rclone_files.txt"
+
+// RcloneAvailable reports whether the rclone binary is on PATH.
+func RcloneAvailable() bool {
+	_, err := exec.LookPath("rclone")
+	return err == nil
+}
+
+// RunFiles returns the files this run wrote for photos, relative to outRoot:
+// each output file with its motion video and any XMP, description and JSON
+// sidecars. Files outside outRoot are left out.
+func RunFiles(outRoot string, photos []*models.Photo) []string {
+	// Never nil, which would list the whole tree.
+	files := []string{}
+	for _, p := range photos {
+		if p == nil || p.DstPath == "" {
+			continue
+		}
+		for _, path := range []string{p.DstPath, p.MotionVideo, metadata.SidecarPath(p.DstPath), descriptionSidecarPath(p.DstPath), p.DstPath + ".json"} {
+			if path == "" {
+				continue
+			}
+			if _, err := os.Lstat(path); err != nil {
+				continue
+			}
+			rel, err := filepath.Rel(outRoot, path)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	return files
+}
+
+// WriteRcloneList writes <outRoot>/.gphotos/rclone_files.txt, a --files-from
+// list of files relative to outRoot, and returns its path. A nil files
+// lists every file in the output tree.
+func WriteRcloneList(outRoot string, files []string) (string, error) {
+	if files == nil {
+		var err error
+		if files, err = treeFiles(outRoot); err != nil {
+			return "", err
+		}
+	}
+	files = append([]string(nil), files...)
+	sort.Strings(files)
+
+	path := filepath.Join(outRoot, ".gphotos", rcloneListFile)
+	if err := fsutil.MkdirAll(filepath.Dir(path)); err != nil {
+		return "", err
+	}
+	f, err := fsutil.Create(path)
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(f)
+	for _, rel := range files {
+		fmt.Fprintln(w, rel)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// treeFiles lists every file below outRoot outside .gphotos.
+func treeFiles(outRoot string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(outRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != outRoot && d.Name() == ".gphotos" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(outRoot, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// RcloneCommand returns the rclone invocation that uploads the files listed
+// in listPath from outRoot to remote. mode is "copy" or "move".
+func RcloneCommand(mode, outRoot, remote, listPath string) []string {
+	return []string{"rclone", mode, outRoot, remote, "--files-from", listPath, "--stats-one-line", "-v"}
+}
+
+// RcloneTransfer uploads files (relative to outRoot; nil for the whole
+// output tree) to an rclone remote (for example "gdrive:Photos"). mode
+// "move" deletes the uploaded local copies, so callers pass only the files
+// the run wrote. Output from rclone is passed through to the terminal.
+func RcloneTransfer(mode, outRoot, remote string, files []string) error {
+	if mode != "copy" && mode != "move" {
+		return fmt.Errorf("unknown rclone mode: %s", mode)
+	}
+	if !RcloneAvailable() {
+		return fmt.Errorf("rclone not found on PATH")
+	}
+	listPath, err := WriteRcloneList(outRoot, files)
+	if err != nil {
+		return err
+	}
+	args := RcloneCommand(mode, outRoot, remote, listPath)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v", strings.Join(args[:2], " "), err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+	"gphotos/core/scanner"
+)
+
+const reconcileReportFile = "reconcile_report.txt"
+
+// FolderReconciliation compares what a Takeout folder holds with what the
+// run scanned from it and how many of those files ended up in the output,
+// directly or as a duplicate of a copied file.
+type FolderReconciliation struct {
+	scanner.FolderCount
+	Scanned int
+	Copied  int
+}
+
+// Unrecognized counts files in the folder the scan did not take as media.
+func (f FolderReconciliation) Unrecognized() int {
+	return max(f.Files-f.Scanned, 0)
+}
+
+// Missing counts items the album metadata declares beyond the files the
+// export holds.
+func (f FolderReconciliation) Missing() int {
+	if f.Declared < 0 {
+		return 0
+	}
+	return max(f.Declared-f.Files, 0)
+}
+
+// NotCopied counts scanned files that are not in the output, because they
+// were filtered, excluded, quarantined or dropped as recompressed copies.
+func (f FolderReconciliation) NotCopied() int {
+	return max(f.Scanned-f.Copied, 0)
+}
+
+func (f FolderReconciliation) differs() bool {
+	return f.Unrecognized() > 0 || f.Missing() > 0 || f.NotCopied() > 0
+}
+
+// Reconcile matches the folder counts of a Takeout with scanned, the media
+// found per folder, and photos after OrganizePhotos: every source copy of a
+// photo with DstPath set counts as copied, and so does a file of an
+// overlapping Takeout part (a key of overlapKept) whose kept twin was.
+func Reconcile(folders []scanner.FolderCount, scanned map[string]int, overlapKept map[string]string, photos []*models.Photo) []FolderReconciliation {
+	copied := make(map[string]int)
+	seen := make(map[string]bool)
+	count := func(path string) {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			copied[filepath.Dir(path)]++
+		}
+	}
+	for _, p := range photos {
+		if p == nil || p.DstPath == "" {
+			continue
+		}
+		count(p.SrcPath)
+		for _, c := range p.Copies {
+			count(c.MediaPath)
+		}
+	}
+	for dropped, kept := range overlapKept {
+		if seen[kept] {
+			count(dropped)
+		}
+	}
+	rows := make([]FolderReconciliation, 0, len(folders))
+	for _, f := range folders {
+		rows = append(rows, FolderReconciliation{
+			FolderCount: f,
+			Scanned:     scanned[f.Dir],
+			Copied:      copied[f.Dir],
+		})
+	}
+	return rows
+}
+
+// WriteReconcileReport lists the folders of rows whose counts disagree in
+// <outRoot>/reconcile_report.txt, with paths relative to inRoot, and
+// returns the report path and the number of folders listed. An earlier
+// report is removed when every folder agrees.
+func WriteReconcileReport(inRoot, outRoot string, rows []FolderReconciliation) (string, int, error) {
+	path := filepath.Join(outRoot, reconcileReportFile)
+	var b strings.Builder
+	listed := 0
+	for _, f := range rows {
+		if !f.differs() {
+			continue
+		}
+		if listed == 0 {
+			b.WriteString("folder\tfiles\tdeclared\tscanned\tcopied\n")
+		}
+		declared := "-"
+		if f.Declared >= 0 {
+			declared = fmt.Sprint(f.Declared)
+		}
+		fmt.Fprintf(&b, "%s\t%d\t%s\t%d\t%d\n", relSlash(inRoot, f.Dir), f.Files, declared, f.Scanned, f.Copied)
+		listed++
+	}
+	if listed == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return "", 0, err
+		}
+		return "", 0, nil
+	}
+	return path, listed, fsutil.WriteFile(path, []byte(b.String()))
+}
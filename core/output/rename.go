@@ -0,0 +1,108 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gphotos/core/models"
+)
+
+const chronoLayout = "20060102_150405"
+
+// copySuffixRe matches the "(1)" Takeout appends, without a space, to the
+// second file of the same name in a folder. Names such as
+// "Birthday (2019).jpg" are the user's own and do not match.
+var copySuffixRe = regexp.MustCompile(`^(.+?)\((\d{1,2})\)(\.[^.]*)?$`)
+
+// ChronoNames sets DstName to YYYYMMDD_HHMMSS.ext from each photo's resolved
+// taken date, so a plain name sort is chronological. Photos sharing a second
+// get _1, _2, ... in content hash order, which keeps the numbering stable
+// across runs. Photos without a date keep their original name. It returns
+// the number of photos renamed.
+func ChronoNames(photos []*models.Photo) int {
+	bySecond := make(map[string][]*models.Photo)
+	for _, p := range photos {
+		if p == nil || p.Meta.TakenTime == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, p.Meta.TakenTime)
+		if err != nil {
+			continue
+		}
+		stamp := t.Format(chronoLayout)
+		bySecond[stamp] = append(bySecond[stamp], p)
+	}
+
+	renamed := 0
+	for stamp, group := range bySecond {
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].Hash != group[j].Hash {
+				return group[i].Hash < group[j].Hash
+			}
+			return group[i].SrcPath < group[j].SrcPath
+		})
+		for i, p := range group {
+			ext := strings.ToLower(filepath.Ext(p.SrcPath))
+			if len(group) == 1 {
+				p.DstName = stamp + ext
+			} else {
+				p.DstName = fmt.Sprintf("%s_%d%s", stamp, i+1, ext)
+			}
+			renamed++
+		}
+	}
+	return renamed
+}
+
+// StripCopySuffixes drops the "(1)" Takeout adds to repeated file names
+// ("IMG_001(1).jpg" becomes "IMG_001.jpg") when no other file of the run
+// would get that name in the same output folder and none exists there yet.
+// Only names whose JSON sidecar Takeout named after the un-suffixed file
+// ("IMG_001.jpg(1).json") are taken for its copies.
+// Photos already renamed keep their DstName. It returns the number of
+// photos renamed.
+func StripCopySuffixes(photos []*models.Photo, outRoot string) int {
+	name := func(p *models.Photo) string {
+		if p.DstName != "" {
+			return p.DstName
+		}
+		return filepath.Base(p.SrcPath)
+	}
+	taken := make(map[string]int)
+	for _, p := range photos {
+		if p != nil {
+			taken[strings.ToLower(filepath.Join(destDir(p, outRoot), name(p)))]++
+		}
+	}
+
+	renamed := 0
+	for _, p := range photos {
+		if p == nil || p.DstName != "" {
+			continue
+		}
+		m := copySuffixRe.FindStringSubmatch(filepath.Base(p.SrcPath))
+		if m == nil {
+			continue
+		}
+		stripped := m[1] + m[3]
+		if !strings.HasPrefix(filepath.Base(p.JsonPath), stripped+".") && !strings.HasPrefix(filepath.Base(p.JsonPath), stripped+"(") {
+			continue
+		}
+		target := filepath.Join(destDir(p, outRoot), stripped)
+		if taken[strings.ToLower(target)] > 0 {
+			continue
+		}
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		}
+		taken[strings.ToLower(target)]++
+		p.DstName = stripped
+		renamed++
+	}
+	return renamed
+}
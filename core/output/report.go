@@ -0,0 +1,57 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/metadata"
+)
+
+const (
+	metadataReportFile = "metadata_report.txt"
+	sidecarReportFile  = "xmp_sidecar_report.txt"
+)
+
+// WriteMetadataReport lists every file exiftool reported problems for in
+// <outRoot>/metadata_report.txt and returns the report path.
+func WriteMetadataReport(outRoot string, failures []metadata.WriteFailure) (string, error) {
+	if len(failures) == 0 {
+		return "", nil
+	}
+	sorted := append([]metadata.WriteFailure(nil), failures...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+	var b strings.Builder
+	for _, f := range sorted {
+		status := "WARNING"
+		if f.Fatal {
+			status = "FAILED"
+		}
+		fmt.Fprintf(&b, "%s\t%s\n", status, f.Path)
+		for _, msg := range f.Messages {
+			fmt.Fprintf(&b, "\t%s\n", msg)
+		}
+	}
+	path := filepath.Join(outRoot, metadataReportFile)
+	return path, fsutil.WriteFile(path, []byte(b.String()))
+}
+
+// WriteSidecarReport lists the files whose metadata went to an XMP sidecar
+// in <outRoot>/xmp_sidecar_report.txt and returns the report path.
+func WriteSidecarReport(outRoot string, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	var b strings.Builder
+	for _, path := range sorted {
+		fmt.Fprintf(&b, "%s\t%s\n", path, metadata.SidecarPath(path))
+	}
+	path := filepath.Join(outRoot, sidecarReportFile)
+	return path, fsutil.WriteFile(path, []byte(b.String()))
+}
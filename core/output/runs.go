@@ -0,0 +1,195 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+const (
+	runsFolder      = "runs"
+	runManifestFile = "manifest.json"
+	runSummaryFile  = "summary.txt"
+	runIDLayout     = "20060102-150405"
+)
+
+// RunEntry is one output file of a recorded run.
+type RunEntry struct {
+	Hash       string `json:"hash,omitempty"`       // source content hash
+	Path       string `json:"path"`                 // relative to the output root
+	Source     string `json:"source"`               // relative to the Takeout root
+	Taken      string `json:"taken,omitempty"`      // RFC3339
+	Duplicates int    `json:"duplicates,omitempty"` // identical copies merged into this file
+}
+
+// RunInfo describes a recorded run for `gphotos runs list`.
+type RunInfo struct {
+	ID         string
+	Files      int
+	Duplicates int
+	Summary    []string
+}
+
+// RecordRun stores the summary and a manifest of every copied photo under
+// <outRoot>/.gphotos/runs/<id>/ and returns the new run id.
+func RecordRun(outRoot, inRoot string, photos []*models.Photo, summary []string) (string, error) {
+	base := filepath.Join(outRoot, ".gphotos", runsFolder)
+	id := time.Now().Format(runIDLayout)
+	dir := filepath.Join(base, id)
+	for n := 2; ; n++ {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", time.Now().Format(runIDLayout), n)
+		dir = filepath.Join(base, id)
+	}
+	if err := fsutil.MkdirAll(dir); err != nil {
+		return "", err
+	}
+
+	var entries []RunEntry
+	for _, p := range photos {
+		if p == nil || p.DstPath == "" {
+			continue
+		}
+		rel, err := filepath.Rel(outRoot, p.DstPath)
+		if err != nil {
+			continue
+		}
+		entry := RunEntry{
+			Hash:   p.Hash,
+			Path:   filepath.ToSlash(rel),
+			Source: p.Meta.SourcePath,
+			Taken:  p.Meta.TakenTime,
+		}
+		if entry.Source == "" {
+			entry.Source = relSlash(inRoot, p.SrcPath)
+		}
+		if len(p.Copies) > 1 {
+			entry.Duplicates = len(p.Copies) - 1
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := fsutil.WriteFile(filepath.Join(dir, runManifestFile), data); err != nil {
+		return "", err
+	}
+	text := strings.Join(summary, "\n") + "\n"
+	return id, fsutil.WriteFile(filepath.Join(dir, runSummaryFile), []byte(text))
+}
+
+// ListRuns returns the recorded runs of an output root, oldest first.
+func ListRuns(outRoot string) ([]RunInfo, error) {
+	base := filepath.Join(outRoot, ".gphotos", runsFolder)
+	dirs, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var runs []RunInfo
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entries, err := LoadRunManifest(outRoot, d.Name())
+		if err != nil {
+			return nil, err
+		}
+		info := RunInfo{ID: d.Name(), Files: len(entries)}
+		for _, e := range entries {
+			info.Duplicates += e.Duplicates
+		}
+		if data, err := os.ReadFile(filepath.Join(base, d.Name(), runSummaryFile)); err == nil {
+			info.Summary = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		}
+		runs = append(runs, info)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ID < runs[j].ID
+	})
+	return runs, nil
+}
+
+// LoadRunManifest reads the manifest of one recorded run.
+func LoadRunManifest(outRoot, id string) ([]RunEntry, error) {
+	data, err := os.ReadFile(filepath.Join(outRoot, ".gphotos", runsFolder, id, runManifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no recorded run %q", id)
+		}
+		return nil, err
+	}
+	var entries []RunEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// RunChange is a file present in both runs with its entry in each.
+type RunChange struct {
+	Old, New RunEntry
+}
+
+// RunDiff is the difference between two recorded runs. Files are matched
+// by content hash, or by source path when they were not hashed. Dates
+// lists files whose taken date changed, Duplicates those that had a
+// different number of identical copies merged into them.
+type RunDiff struct {
+	Added      []RunEntry
+	Removed    []RunEntry
+	Dates      []RunChange
+	Duplicates []RunChange
+}
+
+// DiffRuns compares the manifest of an older run with a newer one.
+func DiffRuns(older, newer []RunEntry) RunDiff {
+	key := func(e RunEntry) string {
+		if e.Hash != "" {
+			return e.Hash
+		}
+		return "source:" + e.Source
+	}
+	before := make(map[string]RunEntry, len(older))
+	for _, e := range older {
+		before[key(e)] = e
+	}
+	var diff RunDiff
+	seen := make(map[string]bool, len(newer))
+	for _, e := range newer {
+		k := key(e)
+		seen[k] = true
+		old, ok := before[k]
+		if !ok {
+			diff.Added = append(diff.Added, e)
+			continue
+		}
+		if old.Taken != e.Taken {
+			diff.Dates = append(diff.Dates, RunChange{Old: old, New: e})
+		}
+		if old.Duplicates != e.Duplicates {
+			diff.Duplicates = append(diff.Duplicates, RunChange{Old: old, New: e})
+		}
+	}
+	for _, e := range older {
+		if !seen[key(e)] {
+			diff.Removed = append(diff.Removed, e)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,98 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+const (
+	pauseFile   = "pause"
+	stoppedFile = "stopped"
+)
+
+// pausePoll is how often a paused run checks whether it may continue.
+const pausePoll = 5 * time.Second
+
+// PausePath is the file that, while it exists, pauses the copy into outRoot
+// (see `gphotos pause`).
+func PausePath(outRoot string) string {
+	return filepath.Join(outRoot, ".gphotos", pauseFile)
+}
+
+// mayStart reports whether the next file may be started: false once
+// opts.Deadline has passed. While opts.PauseFile exists it waits, checking
+// again every pausePoll.
+func mayStart(opts Options) bool {
+	paused := false
+	for {
+		if !opts.Deadline.IsZero() && !time.Now().Before(opts.Deadline) {
+			return false
+		}
+		if opts.PauseFile == "" {
+			return true
+		}
+		if _, err := os.Stat(opts.PauseFile); err != nil {
+			if paused {
+				fmt.Println("Resumed.")
+			}
+			return true
+		}
+		if !paused {
+			fmt.Printf("\nPaused; remove %s or run gphotos resume to continue.\n", opts.PauseFile)
+			paused = true
+		}
+		time.Sleep(pausePoll)
+	}
+}
+
+// MarkStopped records that a run into outRoot stopped before copying
+// everything, so the next run resumes instead of copying again.
+func MarkStopped(outRoot string) error {
+	path := filepath.Join(outRoot, ".gphotos", stoppedFile)
+	if err := fsutil.MkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return fsutil.WriteFile(path, []byte(time.Now().Format(time.RFC3339)+"\n"))
+}
+
+// ClearStopped removes the mark left by MarkStopped once a run finished.
+func ClearStopped(outRoot string) error {
+	err := os.Remove(filepath.Join(outRoot, ".gphotos", stoppedFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ResumeStopped continues a run that MarkStopped recorded: photos whose
+// content the library index lists at a file that still exists get that
+// DstPath and are left out of the returned list, so only the rest is
+// copied. Without a mark every photo is returned.
+func ResumeStopped(outRoot string, photos []*models.Photo) ([]*models.Photo, int, error) {
+	if _, err := os.Stat(filepath.Join(outRoot, ".gphotos", stoppedFile)); err != nil {
+		return photos, 0, nil
+	}
+	index, err := loadLibraryIndex(outRoot)
+	if err != nil {
+		return nil, 0, err
+	}
+	todo := make([]*models.Photo, 0, len(photos))
+	done := 0
+	for _, p := range photos {
+		if rel, ok := index[p.Hash]; ok && p.Hash != "" {
+			path := filepath.Join(outRoot, rel)
+			if _, err := os.Stat(path); err == nil {
+				p.DstPath = path
+				done++
+				continue
+			}
+		}
+		todo = append(todo, p)
+	}
+	return todo, done, nil
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gphotos/core/dedup"
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+)
+
+// Extended attributes written by WriteChecksumXattrs. The shatag names let
+// shatag verify the files without rehashing the first time.
+const (
+	xattrSHA256     = "user.gphotos.sha256"
+	xattrTaken      = "user.gphotos.taken"
+	xattrShatagHash = "user.shatag.sha256"
+	xattrShatagTS   = "user.shatag.ts"
+)
+
+// WriteChecksumXattrs stores each copied file's SHA-256 and taken date in
+// extended attributes for later bit-rot checks. Like WriteChecksums it must
+// run after metadata writes have finished. It stops at the first file whose
+// filesystem rejects xattrs.
+func WriteChecksumXattrs(photos []*models.Photo, progress func(done, total int)) (int, error) {
+	total := len(photos)
+	tagged := 0
+	for i, p := range photos {
+		if progress != nil {
+			progress(i+1, total)
+		}
+		if p == nil || p.DstPath == "" {
+			continue
+		}
+		sum, err := dedup.HashFile(p.DstPath)
+		if err != nil {
+			return tagged, err
+		}
+		info, err := os.Stat(p.DstPath)
+		if err != nil {
+			return tagged, err
+		}
+		mtime := info.ModTime()
+		attrs := [][2]string{
+			{xattrSHA256, sum},
+			{xattrShatagHash, sum},
+			{xattrShatagTS, fmt.Sprintf("%d.%09d", mtime.Unix(), mtime.Nanosecond())},
+		}
+		if taken := strings.TrimSpace(p.Meta.TakenTime); taken != "" {
+			attrs = append(attrs, [2]string{xattrTaken, taken})
+		}
+		for _, a := range attrs {
+			if err := fsutil.SetXattr(p.DstPath, a[0], a[1]); err != nil {
+				return tagged, fmt.Errorf("%s: %w", p.DstPath, err)
+			}
+		}
+		tagged++
+	}
+	return tagged, nil
+}
@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// amazonWrappers are the folders an Amazon Photos download nests the
+// library in; they are not albums.
+var amazonWrappers = map[string]bool{
+	"amazon photos downloads": true,
+	"amazon photos":           true,
+	"pictures":                true,
+	"videos":                  true,
+}
+
+// amazonImporter reads an Amazon Photos download. Amazon ships the
+// originals without metadata files, so dates come from EXIF and filenames;
+// the first folder below the download wrappers is taken as the album, which
+// is how album downloads are laid out.
+type amazonImporter struct{}
+
+func (amazonImporter) Name() string   { return "amazon" }
+func (amazonImporter) Sidecars() bool { return false }
+
+func (amazonImporter) Scan(root string, verbose bool) ([]FilePair, []Unreadable, error) {
+	var pairs []FilePair
+	unreadable, err := walkRetrying(root, func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isMediaFile(strings.ToLower(path)) && !sniffMedia(path) {
+			return nil
+		}
+		pairs = append(pairs, FilePair{MediaPath: path, Album: amazonAlbum(root, path)})
+		if verbose {
+			rel, _ := filepath.Rel(root, path)
+			println("Scanned:", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if verbose {
+		println("Scan complete. Media files found:", len(pairs))
+	}
+	return pairs, unreadable, nil
+}
+
+func amazonAlbum(root, path string) string {
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return ""
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if !amazonWrappers[strings.ToLower(part)] {
+			return part
+		}
+	}
+	return ""
+}
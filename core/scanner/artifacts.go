@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Artifact is a file Takeout adds to a Photos export that is not part of
+// the library: the archive's index page, print orders, shared album
+// comments and other account data.
+type Artifact struct {
+	Path string
+	Kind string
+}
+
+// takeoutArtifactNames are the artifacts recognized by name, lowercased.
+// Their JSON is never a media sidecar.
+var takeoutArtifactNames = map[string]string{
+	"archive_browser.html":              "Takeout index page",
+	"print-subscriptions.json":          "print subscriptions",
+	"shared_album_comments.json":        "shared album comments",
+	"user-generated-memory-titles.json": "memory titles",
+}
+
+// takeoutArtifactExts are recognized by extension wherever they appear.
+var takeoutArtifactExts = map[string]string{
+	".html": "Takeout index page",
+	".pdf":  "print order",
+}
+
+// artifactKind reports whether path is a Takeout artifact and which kind.
+func artifactKind(path string) (string, bool) {
+	base := strings.ToLower(filepath.Base(path))
+	if kind, ok := takeoutArtifactNames[base]; ok {
+		return kind, true
+	}
+	kind, ok := takeoutArtifactExts[filepath.Ext(base)]
+	return kind, ok
+}
+
+// FindArtifacts lists the Takeout artifacts below root in path order.
+// Neither the scan nor the sidecar matching uses them, so they are never
+// copied or reported as unmatched.
+func FindArtifacts(root string) ([]Artifact, error) {
+	var found []Artifact
+	_, err := walkRetrying(root, func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		if kind, ok := artifactKind(path); ok {
+			found = append(found, Artifact{Path: path, Kind: kind})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	return found, nil
+}
@@ -0,0 +1,101 @@
+package scanner
+
+import (
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gphotos/core/fsutil"
+)
+
+// FolderCount is one Takeout folder as its listing and album metadata
+// describe it, independent of what the scan made of it.
+type FolderCount struct {
+	Dir string
+	// Files counts everything but JSON, Takeout artifacts and hidden files.
+	Files int
+	// Declared is the item count in the album's metadata.json, or -1 when
+	// the export has none.
+	Declared int
+}
+
+// CountTakeoutFolders lists the folders below root that hold files or
+// declare an item count, in path order.
+func CountTakeoutFolders(root string) ([]FolderCount, error) {
+	byDir := make(map[string]*FolderCount)
+	folder := func(dir string) *FolderCount {
+		c := byDir[dir]
+		if c == nil {
+			c = &FolderCount{Dir: dir, Declared: -1}
+			byDir[dir] = c
+		}
+		return c
+	}
+	_, err := walkRetrying(root, func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			// Hidden folders hold state such as .gphotos/, not media.
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if d.Name() == "metadata.json" {
+			if n, ok := declaredItems(path); ok {
+				folder(filepath.Dir(path)).Declared = n
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			return nil
+		}
+		if _, ok := artifactKind(path); ok {
+			return nil
+		}
+		folder(filepath.Dir(path)).Files++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]FolderCount, 0, len(byDir))
+	for _, c := range byDir {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Dir < counts[j].Dir })
+	return counts, nil
+}
+
+// declaredItems reads the item count some album metadata.json files carry,
+// as a number or a numeric string.
+func declaredItems(path string) (int, bool) {
+	data, err := fsutil.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	var payload struct {
+		MediaItemsCount json.Number `json:"mediaItemsCount"`
+	}
+	if json.Unmarshal(data, &payload) != nil || payload.MediaItemsCount == "" {
+		return 0, false
+	}
+	n, err := payload.MediaItemsCount.Int64()
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// CountPairsByDir counts scanned media per folder, for comparing with
+// CountTakeoutFolders.
+func CountPairsByDir(pairs []FilePair) map[string]int {
+	counts := make(map[string]int)
+	for _, p := range pairs {
+		counts[filepath.Dir(p.MediaPath)]++
+	}
+	return counts
+}
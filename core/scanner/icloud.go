@@ -0,0 +1,207 @@
+package scanner
+
+import (
+	"encoding/csv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gphotos/core/models"
+)
+
+// icloudDateLayouts are the originalCreationDate formats seen in iCloud's
+// "Photo Details.csv", e.g. "Thursday May 9,2019 8:21 PM GMT".
+var icloudDateLayouts = []string{
+	"Monday January 2,2006 3:04 PM MST",
+	"Monday January 2, 2006 3:04 PM MST",
+	"Monday January 2,2006 3:04:05 PM MST",
+	"Monday January 2, 2006 3:04:05 PM MST",
+}
+
+// icloudImporter reads Apple's "Data & Privacy" iCloud Photos export: the
+// originals in "iCloud Photos Part N of M/Photos/", a "Photo Details*.csv"
+// with dates and flags next to them, and one CSV per album under Albums/
+// listing its file names.
+type icloudImporter struct{}
+
+func (icloudImporter) Name() string   { return "icloud" }
+func (icloudImporter) Sidecars() bool { return false }
+
+func (icloudImporter) Scan(root string, verbose bool) ([]FilePair, []Unreadable, error) {
+	var media []string
+	var details, albumLists []string
+	unreadable, err := walkRetrying(root, func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		lower := strings.ToLower(path)
+		if strings.HasSuffix(lower, ".csv") {
+			if strings.HasPrefix(strings.ToLower(d.Name()), "photo details") {
+				details = append(details, path)
+			} else if strings.EqualFold(filepath.Base(filepath.Dir(path)), "Albums") {
+				albumLists = append(albumLists, path)
+			}
+			return nil
+		}
+		if isMediaFile(lower) || sniffMedia(path) {
+			media = append(media, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// File names are unique within one folder of one part only, so rows are
+	// looked up by folder and name, then by part and name. The bare name
+	// is a last resort, used only when no other row or file shares it, so
+	// same-named files never borrow each other's dates, flags or albums.
+	byPath := make(map[string]*models.MetaData)
+	byPart := make(map[string]*models.MetaData)
+	byName := make(map[string]*models.MetaData)
+	rowNames := make(map[string]int)
+	for _, path := range details {
+		rows, err := readCSV(path)
+		if err != nil {
+			unreadable = append(unreadable, Unreadable{Path: path, Err: err})
+			continue
+		}
+		part := icloudPart(root, path)
+		for _, row := range rows {
+			name := row["imgName"]
+			if name == "" {
+				continue
+			}
+			meta := icloudMeta(row)
+			byPath[filepath.Join(filepath.Dir(path), name)] = meta
+			byPart[part+"\x00"+strings.ToLower(name)] = meta
+			byName[strings.ToLower(name)] = meta
+			rowNames[strings.ToLower(name)]++
+		}
+	}
+	albums := make(map[string][]string)
+	albumsByName := make(map[string][]string)
+	for _, path := range albumLists {
+		rows, err := readCSV(path)
+		if err != nil {
+			unreadable = append(unreadable, Unreadable{Path: path, Err: err})
+			continue
+		}
+		part := icloudPart(root, path)
+		album := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		for _, row := range rows {
+			if name := strings.ToLower(row["Images"]); name != "" {
+				albums[part+"\x00"+name] = append(albums[part+"\x00"+name], album)
+				albumsByName[name] = append(albumsByName[name], album)
+			}
+		}
+	}
+	mediaNames := make(map[string]int, len(media))
+	for _, path := range media {
+		mediaNames[strings.ToLower(filepath.Base(path))]++
+	}
+
+	pairs := make([]FilePair, 0, len(media))
+	for _, path := range media {
+		key := strings.ToLower(filepath.Base(path))
+		partKey := icloudPart(root, path) + "\x00" + key
+		unique := mediaNames[key] == 1
+		pair := FilePair{MediaPath: path, Meta: byPath[path]}
+		if pair.Meta == nil {
+			pair.Meta = byPart[partKey]
+		}
+		if pair.Meta == nil && unique && rowNames[key] == 1 {
+			pair.Meta = byName[key]
+		}
+		names := albums[partKey]
+		if len(names) == 0 && unique {
+			names = albumsByName[key]
+		}
+		if len(names) > 0 {
+			pair.Album = names[0]
+			pair.MoreAlbums = names[1:]
+		}
+		pairs = append(pairs, pair)
+		if verbose {
+			rel, _ := filepath.Rel(root, path)
+			println("Scanned:", rel)
+		}
+	}
+	if verbose {
+		println("Scan complete. Media files found:", len(pairs))
+	}
+	return pairs, unreadable, nil
+}
+
+// icloudPart returns the "iCloud Photos Part N of M" folder path lies in,
+// or root for exports that are not split into parts.
+func icloudPart(root, path string) string {
+	for dir := filepath.Dir(path); dir != root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if strings.HasPrefix(strings.ToLower(filepath.Base(dir)), "icloud photos part ") {
+			return dir
+		}
+	}
+	return root
+}
+
+// icloudMeta maps one "Photo Details" row. Hidden items are treated like
+// Google's archived ones and recently deleted like trashed.
+func icloudMeta(row map[string]string) *models.MetaData {
+	_, hasHidden := row["hidden"]
+	_, hasDeleted := row["deleted"]
+	meta := &models.MetaData{
+		Favorited:   strings.EqualFold(row["favorite"], "yes"),
+		Archived:    strings.EqualFold(row["hidden"], "yes"),
+		Trashed:     strings.EqualFold(row["deleted"], "yes"),
+		HasArchived: hasHidden,
+		HasTrashed:  hasDeleted,
+	}
+	for _, layout := range icloudDateLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(row["originalCreationDate"])); err == nil {
+			meta.TakenTime = t.UTC().Format(time.RFC3339)
+			break
+		}
+	}
+	return meta
+}
+
+// readCSV reads a CSV with a header row into one map per row.
+func readCSV(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	var rows []map[string]string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(rec) {
+				row[strings.TrimSpace(name)] = strings.TrimSpace(rec[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,105 @@
+package scanner
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Importer finds the media of one kind of export and pairs each file with
+// the metadata sidecar that export provides, if any. The rest of the
+// pipeline (hashing, dedup, dates, output) works on the returned pairs
+// whatever their source.
+type Importer interface {
+	// Name is the value selecting the importer with -source.
+	Name() string
+	// Scan walks root. Paths that stay unreadable are returned rather than
+	// ending the scan.
+	Scan(root string, verbose bool) ([]FilePair, []Unreadable, error)
+	// Sidecars reports whether the pairs can carry JSON sidecars, which
+	// turns on the Takeout-specific matching and overlap checks.
+	Sidecars() bool
+}
+
+var importers = map[string]Importer{}
+
+// RegisterImporter makes imp selectable by its name.
+func RegisterImporter(imp Importer) {
+	importers[imp.Name()] = imp
+}
+
+func init() {
+	RegisterImporter(takeoutImporter{})
+	RegisterImporter(folderImporter{})
+	RegisterImporter(icloudImporter{})
+	RegisterImporter(amazonImporter{})
+}
+
+// LookupImporter returns the importer registered under name.
+func LookupImporter(name string) (Importer, error) {
+	imp, ok := importers[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q (known: %s)", name, strings.Join(ImporterNames(), ", "))
+	}
+	return imp, nil
+}
+
+// ImporterNames lists the registered importers in order.
+func ImporterNames() []string {
+	names := make([]string, 0, len(importers))
+	for name := range importers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// takeoutImporter reads a Google Takeout export: media with JSON sidecars,
+// albums from the folder names.
+type takeoutImporter struct{}
+
+func (takeoutImporter) Name() string   { return "takeout" }
+func (takeoutImporter) Sidecars() bool { return true }
+
+func (takeoutImporter) Scan(root string, verbose bool) ([]FilePair, []Unreadable, error) {
+	return ScanTakeout(root, verbose)
+}
+
+// folderImporter reads an ordinary folder such as a camera or phone dump.
+// There are no sidecars, so dates come from EXIF and filenames, and
+// folders are not albums. Hidden folders (.thumbnails, .gphotos, ...) are
+// skipped.
+type folderImporter struct{}
+
+func (folderImporter) Name() string   { return "folder" }
+func (folderImporter) Sidecars() bool { return false }
+
+func (folderImporter) Scan(root string, verbose bool) ([]FilePair, []Unreadable, error) {
+	var pairs []FilePair
+	unreadable, err := walkRetrying(root, func(path string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isMediaFile(strings.ToLower(path)) && !sniffMedia(path) {
+			return nil
+		}
+		pairs = append(pairs, FilePair{MediaPath: path})
+		if verbose {
+			rel, _ := filepath.Rel(root, path)
+			println("Scanned:", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if verbose {
+		println("Scan complete. Media files found:", len(pairs))
+	}
+	return pairs, unreadable, nil
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Overlap records a media file that appeared under the same relative path in
+// more than one extracted Takeout part.
+type Overlap struct {
+	LogicalPath string
+	Kept        string
+	Dropped     []string
+	Conflicting []string
+}
+
+var takeoutPartRe = regexp.MustCompile(`(?i)^takeout([ _-].*)?$`)
+
+// CollapseOverlappingParts detects media that exists in several Takeout parts
+// (e.g. Takeout/ and "Takeout 2/" extracted side by side), verifies the copies
+// are byte-identical, and keeps a single pair per logical file. Copies whose
+// content differs are kept and reported as conflicting.
+func CollapseOverlappingParts(root string, pairs []FilePair) ([]FilePair, []Overlap) {
+	byLogical := make(map[string][]int)
+	var order []string
+	for i, p := range pairs {
+		key := logicalPath(root, p.MediaPath)
+		if _, ok := byLogical[key]; !ok {
+			order = append(order, key)
+		}
+		byLogical[key] = append(byLogical[key], i)
+	}
+
+	drop := make(map[int]bool)
+	var overlaps []Overlap
+	for _, key := range order {
+		idxs := byLogical[key]
+		if len(idxs) < 2 {
+			continue
+		}
+		sort.Slice(idxs, func(i, j int) bool {
+			return pairs[idxs[i]].MediaPath < pairs[idxs[j]].MediaPath
+		})
+		keep := idxs[0]
+		ov := Overlap{LogicalPath: key, Kept: pairs[keep].MediaPath}
+		for _, idx := range idxs[1:] {
+			same, err := sameContent(pairs[keep].MediaPath, pairs[idx].MediaPath)
+			if err != nil || !same {
+				ov.Conflicting = append(ov.Conflicting, pairs[idx].MediaPath)
+				continue
+			}
+			if pairs[keep].JsonPath == "" && pairs[idx].JsonPath != "" {
+				pairs[keep].JsonPath = pairs[idx].JsonPath
+			}
+			ov.Dropped = append(ov.Dropped, pairs[idx].MediaPath)
+			drop[idx] = true
+		}
+		overlaps = append(overlaps, ov)
+	}
+
+	if len(drop) == 0 {
+		return pairs, overlaps
+	}
+	out := make([]FilePair, 0, len(pairs)-len(drop))
+	for i, p := range pairs {
+		if !drop[i] {
+			out = append(out, p)
+		}
+	}
+	return out, overlaps
+}
+
+// logicalPath returns the path of a media file relative to its Takeout part,
+// so the same photo extracted from two zips yields the same key.
+func logicalPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		if strings.EqualFold(part, "Google Photos") {
+			return strings.ToLower(filepath.Join(parts[i:]...))
+		}
+	}
+	if len(parts) > 1 && takeoutPartRe.MatchString(parts[0]) {
+		return strings.ToLower(filepath.Join(parts[1:]...))
+	}
+	return strings.ToLower(rel)
+}
+
+func sameContent(a, b string) (bool, error) {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if ai.Size() != bi.Size() {
+		return false, nil
+	}
+
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
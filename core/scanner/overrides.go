@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LoadJSONOverrides reads a json_overrides.json file mapping media paths to
+// the sidecar they should use. Paths are relative to the Takeout root (or
+// absolute); an empty sidecar means "no sidecar".
+func LoadJSONOverrides(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	var overrides map[string]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if overrides == nil {
+		overrides = map[string]string{}
+	}
+	return overrides, nil
+}
+
+// ApplyJSONOverrides pins the sidecar of every pair listed in overrides and
+// returns how many pairs were changed.
+func ApplyJSONOverrides(root string, pairs []FilePair, overrides map[string]string) int {
+	if len(overrides) == 0 {
+		return 0
+	}
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return filepath.Clean(p)
+		}
+		return filepath.Join(root, filepath.FromSlash(p))
+	}
+	pinned := make(map[string]string, len(overrides))
+	for media, sidecar := range overrides {
+		if sidecar == "" {
+			pinned[resolve(media)] = ""
+			continue
+		}
+		pinned[resolve(media)] = resolve(sidecar)
+	}
+	applied := 0
+	for i := range pairs {
+		sidecar, ok := pinned[filepath.Clean(pairs[i].MediaPath)]
+		if !ok {
+			continue
+		}
+		pairs[i].JsonPath = sidecar
+		pairs[i].JSONMatch = ""
+		applied++
+	}
+	return applied
+}
+
+// WriteJSONMatchReport lists the low-confidence sidecar matches in pairs,
+// one "media<TAB>sidecar<TAB>heuristic" line each with paths relative to
+// root, and returns how many were listed. A stale report is removed when
+// there are none.
+func WriteJSONMatchReport(root string, pairs []FilePair, path string) (int, error) {
+	var lines []string
+	for _, p := range pairs {
+		if p.JSONMatch == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s", relTo(root, p.MediaPath), relTo(root, p.JsonPath), p.JSONMatch))
+	}
+	if len(lines) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+		return 0, nil
+	}
+	sort.Strings(lines)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	return len(lines), os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+}
+
+func relTo(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -2,17 +2,30 @@ package scanner
 
 import (
 	"encoding/json"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
 )
 
 type FilePair struct {
 	MediaPath string
 	JsonPath  string
 	Album     string
+	// JSONMatch is set when JsonPath was found by a heuristic and may be
+	// wrong; it names the heuristic.
+	JSONMatch string
+	// Meta is metadata an importer read from something other than a JSON
+	// sidecar (e.g. a CSV); it is used like a sidecar.
+	Meta *models.MetaData
+	// MoreAlbums lists further albums for exports that record album
+	// membership separately from the folder layout.
+	MoreAlbums []string
 }
 
 type jsonTitleEntry struct {
@@ -20,7 +33,17 @@ type jsonTitleEntry struct {
 	Path  string
 }
 
-func ScanTakeout(root string, verbose bool) ([]FilePair, error) {
+// Unreadable is a directory or file the scan had to give up on after
+// retrying.
+type Unreadable struct {
+	Path string
+	Err  error
+}
+
+// ScanTakeout walks root and pairs every media file with its JSON sidecar.
+// Transient errors (network share hiccups) are retried with backoff; paths
+// that stay unreadable are returned rather than silently skipped.
+func ScanTakeout(root string, verbose bool) ([]FilePair, []Unreadable, error) {
 	var pairs []FilePair
 	var media []FilePair
 	jsonByTitle := make(map[string][]string)
@@ -29,11 +52,7 @@ func ScanTakeout(root string, verbose bool) ([]FilePair, error) {
 	jsonByNorm := make(map[string][]string)
 	found := 0
 
-	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-
+	unreadable, err := walkRetrying(root, func(path string, d fs.DirEntry) error {
 		if d.IsDir() {
 			return nil
 		}
@@ -42,27 +61,32 @@ func ScanTakeout(root string, verbose bool) ([]FilePair, error) {
 
 		if strings.HasSuffix(lower, ".json") {
 			base := filepath.Base(path)
-			if base != "metadata.json" {
-				if title, ok := extractJSONTitle(path); ok && title != "" {
-					key := strings.ToLower(title)
-					jsonByTitle[key] = append(jsonByTitle[key], path)
-					dir := filepath.Dir(path)
-					jsonByDir[dir] = append(jsonByDir[dir], jsonTitleEntry{
-						Title: title,
-						Path:  path,
-					})
-					if norm := normalizeBaseForMatch(stripExt(title)); norm != "" {
-						jsonByNorm[norm] = append(jsonByNorm[norm], path)
-					}
-				}
-				if key := normalizeJSONKey(base); key != "" {
-					jsonByKey[key] = append(jsonByKey[key], path)
+			if base == "metadata.json" {
+				return nil
+			}
+			if _, ok := artifactKind(path); ok {
+				return nil
+			}
+			title, ok, readErr := extractJSONTitle(path)
+			if ok && title != "" {
+				key := strings.ToLower(title)
+				jsonByTitle[key] = append(jsonByTitle[key], path)
+				dir := filepath.Dir(path)
+				jsonByDir[dir] = append(jsonByDir[dir], jsonTitleEntry{
+					Title: title,
+					Path:  path,
+				})
+				if norm := normalizeBaseForMatch(stripExt(title)); norm != "" {
+					jsonByNorm[norm] = append(jsonByNorm[norm], path)
 				}
 			}
-			return nil
+			if key := normalizeJSONKey(base); key != "" {
+				jsonByKey[key] = append(jsonByKey[key], path)
+			}
+			return readErr
 		}
 
-		if isMediaFile(lower) {
+		if isMediaFile(lower) || sniffMedia(path) {
 			album := detectAlbum(root, path)
 			media = append(media, FilePair{
 				MediaPath: path,
@@ -78,16 +102,71 @@ func ScanTakeout(root string, verbose bool) ([]FilePair, error) {
 
 		return nil
 	})
+	if err != nil {
+		return nil, nil, err
+	}
 
 	for _, m := range media {
-		m.JsonPath = resolveJSONPath(m.MediaPath, jsonByTitle, jsonByKey, jsonByDir, jsonByNorm)
+		m.JsonPath, m.JSONMatch = resolveJSONPath(m.MediaPath, jsonByTitle, jsonByKey, jsonByDir, jsonByNorm)
 		pairs = append(pairs, m)
 	}
 
 	if verbose {
 		println("Scan complete. Media files found:", found)
 	}
-	return pairs, nil
+	return pairs, unreadable, nil
+}
+
+// walkRetrying walks root and calls visit for every entry. Directories that
+// fail to read are retried with backoff. Paths that stay unreadable, and
+// paths visit returns an error for, are collected instead of ending the
+// walk; visit may return fs.SkipDir to skip a directory.
+func walkRetrying(root string, visit func(path string, d fs.DirEntry) error) ([]Unreadable, error) {
+	var unreadable []Unreadable
+	var walk fs.WalkDirFunc
+	walk = func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && d == nil {
+				return err
+			}
+			if d != nil && d.IsDir() {
+				// WalkDir already gave up on this directory's entries; if it
+				// becomes readable after backoff, walk it again ourselves.
+				retryErr := fsutil.Retry(func() error {
+					_, err := os.ReadDir(path)
+					return err
+				})
+				if retryErr == nil {
+					return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+						if p == path && err == nil {
+							return nil
+						}
+						return walk(p, d, err)
+					})
+				}
+				err = retryErr
+			}
+			unreadable = append(unreadable, Unreadable{Path: path, Err: err})
+			return nil
+		}
+		if err := visit(path, d); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				return err
+			}
+			unreadable = append(unreadable, Unreadable{Path: path, Err: err})
+		}
+		return nil
+	}
+	if err := filepath.WalkDir(root, walk); err != nil {
+		return nil, err
+	}
+	return unreadable, nil
+}
+
+// IsMediaFile reports whether path has one of the media extensions the
+// scanner picks up.
+func IsMediaFile(path string) bool {
+	return isMediaFile(strings.ToLower(path))
 }
 
 func isMediaFile(lowerPath string) bool {
@@ -108,46 +187,96 @@ func isMediaFile(lowerPath string) bool {
 		strings.HasSuffix(lowerPath, ".mp~3")
 }
 
-func resolveJSONPath(mediaPath string, jsonByTitle map[string][]string, jsonByKey map[string][]string, jsonByDir map[string][]jsonTitleEntry, jsonByNorm map[string][]string) string {
+// Reasons a sidecar match is reported as low confidence.
+const (
+	matchFirstOfMany = "first of several candidates"
+	matchPrefix      = "title prefix"
+	matchTruncated   = "truncated name"
+	matchNormalized  = "normalized name"
+)
+
+// resolveJSONPath finds the sidecar for mediaPath. The second result is
+// empty for confident matches and names the heuristic otherwise.
+func resolveJSONPath(mediaPath string, jsonByTitle map[string][]string, jsonByKey map[string][]string, jsonByDir map[string][]jsonTitleEntry, jsonByNorm map[string][]string) (string, string) {
 	base := filepath.Base(mediaPath)
 	baseNoExt := stripExt(base)
 	baseLower := strings.ToLower(base)
 	baseNoExtLower := strings.ToLower(baseNoExt)
 	extLower := strings.ToLower(filepath.Ext(base))
 
-	if path := pickCandidate(jsonByTitle[baseLower], base); path != "" {
-		return path
+	pick := func(candidates []string, base string) (string, string) {
+		path, ambiguous := pickCandidate(candidates, base)
+		if ambiguous {
+			return path, matchFirstOfMany
+		}
+		return path, ""
 	}
-	if path := pickCandidate(jsonByTitle[baseNoExtLower], base); path != "" {
-		return path
+
+	// Sidecars in the media's own folder come first: the title and key
+	// indexes span the whole export, so a repeated name in another album
+	// would otherwise win.
+	dir := filepath.Dir(mediaPath)
+	local := func(candidates []string) []string {
+		var out []string
+		for _, c := range candidates {
+			if filepath.Dir(c) == dir {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	for _, candidates := range [][]string{jsonByTitle[baseLower], jsonByTitle[baseNoExtLower]} {
+		if path, reason := pick(local(candidates), base); path != "" {
+			return path, reason
+		}
+	}
+	for _, key := range mediaKeys(base) {
+		if path, reason := pick(local(jsonByKey[key]), base); path != "" {
+			return path, reason
+		}
+	}
+
+	if path, reason := pick(jsonByTitle[baseLower], base); path != "" {
+		return path, reason
+	}
+	if path, reason := pick(jsonByTitle[baseNoExtLower], base); path != "" {
+		return path, reason
+	}
+	if extLower == "" {
+		if path := pickExtensionlessCandidate(mediaPath, jsonByDir); path != "" {
+			return path, ""
+		}
 	}
 	if extLower == ".mp" {
-		if path := pickCandidate(jsonByTitle[strings.ToLower(base+".jpg")], base); path != "" {
-			return path
+		if path, reason := pick(jsonByTitle[strings.ToLower(base+".jpg")], base); path != "" {
+			return path, reason
 		}
-		if path := pickCandidate(jsonByTitle[strings.ToLower(base+".jpeg")], base); path != "" {
-			return path
+		if path, reason := pick(jsonByTitle[strings.ToLower(base+".jpeg")], base); path != "" {
+			return path, reason
 		}
 	}
 
 	if path := pickLivePhotoSiblingJSON(mediaPath, jsonByTitle); path != "" {
-		return path
+		return path, ""
 	}
 
 	for _, key := range mediaKeys(base) {
-		if path := pickCandidate(jsonByKey[key], base); path != "" {
-			return path
+		if path, reason := pick(jsonByKey[key], base); path != "" {
+			return path, reason
 		}
 	}
 	if path := pickPrefixCandidate(mediaPath, jsonByDir); path != "" {
-		return path
+		return path, matchPrefix
+	}
+	if path := pickTruncatedCandidate(mediaPath, jsonByDir); path != "" {
+		return path, matchTruncated
 	}
 	if norm := normalizeBaseForMatch(baseNoExt); norm != "" {
-		if path := pickCandidate(jsonByNorm[norm], base); path != "" {
-			return path
+		if path, _ := pickCandidate(jsonByNorm[norm], base); path != "" {
+			return path, matchNormalized
 		}
 	}
-	return ""
+	return "", ""
 }
 
 func stripExt(path string) string {
@@ -221,37 +350,41 @@ func dedupeKeys(keys []string) []string {
 	return out
 }
 
-func extractJSONTitle(path string) (string, bool) {
-	data, err := os.ReadFile(path)
+// extractJSONTitle returns the sidecar's title. The error is only set when
+// the file could not be read; malformed JSON just yields no title.
+func extractJSONTitle(path string) (string, bool, error) {
+	data, err := fsutil.ReadFile(path)
 	if err != nil {
-		return "", false
+		return "", false, err
 	}
 	var payload struct {
 		Title string `json:"title"`
 	}
 	if err := json.Unmarshal(data, &payload); err != nil {
-		return "", false
+		return "", false, nil
 	}
 	if payload.Title == "" {
-		return "", false
+		return "", false, nil
 	}
-	return payload.Title, true
+	return payload.Title, true, nil
 }
 
-func pickCandidate(candidates []string, base string) string {
+// pickCandidate chooses among sidecars indexed under the same key. It
+// reports ambiguous when several exist and none is named after base.
+func pickCandidate(candidates []string, base string) (string, bool) {
 	if len(candidates) == 0 {
-		return ""
+		return "", false
 	}
 	if len(candidates) == 1 {
-		return candidates[0]
+		return candidates[0], false
 	}
 	for _, c := range candidates {
 		name := filepath.Base(c)
 		if matchesMetadataName(name, base) {
-			return c
+			return c, false
 		}
 	}
-	return candidates[0]
+	return candidates[0], true
 }
 
 func pickLivePhotoSiblingJSON(mediaPath string, jsonByTitle map[string][]string) string {
@@ -265,13 +398,75 @@ func pickLivePhotoSiblingJSON(mediaPath string, jsonByTitle map[string][]string)
 	exts := []string{".heic", ".jpg", ".jpeg", ".png"}
 	for _, e := range exts {
 		title := baseNoExt + e
-		if path := pickCandidate(jsonByTitle[title], title); path != "" {
+		if path, _ := pickCandidate(jsonByTitle[title], title); path != "" {
 			return path
 		}
 	}
 	return ""
 }
 
+// truncatedJSONNameLen is the shortest sidecar file name (with ".json")
+// Google is known to cut long names down to; the limit is 46-51 characters
+// depending on the export.
+const truncatedJSONNameLen = 46
+
+var trailingIndexRe = regexp.MustCompile(`\((\d+)\)$`)
+
+// pickTruncatedCandidate matches sidecars whose file names Google shortened,
+// e.g. "a_very_long_file_name_from_some_app_2019.jpg.supplemen.json" for
+// "a_very_long_file_name_from_some_app_2019.jpg", or a cut inside the media
+// name itself. Duplicate indexes move to the end of the truncated name
+// ("...supplemen(1).json" for "name(1).jpg"). Only names at the truncation
+// length are considered, and the longest matching stem wins.
+func pickTruncatedCandidate(mediaPath string, jsonByDir map[string][]jsonTitleEntry) string {
+	base := strings.ToLower(filepath.Base(mediaPath))
+	ext := filepath.Ext(base)
+	index := ""
+	if m := trailingIndexRe.FindString(stripExt(base)); m != "" {
+		index = m
+		base = strings.TrimSuffix(stripExt(base), m) + ext
+	}
+	full := base + ".supplemental-metadata"
+
+	best := ""
+	bestLen := 0
+	for _, entry := range jsonByDir[filepath.Dir(mediaPath)] {
+		name := strings.ToLower(filepath.Base(entry.Path))
+		if len(name) < truncatedJSONNameLen {
+			continue
+		}
+		stem := strings.TrimSuffix(name, ".json")
+		if index != "" {
+			if !strings.HasSuffix(stem, index) {
+				continue
+			}
+			stem = strings.TrimSuffix(stem, index)
+		} else if trailingIndexRe.MatchString(stem) {
+			continue
+		}
+		if !strings.HasPrefix(full, stem) {
+			continue
+		}
+		if len(stem) > bestLen {
+			best = entry.Path
+			bestLen = len(stem)
+		}
+	}
+	return best
+}
+
+// pickExtensionlessCandidate matches a media file without extension to a
+// sidecar in the same folder whose title is the same name plus an extension.
+func pickExtensionlessCandidate(mediaPath string, jsonByDir map[string][]jsonTitleEntry) string {
+	base := strings.ToLower(filepath.Base(mediaPath))
+	for _, entry := range jsonByDir[filepath.Dir(mediaPath)] {
+		if strings.ToLower(stripExt(entry.Title)) == base {
+			return entry.Path
+		}
+	}
+	return ""
+}
+
 func pickPrefixCandidate(mediaPath string, jsonByDir map[string][]jsonTitleEntry) string {
 	dir := filepath.Dir(mediaPath)
 	entries := jsonByDir[dir]
@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"bytes"
+	"os"
+)
+
+// sniffMedia reads the first bytes of path and reports whether they look
+// like a photo or video, whatever the extension says. WhatsApp and other
+// exports often produce media with missing or wrong extensions.
+func sniffMedia(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, 16)
+	n, _ := f.Read(buf)
+	return isMediaSignature(buf[:n])
+}
+
+func isMediaSignature(buf []byte) bool {
+	if len(buf) < 12 {
+		return false
+	}
+	switch {
+	case buf[0] == 0xFF && buf[1] == 0xD8 && buf[2] == 0xFF:
+		return true // JPEG
+	case bytes.HasPrefix(buf, []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A}):
+		return true
+	case bytes.HasPrefix(buf, []byte("GIF87a")), bytes.HasPrefix(buf, []byte("GIF89a")):
+		return true
+	case string(buf[0:4]) == "RIFF" && (string(buf[8:12]) == "WEBP" || string(buf[8:12]) == "AVI "):
+		return true
+	case string(buf[4:8]) == "ftyp":
+		// ISO BMFF covers HEIC/AVIF as well as MP4, MOV, M4V and 3GP.
+		return true
+	case string(buf[4:8]) == "moov", string(buf[4:8]) == "mdat", string(buf[4:8]) == "wide":
+		return true // older QuickTime files without ftyp
+	case bytes.HasPrefix(buf, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return true // Matroska / WebM
+	case bytes.HasPrefix(buf, []byte("II*\x00")), bytes.HasPrefix(buf, []byte("MM\x00*")):
+		return true // TIFF-based raw (DNG, NEF)
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+// Package spill keeps large intermediate lists on disk instead of in
+// memory, for runs started with -memory-target.
+package spill
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gphotos/core/fsutil"
+)
+
+// segmentRecords is how many values go into one segment file, so reading
+// back never needs more than one open file and one small buffer.
+const segmentRecords = 4096
+
+// Log is an append-only list of values gob-encoded into segment files in a
+// private folder. It is not safe for concurrent use.
+type Log[T any] struct {
+	dir      string
+	segments []string
+	file     *os.File
+	buf      *bufio.Writer
+	enc      *gob.Encoder
+	inSeg    int
+	n        int
+}
+
+// New creates an empty log in a new folder below parent.
+func New[T any](parent string) (*Log[T], error) {
+	if err := fsutil.MkdirAll(parent); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(parent, "spill-")
+	if err != nil {
+		return nil, err
+	}
+	return &Log[T]{dir: dir}, nil
+}
+
+// Append writes v to the current segment, starting a new one when it is
+// full.
+func (l *Log[T]) Append(v T) error {
+	if l.file == nil || l.inSeg == segmentRecords {
+		if err := l.closeSegment(); err != nil {
+			return err
+		}
+		path := filepath.Join(l.dir, strconv.Itoa(len(l.segments))+".gob")
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		l.segments = append(l.segments, path)
+		l.file, l.buf, l.inSeg = f, bufio.NewWriter(f), 0
+		// Every segment is its own gob stream, so it decodes on its own.
+		l.enc = gob.NewEncoder(l.buf)
+	}
+	if err := l.enc.Encode(&v); err != nil {
+		return err
+	}
+	l.inSeg++
+	l.n++
+	return nil
+}
+
+// Len is the number of values appended.
+func (l *Log[T]) Len() int {
+	return l.n
+}
+
+// Each calls fn for every value in the order appended and stops at the
+// first error.
+func (l *Log[T]) Each(fn func(v T) error) error {
+	if err := l.closeSegment(); err != nil {
+		return err
+	}
+	for _, path := range l.segments {
+		if err := eachInSegment(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func eachInSegment[T any](path string, fn func(v T) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	for {
+		var v T
+		if err := dec.Decode(&v); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
+
+// Remove deletes the log's folder. The log cannot be used afterwards.
+func (l *Log[T]) Remove() error {
+	err := l.closeSegment()
+	if rmErr := os.RemoveAll(l.dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+func (l *Log[T]) closeSegment() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.buf.Flush()
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	l.file, l.buf, l.enc = nil, nil, nil
+	return err
+}
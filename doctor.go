@@ -0,0 +1,242 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gphotos/core/console"
+	"gphotos/core/fsutil"
+	"gphotos/core/metadata"
+)
+
+type doctorCheck struct {
+	name string
+	ok   bool
+	info string
+	fix  string
+}
+
+// runDoctor implements `gphotos doctor`, which checks the environment a run
+// depends on and prints how to fix anything that is missing.
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	outRoot := fs.String("out", "./Output", "Destination folder to check")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	var checks []doctorCheck
+	checks = append(checks, checkExiftool())
+
+	probeRoot, err := existingAncestor(*outRoot)
+	var probeDir string
+	var cleanup func()
+	if err == nil {
+		probeDir, cleanup, err = makeProbeDir(probeRoot)
+	}
+	if err != nil {
+		checks = append(checks, doctorCheck{
+			name: "Destination writable",
+			info: err.Error(),
+			fix:  fmt.Sprintf("Create %s or choose a folder you can write to.", *outRoot),
+		})
+	} else {
+		defer cleanup()
+		info := *outRoot
+		if filepath.Clean(probeRoot) != filepath.Clean(*outRoot) {
+			info = fmt.Sprintf("%s (does not exist yet; checked %s)", *outRoot, probeRoot)
+		}
+		checks = append(checks,
+			doctorCheck{name: "Destination writable", ok: true, info: info},
+			checkFreeSpace(probeRoot),
+			checkHardlink(probeDir),
+			checkSymlink(probeDir),
+			checkReflink(probeDir),
+			checkXattr(probeDir),
+			checkLongPaths(probeDir),
+		)
+	}
+
+	failed := 0
+	for _, c := range checks {
+		status := console.Success("ok  ")
+		if !c.ok {
+			status = console.Warn("FAIL")
+			failed++
+		}
+		fmt.Printf("[%s] %s: %s\n", status, c.name, c.info)
+		if !c.ok && c.fix != "" {
+			fmt.Printf("       fix: %s\n", c.fix)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%d check(s) need attention.\n", failed)
+		return exitFailure
+	}
+	fmt.Println(console.Success("Environment looks good."))
+	return exitOK
+}
+
+func checkExiftool() doctorCheck {
+	c := doctorCheck{name: "exiftool"}
+	version, ok := metadata.ExiftoolVersion()
+	if !ok {
+		c.info = "not found in PATH"
+		c.fix = "Install exiftool (https://exiftool.org) so dates, GPS, and descriptions can be written."
+		return c
+	}
+	c.ok = true
+	c.info = "version " + version
+	if v, err := strconv.ParseFloat(version, 64); err == nil && v < 12 {
+		c.ok = false
+		c.fix = "Upgrade exiftool to 12.0 or newer for reliable HEIC and video support."
+	}
+	return c
+}
+
+// existingAncestor returns path, or its nearest parent that exists when it
+// does not, so doctor can probe a destination without creating it.
+func existingAncestor(path string) (string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return "", fmt.Errorf("%s is not a folder", dir)
+			}
+			return dir, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		dir = parent
+	}
+}
+
+// makeProbeDir creates a temporary folder inside dir for the filesystem
+// checks; cleanup removes it again.
+func makeProbeDir(dir string) (string, func(), error) {
+	dir, err := os.MkdirTemp(dir, ".gphotos-doctor-")
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, func() { _ = os.RemoveAll(dir) }, nil
+}
+
+func checkFreeSpace(outRoot string) doctorCheck {
+	c := doctorCheck{name: "Free disk space"}
+	free, err := fsutil.FreeSpace(outRoot)
+	if err != nil {
+		c.ok = true
+		c.info = "unknown (" + err.Error() + ")"
+		return c
+	}
+	c.info = formatBytes(int64(free))
+	c.ok = free >= 1<<30
+	if !c.ok {
+		c.fix = "Free up space; the output needs roughly the size of your Takeout."
+	}
+	return c
+}
+
+func checkHardlink(dir string) doctorCheck {
+	c := doctorCheck{name: "Hardlinks"}
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, []byte("x"), 0o644); err != nil {
+		c.info = err.Error()
+		return c
+	}
+	// Only -album-links and -people-links hardlink mode needs them, so a
+	// missing hardlink is not a failure.
+	c.ok = true
+	if err := os.Link(src, filepath.Join(dir, "hard")); err != nil {
+		c.info = "not available (" + err.Error() + "); use symlink mode for album and people links"
+		return c
+	}
+	c.info = "supported"
+	return c
+}
+
+// checkSymlink runs the probe album and people links use, so a missing
+// symlink is only a failure when the hardlink fallback is missing too.
+func checkSymlink(dir string) doctorCheck {
+	c := doctorCheck{name: "Symlinks"}
+	symErr, hardErr := fsutil.ProbeLinks(dir)
+	switch {
+	case symErr == nil:
+		c.ok = true
+		c.info = "supported"
+	case hardErr == nil:
+		c.ok = true
+		c.info = "not available (" + symErr.Error() + "); album and people links fall back to hardlinks"
+	default:
+		c.info = symErr.Error()
+		c.fix = "Use -album-links off, or on Windows enable Developer Mode to allow symlinks."
+	}
+	return c
+}
+
+func checkReflink(dir string) doctorCheck {
+	c := doctorCheck{name: "Reflink (copy-on-write)", ok: true}
+	if err := fsutil.Reflink(filepath.Join(dir, "src"), filepath.Join(dir, "clone")); err != nil {
+		c.info = "not available (" + err.Error() + "); regular copies will be used"
+		return c
+	}
+	c.info = "supported"
+	return c
+}
+
+func checkXattr(dir string) doctorCheck {
+	c := doctorCheck{name: "Extended attributes", ok: true}
+	if err := fsutil.SetXattr(filepath.Join(dir, "src"), "user.gphotos.probe", "1"); err != nil {
+		c.info = "not available (" + err.Error() + ")"
+		return c
+	}
+	c.info = "supported"
+	return c
+}
+
+func checkLongPaths(dir string) doctorCheck {
+	c := doctorCheck{name: "Long paths"}
+	long := dir
+	for len(long) < 300 {
+		long = filepath.Join(long, strings.Repeat("d", 60))
+	}
+	if err := os.MkdirAll(long, 0o755); err != nil {
+		c.info = err.Error()
+		c.fix = "Enable long path support (Windows: LongPathsEnabled registry key) or use a shorter output path."
+		return c
+	}
+	if err := os.WriteFile(filepath.Join(long, "f"), []byte("x"), 0o644); err != nil {
+		c.info = err.Error()
+		c.fix = "Enable long path support or use a shorter output path."
+		return c
+	}
+	c.ok = true
+	c.info = "paths over 260 characters work"
+	return c
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"gphotos/core/console"
+	"gphotos/core/output"
+)
+
+// runGallery implements `gphotos gallery`, which writes a static HTML
+// gallery over an organized output folder.
+func runGallery(args []string) int {
+	fs := flag.NewFlagSet("gallery", flag.ContinueOnError)
+	thumbSize := fs.Int("thumb-size", 240, "Longest side of the generated thumbnails in pixels")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gphotos gallery [-thumb-size N] <output>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || *thumbSize < 16 {
+		fs.Usage()
+		return exitUsage
+	}
+	outRoot := fs.Arg(0)
+
+	bar := newProgressBar("Building gallery")
+	stats, err := output.WriteGallery(outRoot, *thumbSize, bar.Update)
+	bar.Finish()
+	if err != nil {
+		console.Errorln("Gallery error:", err)
+		return exitFailure
+	}
+	fmt.Printf("Gallery pages: %d, thumbnails: %d, without thumbnail: %d\n", stats.Pages, stats.Thumbs, stats.NoThumb)
+	fmt.Println("Open", filepath.Join(outRoot, "gallery", "index.html"))
+	return exitOK
+}
@@ -2,485 +2,2866 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gphotos/core/albums"
+	"gphotos/core/classify"
+	"gphotos/core/console"
 	"gphotos/core/dedup"
+	"gphotos/core/fsutil"
+	"gphotos/core/i18n"
 	"gphotos/core/metadata"
 	"gphotos/core/models"
 	"gphotos/core/output"
 	"gphotos/core/scanner"
+	"gphotos/core/spill"
 )
 
+// Exit codes returned by gphotos so wrapper scripts can react to the outcome.
+const (
+	exitOK              = 0 // run completed
+	exitFailure         = 1 // unexpected error (state files, album selection, post-copy steps)
+	exitUsage           = 2 // invalid flags or arguments
+	exitScanError       = 3 // input could not be scanned or contained no media
+	exitCancelled       = 4 // user did not confirm the date review
+	exitCopyFailure     = 5 // copying into the output failed
+	exitMetadataFailure = 6 // files copied but some metadata writes failed
+	exitPartial         = 7 // completed, but some files were quarantined
+	exitStopped         = 8 // -max-runtime reached; running again resumes
+)
+
+const commandHelp = `Commands:
+  diff         compare two Takeout exports by content: files added, removed, or changed
+  doctor       check exiftool, destination permissions, free space, and filesystem features
+  gallery      write a static HTML gallery with thumbnails over an organized output folder
+  pause        pause a running copy into an output folder until resume is run
+  patterns     list, export, or import shareable custom filename pattern packs
+  query        list the catalogued files of an output folder by person, year, album, or location
+  resume       continue a copy paused with pause
+  runs         list the runs recorded in an output folder, or diff two of them
+  shift-dates  add a fixed offset to the saved dates of an album, folder, or date range
+  state        export or import the saved state of a Takeout as one archive, to move a migration
+  stats        chart the photo and video counts and sizes per year of an output folder
+  undo         move files back after a -reorganize-in-place run, using its rename journal
+`
+
+const exitCodeHelp = `Exit codes:
+  0  run completed
+  1  unexpected error
+  2  invalid flags or arguments
+  3  scan error or no media found
+  4  date review cancelled
+  5  copy failure
+  6  metadata write failures
+  7  completed with quarantined files
+  8  stopped by -max-runtime; run the same command again to resume
+`
+
 func main() {
+	code := dispatch()
+	metadata.RemoveConfig()
+	os.Exit(code)
+}
+
+// dispatch runs the subcommand named by the first argument, or the
+// organizer when there is none.
+func dispatch() int {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			return runDiff(os.Args[2:])
+		case "doctor":
+			return runDoctor(os.Args[2:])
+		case "undo":
+			return runUndo(os.Args[2:])
+		case "gallery":
+			return runGallery(os.Args[2:])
+		case "pause":
+			return runPause(os.Args[2:])
+		case "resume":
+			return runResume(os.Args[2:])
+		case "patterns":
+			return runPatterns(os.Args[2:])
+		case "query":
+			return runQuery(os.Args[2:])
+		case "runs":
+			return runRuns(os.Args[2:])
+		case "shift-dates":
+			return runShiftDates(os.Args[2:])
+		case "state":
+			return runState(os.Args[2:])
+		case "stats":
+			return runStats(os.Args[2:])
+		}
+	}
+	return run()
+}
+
+func run() (code int) {
 	dryRun := flag.Bool("dry-run", false, "Print planned operations without copying files")
+	dryRunMeta := flag.String("dry-run-meta", "summary", "With -dry-run, how to report the metadata that would be written: summary (files per tag), list (every tag and value per file), off")
 	verbose := flag.Bool("verbose", true, "Print progress and file details")
 	datesOnly := flag.Bool("dates-only", false, "Only analyze dates (skip hashing, dedup, albums, output)")
+	writeDates := flag.Bool("write", false, "With -dates-only, write the reviewed dates into the source files (XMP sidecars where that is unsafe)")
+	rcloneRemote := flag.String("rclone", "", "Upload the finished output to an rclone remote (e.g. gdrive:Photos); the output folder acts as staging")
+	rcloneMode := flag.String("rclone-mode", "copy", "How to hand off to rclone: copy, move (delete this run's files after upload), list (only write a --files-from list)")
+	mergeInto := flag.String("merge-into", "", "Merge into an existing organized library (Library/Albums or {year}/{month}); files already there are skipped")
+	reorganize := flag.Bool("reorganize-in-place", false, "Organize inside the Takeout folder by renaming files instead of copying (journaled; revert with the undo command)")
+	inPlace := flag.Bool("in-place", false, "Write metadata directly into the source files without copying (unsupported or mislabelled files get a NAME.ext.xmp sidecar)")
+	source := flag.String("source", "takeout", "Kind of input: "+strings.Join(scanner.ImporterNames(), ", ")+" (folder: an ordinary camera or phone dump without JSON sidecars; icloud: Apple's Data & Privacy export; amazon: an Amazon Photos download)")
 	workers := flag.Int("workers", 4, "Number of parallel workers for copy")
 	exifBatch := flag.Int("exif-batch", 25, "Batch size for exiftool metadata writes")
+	exifWorkers := flag.Int("exif-workers", 1, "Number of parallel exiftool writers, independent of -workers")
+	exifArgFile := flag.String("exiftool-argfile", "", "Write all planned metadata updates to this exiftool -@ argfile (plus FILE.config) instead of running exiftool")
+	hashCache := flag.String("hash-cache", "", "Hash cache file; share one path across Takeout roots to reuse hashing work (default hash_cache.json in the Takeout's state folder)")
+	stateDir := flag.String("state-dir", "", "Folder for per-Takeout state such as the hash cache and input lock (default $XDG_DATA_HOME/gphotos or ~/.local/share/gphotos)")
+	portable := flag.Bool("portable", false, "Keep all gphotos state (patterns, exclusions, decisions, hash cache, journal, manifests) in <output>/.gphotos so the library moves as one unit")
+	readOnlySource := flag.Bool("read-only-source", false, "Guarantee that nothing inside the Takeout folder is written; refuses modes and paths that would")
+	trustCache := flag.Bool("trust-cache", false, "Reuse cached hashes without re-checking file size and mtime (fast re-runs on slow mounts)")
+	preferCopy := flag.String("prefer-copy", "first", "Which identical copy supplies the path and JSON: first, album (album folder copy), year (Photos from YYYY copy)")
+	noDedup := flag.Bool("no-dedup", false, "Skip hashing and duplicate merging; go straight from scan to dates and copy")
+	mediaType := flag.String("media-type", "all", "Process only photo, video, or all media")
+	minSize := flag.String("min-size", "", "Skip media smaller than this size (e.g. 50KB)")
+	maxSize := flag.String("max-size", "", "Skip media larger than this size (e.g. 2GB)")
+	onlyAlbums := flag.String("only-albums", "", "Comma-separated album names or patterns (e.g. \"Vacation*,Family\"); only media in these albums is hashed, dated and copied")
 	onlyExts := flag.String("only-exts", "", "Comma-separated list of extensions to include (e.g. .mp,.mov,.m4v)")
+	mergeRecompressed := flag.Bool("merge-recompressed", false, "Drop Storage saver copies when the larger original with the same name and time is present")
+	recompressedWindow := flag.String("recompressed-window", "2s", "With -merge-recompressed, how far apart the taken times of an original and its re-encoded copy may be (e.g. 2s, 1m)")
+	screenshots := flag.Bool("screenshots-folder", false, "Route detected screenshots into Screenshots/ instead of the library or albums")
+	videoKindFolders := flag.Bool("video-kind-folders", false, "Route slow-motion and timelapse videos into Slow-motion/ and Timelapse/ (frame rates are read with ffprobe when installed)")
+	appMode := flag.String("app-albums", "off", "Per-app handling: off, albums (put app media without an album into a per-app album), exclude-messaging")
+	deviceFolders := flag.Bool("device-folders", false, "Recreate the phone's original upload folders (Camera, Downloads, ...) under Library/")
+	creationsMode := flag.String("creations", "off", "Google-generated collages, animations and movies: off, folder (route into Creations/), skip")
+	trashedMode := flag.String("trashed", "folder", "Items in Google's bin (JSON trashed flag, or a Trash/Bin folder): folder (route into Trash/), exclude, off (treat like any other item)")
+	archivedMode := flag.String("archived", "folder", "Archived items (JSON archived flag, or an Archive folder): folder (route into Archive/), exclude, off (treat like any other item)")
+	partnerMode := flag.String("partner", "off", "Partner Sharing media handling: off, folder (route into Partner/), exclude")
+	embedJSON := flag.Bool("embed-json", false, "Store the full original JSON sidecar in XMP-gphotos:SourceJSON inside each file")
+	copyJSON := flag.Bool("copy-json", false, "Copy each photo's Google JSON sidecar next to the output file as NAME.ext.json")
+	motionPhotos := flag.String("motion-photos", "keep", "Video embedded in motion photos (MVIMG, PXL_*.MP.jpg): keep, extract (also write it as NAME.mp4), strip (remove it from the still), split (both)")
+	fileDates := flag.String("file-dates", "modified", "File modification time of output files: modified (Google's last-modified time) or taken (the taken date, for apps that sort by file time)")
+	iptc := flag.Bool("iptc", false, "Also write descriptions and people as IPTC Caption-Abstract and Keywords in JPEG and TIFF files")
+	descriptionTxt := flag.Bool("description-txt", false, "Also write each non-empty description to a NAME.ext.txt sidecar next to the output file")
+	unknownBucket := flag.Bool("unknown-folder", true, "Copy library files that end up without a date into Unknown/<original folder>/ instead of Library/")
+	layout := flag.String("layout", "", "Sub-folders for library files under Library/, built from {{camera}}, {{year}}, {{month}} and {{kind}} (e.g. {{camera}}/{{year}})")
+	stripCopySuffix := flag.Bool("strip-copy-suffix", false, "Drop the (1), (2), ... Takeout adds to repeated file names from output names when that causes no collision")
+	renameMode := flag.String("rename", "off", "Rename output files: off, chrono (YYYYMMDD_HHMMSS[_n].ext from the taken date)")
+	checksums := flag.String("checksums", "off", "Write SHA256SUMS manifests: off, root (one file), per-folder")
+	verifyMode := flag.String("verify", "off", "Re-hash copies and compare them with their source before metadata is written: off, full, or sample:N% for a random spot-check")
+	xattrChecksums := flag.Bool("xattr-checksums", false, "Store each output file's SHA-256 and taken date in extended attributes (user.gphotos.*, shatag-compatible)")
+	albumLinks := flag.String("album-links", "off", "Keep real files in Library/ and fill Albums/ with links for every album membership: off, symlink, hardlink")
+	peopleLinks := flag.String("people-links", "off", "Build a People/<Name>/ tree of links: off, symlink, hardlink")
+	dirMode := flag.String("dir-mode", "", "Octal mode for folders created in the output, e.g. 775 (default 755 minus umask)")
+	fileMode := flag.String("file-mode", "", "Octal mode for files created in the output, e.g. 664 (default 644 minus umask)")
+	owner := flag.String("owner", "", "User name or id that owns everything created in the output (Unix, usually needs root)")
+	group := flag.String("group", "", "Group name or id for everything created in the output (Unix)")
+	takeoutExtras := flag.String("takeout-extras", "skip", "Non-photo files Takeout adds (print order PDFs, archive_browser.html, shared album comments): skip, or misc to copy them into Misc/")
+	albumCSV := flag.Bool("album-csv", false, "Write an album.csv in each output album listing file names, taken dates, people, and descriptions")
+	picasaIni := flag.Bool("picasa-ini", false, "Write .picasa.ini files with album names, stars, and captions in each output folder")
+	reviewPage := flag.Int("review-page", 0, "Pause the date review every N entries (0 to list without pausing)")
+	reviewLimit := flag.Int("review-limit", 0, "Show at most N entries per date review category (0 for all)")
+	reviewExport := flag.String("review-export", "", "Write the override, EXIF-only, interpolated and unknown date lists to this CSV and stop, for offline review")
+	reviewFile := flag.String("review-file", "", "Apply date decisions from a CSV written by -review-export instead of prompting")
+	reviewOverflow := flag.String("review-overflow", "", "Write review entries that were not shown to this file")
+	reviewCategories := flag.String("review-categories", "all", "Date review categories to list: overrides,filename,exif,interpolated,unknown or all")
+	interpolateGap := flag.String("interpolate-dates", "0", "Infer unknown dates from the nearest dated files in the same folder when those are at most this far apart (e.g. 7d; 0 disables)")
+	dateCache := flag.Bool("date-cache", true, "Cache date analysis results by file hash (or path, size and mtime when not hashing) in .gphotos/date_cache.json so re-runs skip parsing")
+	reuseDecisions := flag.Bool("reuse-decisions", true, "Reuse date decisions confirmed in earlier runs (stored per file hash)")
+	overrideThreshold := flag.String("override-threshold", "0", "Minimum age difference before a filename date overrides JSON (e.g. 2d, 12h)")
+	patternTZ := flag.String("pattern-tz", "", "Timezones of built-in filename patterns, e.g. pixel=UTC,whatsapp=Local (patterns: "+strings.Join(metadata.PatternNames(), ", ")+")")
+	neverOverrideJSON := flag.Bool("never-override-json", false, "Always keep the JSON date when present, even if the filename date is older")
+	reuseSelection := flag.Bool("reuse-selection", false, "Apply the album selection saved by the previous run without prompting")
+	albumList := flag.String("albums", "", "Album priority order as comma-separated names or numbers, all, or none, instead of the album prompt")
+	assumeApply := flag.Bool("yes", false, "Unattended run: apply date changes without typing APPLY, skip the date prompts, and use -albums or the saved album selection")
+	flag.BoolVar(assumeApply, "assume-apply", false, "Same as -yes")
+	albumStrategy := flag.String("album-strategy", "manual", "Album priority strategy: manual, smallest, largest, newest, alphabetical")
+	untitledAlbums := flag.String("untitled-albums", "off", "Rename \"Untitled\" albums after their photos' dates: off, rename (e.g. 2018-07), or a name template with {{range}} (e.g. \"{{range}} Trip\")")
+	quarantine := flag.Bool("quarantine", true, "Move zero-byte and corrupted media into Quarantine/ instead of the library")
+	lang := flag.String("lang", "", "Language for prompts and reports: en, de, fr, es (default from LANG)")
+	mediaServer := flag.String("media-server", "", "Arrange the output and its metadata for a media server's photo app: "+strings.Join(mediaServerNames(), ", ")+" (flags given explicitly still win)")
+	profile := flag.String("profile", "", "Preset for common goals: "+strings.Join(profileNames(), ", ")+" (flags given explicitly still win)")
+	notify := flag.String("notify", "", "Send a notification with the run summary when the run finishes: webhook:URL, email:ADDRESS (via sendmail), or desktop")
+	maxRuntime := flag.String("max-runtime", "", "Stop starting new copies once the run has taken this long (e.g. 6h); running the same command again resumes")
+	memoryTarget := flag.String("memory-target", "", "Soft heap target (e.g. 512MB) for low-memory NAS boxes: garbage is collected more often near it, and hashed files and parsed sidecars wait in the state folder while they are collected. The file registry and the date proposals still live in memory, so this is not a hard cap")
+	plain := flag.Bool("plain-progress", false, "Print progress as a status line every few seconds instead of redrawing a bar, for logs captured by schedulers and CI")
+	noColor := flag.Bool("no-color", false, "Disable colored output (colors are also off when stdout is not a terminal)")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [flags] [takeout-root [output-folder]]\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(out, "\n"+commandHelp+"\n"+exitCodeHelp)
+	}
 	flag.Parse()
+	if *noColor {
+		console.SetColor(false)
+	}
+	plainProgress = *plain
+	if err := i18n.SetLanguage(*lang); err != nil {
+		console.Errorln(err)
+		return exitUsage
+	}
+	// The media server preset goes first: its settings are more specific
+	// than a profile's, and a flag set by one is left alone by the other.
+	if *mediaServer != "" {
+		applied, err := applyMediaServer(flag.CommandLine, *mediaServer)
+		if err != nil {
+			console.Errorln(err)
+			return exitUsage
+		}
+		fmt.Printf("Media server %s: %s\n", *mediaServer, strings.Join(applied, " "))
+	}
+	if *profile != "" {
+		applied, err := applyProfile(flag.CommandLine, *profile)
+		if err != nil {
+			console.Errorln(err)
+			return exitUsage
+		}
+		fmt.Printf("Profile %s: %s\n", *profile, strings.Join(applied, " "))
+	}
 
-	inRoot := promptPath("Enter path to Takeout root", "./Takeout")
-	outRoot := ""
-	if !*datesOnly {
-		outRoot = promptPath("Enter output folder", "./Output")
+	var deadline time.Time
+	if runtime, err := parseDurationDays(*maxRuntime); err != nil || runtime < 0 {
+		console.Errorln("Invalid -max-runtime:", *maxRuntime)
+		return exitUsage
+	} else if runtime > 0 {
+		deadline = time.Now().Add(runtime)
 	}
 
-	fmt.Println("Scanning...")
-	pairs, err := scanner.ScanTakeout(inRoot, *verbose)
+	threshold, err := parseDurationDays(*overrideThreshold)
 	if err != nil {
-		fmt.Println("Scan error:", err)
-		return
+		console.Errorln("Invalid -override-threshold:", err)
+		return exitUsage
 	}
-	if len(pairs) == 0 {
-		fmt.Println("No media files found.")
-		return
+	recompressWindow, err := parseDurationDays(*recompressedWindow)
+	if err == nil && recompressWindow < 0 {
+		err = fmt.Errorf("must not be negative")
 	}
-	printScanSummary(pairs)
-	if strings.TrimSpace(*onlyExts) != "" {
-		pairs = filterPairsByExt(pairs, *onlyExts)
-		if len(pairs) == 0 {
-			fmt.Println("No media files matched the requested extensions.")
-			return
-		}
-		fmt.Printf("Filtered media by extensions, remaining: %d\n", len(pairs))
+	if err != nil {
+		console.Errorln("Invalid -recompressed-window:", err)
+		return exitUsage
 	}
-
-	if *datesOnly {
-		photos := photosFromScan(pairs)
-		if err := applyDatesWithReview(photos); err != nil {
-			fmt.Println("Date parsing error:", err)
-			return
-		}
-		fmt.Println("Dates-only analysis complete.")
-		return
+	metadata.SetOverridePolicy(metadata.OverridePolicy{
+		Threshold: threshold,
+		Never:     *neverOverrideJSON,
+	})
+	if *fileDates != "modified" && *fileDates != "taken" {
+		console.Errorln("-file-dates must be modified or taken")
+		return exitUsage
 	}
+	metadata.SetTagPolicy(metadata.TagPolicy{
+		FileDateFromTaken: *fileDates == "taken",
+		IPTC:              *iptc,
+	})
 
-	fmt.Println("Building registry...")
-	hashBar := newProgressBar("Hashing")
-	cachePath := filepath.Join(inRoot, ".gphotos", "hash_cache.json")
-	registry := dedup.BuildRegistry(pairs, cachePath, *verbose, hashBar.Update)
-	hashBar.Finish()
-	photos := registryToSlice(registry)
-	fmt.Printf("Unique files (by hash): %d\n", len(registry))
+	zones, err := parsePatternZones(*patternTZ)
+	if err == nil {
+		err = metadata.SetPatternTimezones(zones)
+	}
+	if err != nil {
+		console.Errorln("Invalid -pattern-tz:", err)
+		return exitUsage
+	}
 
-	if err := applyDatesWithReview(photos); err != nil {
-		fmt.Println("Date parsing error:", err)
-		return
+	switch *mediaType {
+	case "all", "photo", "video":
+	default:
+		console.Errorln("Unknown -media-type:", *mediaType)
+		return exitUsage
 	}
 
-	fmt.Println("Merging duplicates...")
-	mergeBar := newProgressBar("Merging")
-	before := len(photos)
-	photos = dedup.MergeIdentical(photos, mergeBar.Update)
-	mergeBar.Finish()
-	fmt.Printf("Duplicates merged: %d -> %d\n", before, len(photos))
+	minBytes, err := parseSize(*minSize)
+	if err != nil {
+		console.Errorln("Invalid -min-size:", err)
+		return exitUsage
+	}
+	maxBytes, err := parseSize(*maxSize)
+	if err != nil {
+		console.Errorln("Invalid -max-size:", err)
+		return exitUsage
+	}
+	memLimit, err := parseSize(*memoryTarget)
+	if err != nil {
+		console.Errorln("Invalid -memory-target:", err)
+		return exitUsage
+	}
+	if memLimit > 0 {
+		debug.SetMemoryLimit(memLimit)
+	}
+	albumPatterns, err := parseAlbumPatterns(*onlyAlbums)
+	if err != nil {
+		console.Errorln("Invalid -only-albums:", err)
+		return exitUsage
+	}
 
-	allAlbums := albums.ListDistinctAlbums(photos)
-	fmt.Printf("Distinct albums detected: %d\n", len(allAlbums))
-	selected, err := albums.PromptAlbumSelection(allAlbums)
+	categories, err := parseReviewCategories(*reviewCategories)
 	if err != nil {
-		fmt.Println("Album selection error:", err)
-		return
+		console.Errorln(err)
+		return exitUsage
 	}
-	assignBar := newProgressBar("Assigning albums")
-	albums.AssignFinalAlbums(photos, selected, assignBar.Update)
-	assignBar.Finish()
-	printAlbumSummary(photos)
+	interpolate, err := parseDurationDays(*interpolateGap)
+	if err != nil {
+		console.Errorln("Invalid -interpolate-dates:", err)
+		return exitUsage
+	}
+	review := reviewOptions{
+		pageSize:   *reviewPage,
+		limit:      *reviewLimit,
+		overflow:   *reviewOverflow,
+		categories: categories,
 
-	fmt.Println("Organizing output...")
-	copyBar := newProgressBar("Copying")
-	if err := output.OrganizePhotos(photos, outRoot, *dryRun, *verbose, *workers, *exifBatch, copyBar.Update); err != nil {
-		fmt.Println("Output error:", err)
-		return
+		reuseDecisions: *reuseDecisions,
+		dateCache:      *dateCache,
+		interpolate:    interpolate,
+		export:         *reviewExport,
+		file:           *reviewFile,
+		assumeApply:    *assumeApply,
+	}
+	if review.export != "" && review.file != "" {
+		console.Errorln("-review-export and -review-file cannot be combined")
+		return exitUsage
 	}
-	copyBar.Finish()
 
-	if *dryRun {
-		fmt.Println("Dry run complete.")
-	} else {
-		fmt.Println("Done.")
+	if !dedup.ValidCopyPreference(*preferCopy) {
+		console.Errorln("Unknown -prefer-copy policy:", *preferCopy)
+		return exitUsage
 	}
-}
 
-func filterPairsByExt(pairs []scanner.FilePair, onlyExts string) []scanner.FilePair {
-	set := make(map[string]bool)
-	for _, part := range strings.Split(onlyExts, ",") {
-		ext := strings.ToLower(strings.TrimSpace(part))
-		if ext == "" {
-			continue
-		}
-		if !strings.HasPrefix(ext, ".") {
-			ext = "." + ext
+	importer, err := scanner.LookupImporter(*source)
+	if err != nil {
+		console.Errorln("Invalid -source:", err)
+		return exitUsage
+	}
+	if !albums.ValidStrategy(*albumStrategy) {
+		console.Errorln("Unknown -album-strategy:", *albumStrategy)
+		return exitUsage
+	}
+	untitledTemplate := strings.TrimSpace(*untitledAlbums)
+	switch {
+	case untitledTemplate == "" || strings.EqualFold(untitledTemplate, "off"):
+		untitledTemplate = ""
+	case strings.EqualFold(untitledTemplate, "rename"):
+		untitledTemplate = albums.RangePlaceholder
+	case !strings.Contains(untitledTemplate, albums.RangePlaceholder):
+		console.Errorln("-untitled-albums must be off, rename, or a template containing " + albums.RangePlaceholder)
+		return exitUsage
+	}
+
+	switch strings.ToLower(strings.TrimSpace(*appMode)) {
+	case "", "off", "albums", "exclude-messaging":
+	default:
+		console.Errorln("Unknown -app-albums mode:", *appMode)
+		return exitUsage
+	}
+	switch strings.ToLower(strings.TrimSpace(*partnerMode)) {
+	case "", "off", "folder", "exclude":
+	default:
+		console.Errorln("Unknown -partner mode:", *partnerMode)
+		return exitUsage
+	}
+	for _, mode := range []struct{ flag, value string }{{"trashed", *trashedMode}, {"archived", *archivedMode}} {
+		switch strings.ToLower(strings.TrimSpace(mode.value)) {
+		case "off", "folder", "exclude":
+		default:
+			console.Errorln("Unknown -"+mode.flag+" mode:", mode.value)
+			return exitUsage
 		}
-		set[ext] = true
 	}
-	if len(set) == 0 {
-		return pairs
+	switch strings.ToLower(strings.TrimSpace(*creationsMode)) {
+	case "", "off", "folder", "skip":
+	default:
+		console.Errorln("Unknown -creations mode:", *creationsMode)
+		return exitUsage
 	}
-	out := make([]scanner.FilePair, 0, len(pairs))
-	for _, p := range pairs {
-		ext := strings.ToLower(filepath.Ext(p.MediaPath))
-		if set[ext] {
-			out = append(out, p)
+	if err := validateLayout(*layout); err != nil {
+		console.Errorln(err)
+		return exitUsage
+	}
+	switch *renameMode {
+	case "off", "chrono":
+	default:
+		console.Errorln("Unknown -rename mode:", *renameMode)
+		return exitUsage
+	}
+	switch *checksums {
+	case "off", "root", "per-folder":
+	default:
+		console.Errorln("Unknown -checksums mode:", *checksums)
+		return exitUsage
+	}
+	switch strings.ToLower(strings.TrimSpace(*peopleLinks)) {
+	case "", "off", "symlink", "hardlink":
+	default:
+		console.Errorln("Unknown -people-links mode:", *peopleLinks)
+		return exitUsage
+	}
+	if *dirMode != "" || *fileMode != "" || *owner != "" || *group != "" {
+		perms, err := parsePermissions(*dirMode, *fileMode, *owner, *group)
+		if err != nil {
+			console.Errorln(err)
+			return exitUsage
 		}
+		fsutil.SetPermissions(perms)
+	}
+	verifySample, err := parseVerifyMode(*verifyMode)
+	if err != nil {
+		console.Errorln("Invalid -verify:", err)
+		return exitUsage
+	}
+	switch *rcloneMode {
+	case "copy", "move", "list":
+	default:
+		console.Errorln("Unknown -rclone-mode:", *rcloneMode)
+		return exitUsage
+	}
+	if *rcloneRemote != "" && *rcloneMode == "move" && (*mergeInto != "" || *reorganize) {
+		// The output there is the user's library or the Takeout itself.
+		console.Errorln("-rclone-mode move cannot be combined with -merge-into or -reorganize-in-place")
+		return exitUsage
+	}
+	if *rcloneRemote != "" && *rcloneMode != "list" && !output.RcloneAvailable() {
+		console.Errorln("-rclone needs rclone on PATH (or use -rclone-mode list)")
+		return exitUsage
+	}
+	switch *takeoutExtras {
+	case "skip", "misc":
+	default:
+		console.Errorln("Unknown -takeout-extras mode:", *takeoutExtras)
+		return exitUsage
+	}
+	albumLinkMode := strings.ToLower(strings.TrimSpace(*albumLinks))
+	switch albumLinkMode {
+	case "", "off":
+		albumLinkMode = ""
+	case "symlink", "hardlink":
+	default:
+		console.Errorln("Unknown -album-links mode:", *albumLinks)
+		return exitUsage
 	}
-	return out
-}
 
-type dateProposal struct {
-	photo    *models.Photo
-	jsonTime time.Time
-	fileTime time.Time
-	exifTime time.Time
-	hasJSON  bool
-	hasFile  bool
-	hasExif  bool
-	proposed time.Time
-	accuracy int
-}
+	if *writeDates && !*datesOnly {
+		console.Errorln("-write only applies to -dates-only")
+		return exitUsage
+	}
+	if *inPlace && *datesOnly {
+		console.Errorln("-in-place and -dates-only cannot be combined")
+		return exitUsage
+	}
+	if *reorganize && (*inPlace || *datesOnly) {
+		console.Errorln("-reorganize-in-place cannot be combined with -in-place or -dates-only")
+		return exitUsage
+	}
+	dryRunMetaMode := strings.ToLower(strings.TrimSpace(*dryRunMeta))
+	switch dryRunMetaMode {
+	case "summary":
+		dryRunMetaMode = output.DryRunMetaSummary
+	case output.DryRunMetaList, output.DryRunMetaOff:
+	default:
+		console.Errorln("-dry-run-meta must be summary, list, or off")
+		return exitUsage
+	}
+	motionMode := strings.ToLower(strings.TrimSpace(*motionPhotos))
+	switch motionMode {
+	case "keep":
+		motionMode = output.MotionKeep
+	case output.MotionExtract, output.MotionStrip, output.MotionSplit:
+	default:
+		console.Errorln("-motion-photos must be keep, extract, strip, or split")
+		return exitUsage
+	}
+	if motionMode != output.MotionKeep && (*inPlace || *datesOnly) {
+		console.Errorln("-motion-photos needs an output folder and cannot be combined with -in-place or -dates-only")
+		return exitUsage
+	}
+	if *reorganize && (motionMode == output.MotionStrip || motionMode == output.MotionSplit) {
+		console.Errorln("-motion-photos strip and split would change the original files and cannot be combined with -reorganize-in-place")
+		return exitUsage
+	}
+	if *mergeInto != "" && (*reorganize || *inPlace || *datesOnly) {
+		console.Errorln("-merge-into cannot be combined with -reorganize-in-place, -in-place, or -dates-only")
+		return exitUsage
+	}
+	if *portable && (*inPlace || *datesOnly) {
+		console.Errorln("-portable needs an output folder and cannot be combined with -in-place or -dates-only")
+		return exitUsage
+	}
+	if *readOnlySource && (*reorganize || *inPlace || *writeDates) {
+		console.Errorln("-read-only-source cannot be combined with -reorganize-in-place, -in-place, or -write")
+		return exitUsage
+	}
 
-func applyDatesWithReview(photos []*models.Photo) error {
-	patternPath := filepath.Join(".gphotos", "date_patterns.json")
-	exclusionPath := filepath.Join(".gphotos", "date_exclusions.json")
-	custom, err := metadata.LoadCustomPatterns(patternPath)
-	if err != nil {
-		return err
+	if *exifArgFile != "" && *dryRun {
+		console.Errorln("-exiftool-argfile cannot be combined with -dry-run")
+		return exitUsage
 	}
-	exclusions, err := metadata.LoadDateExclusions(exclusionPath)
-	if err != nil {
-		return err
+
+	var summary *runSummary
+	if *notify != "" {
+		target, err := parseNotifyTarget(*notify)
+		if err != nil {
+			console.Errorln("Invalid -notify:", err)
+			return exitUsage
+		}
+		defer func() {
+			var lines []string
+			if summary != nil {
+				lines = summary.rendered
+			}
+			if err := target.send(code, lines); err != nil {
+				console.Errorln("Notification error:", err)
+			}
+		}()
 	}
 
-	dateBar := newProgressBar("Analyzing dates")
-	proposals := collectDateProposals(photos, custom, exclusions, dateBar.Update)
-	dateBar.Finish()
-	for {
-		unknown := filterUnknown(proposals)
-		if len(unknown) == 0 {
-			break
+	// Paths given as arguments skip the prompts, for unattended runs.
+	inRoot := flag.Arg(0)
+	if inRoot == "" {
+		inRoot = promptPath("Enter path to Takeout root", "./Takeout")
+	}
+	outRoot := ""
+	if *reorganize {
+		outRoot = inRoot
+	} else if *mergeInto != "" {
+		outRoot = *mergeInto
+	} else if !*datesOnly && !*inPlace {
+		outRoot = flag.Arg(1)
+		if outRoot == "" {
+			outRoot = promptPath("Enter output folder", "./Output")
 		}
-		updated, updatedExclusions, err := promptCustomPatternsLoop(unknown, custom, exclusions, patternPath, exclusionPath)
-		if err != nil {
-			return err
+	}
+	takeoutState, err := resolveTakeoutState(*stateDir, inRoot)
+	if err != nil {
+		console.Errorln("State dir error:", err)
+		return exitUsage
+	}
+	if *portable {
+		takeoutState = usePortableState(outRoot, takeoutState)
+	}
+	cachePath := *hashCache
+	if cachePath == "" {
+		cachePath = filepath.Join(takeoutState, "hash_cache.json")
+	}
+	if *readOnlySource {
+		if err := checkReadOnlySource(inRoot, outRoot, takeoutState, cachePath, *exifArgFile); err != nil {
+			console.Errorln("-read-only-source:", err)
+			return exitUsage
 		}
-		if len(updated) == len(custom) && len(updatedExclusions) == len(exclusions) {
-			break
+		if err := fsutil.ProtectTree(inRoot); err != nil {
+			console.Errorln("-read-only-source:", err)
+			return exitUsage
 		}
-		custom = updated
-		exclusions = updatedExclusions
-		dateBar = newProgressBar("Analyzing dates")
-		proposals = collectDateProposals(photos, custom, exclusions, dateBar.Update)
-		dateBar.Finish()
 	}
-
-	printDateReview(proposals)
-	if !promptApplyConfirmation() {
-		return fmt.Errorf("date review not confirmed")
+	if *hashCache == "" {
+		migrateHashCache(inRoot, cachePath)
 	}
 
-	for _, p := range proposals {
-		if p.accuracy == metadata.DateAccuracyNone {
-			p.photo.Meta.TakenTime = ""
-			p.photo.DateAccuracy = metadata.DateAccuracyNone
-			continue
+	// Locks keep a second run from racing on the hash cache and on output
+	// names. The input's lock lives in its state folder, which -portable
+	// makes the output's; a dry run writes nothing to the output, so the
+	// output is only locked on real runs.
+	lock, err := lockTakeout(takeoutState)
+	if err != nil {
+		console.Errorln("Lock error:", err)
+		return exitFailure
+	}
+	defer lock.Release()
+	if memLimit > 0 {
+		// Spill logs are removed when done; the lock keeps a second run
+		// from sharing the folder.
+		spillRoot := filepath.Join(takeoutState, "spill")
+		defer os.Remove(spillRoot)
+		dedup.SetSpillDir(spillRoot)
+		dateSpillDir = spillRoot
+	}
+	if outRoot != "" && outRoot != inRoot && !*dryRun && !*portable {
+		lock, err := lockRoot(outRoot)
+		if err != nil {
+			console.Errorln("Lock error:", err)
+			return exitFailure
 		}
-		p.photo.Meta.TakenTime = p.proposed.Format(time.RFC3339)
-		p.photo.DateAccuracy = p.accuracy
+		defer lock.Release()
+	}
+	var journal *output.Journal
+	if *reorganize && !*dryRun {
+		journal, err = output.OpenJournal(journalPath(inRoot))
+		if err != nil {
+			console.Errorln("Journal error:", err)
+			return exitFailure
+		}
+		defer journal.Close()
 	}
 
-	return nil
-}
+	stageCount := 6
+	if *datesOnly && *writeDates {
+		stageCount = 3
+	} else if *datesOnly {
+		stageCount = 2
+	} else if *inPlace {
+		stageCount = 3
+	} else if *noDedup {
+		stageCount = 4
+	}
+	stages = newPipeline(stageCount)
+	summary = &runSummary{started: time.Now(), dryRun: *dryRun, dedup: !*noDedup}
 
-func collectDateProposals(photos []*models.Photo, custom []metadata.CustomPattern, exclusions map[string]bool, progress func(done, total int)) []dateProposal {
-	proposals := make([]dateProposal, 0, len(photos))
-	total := len(photos)
-	processed := 0
-	for _, p := range photos {
-		jsonMeta, hasJSONMeta := metadata.ParseJSONMeta(p.JsonPath)
-		jsonTime := jsonMeta.PhotoTakenTime
-		hasJSON := jsonMeta.HasPhotoTaken
-		if !hasJSON && jsonMeta.HasCreation {
-			jsonTime = jsonMeta.CreationTime
-			hasJSON = true
-		}
-		fileTime, hasFile := metadata.GuessDateFromFilenameWithCustomAndExclusions(p.SrcPath, custom, exclusions)
-		proposed, accuracy, ok, exifTime, hasExif := metadata.ExtractBestDateWithCustomAndExclusions(p.SrcPath, jsonTime, hasJSON, custom, exclusions)
-		if hasJSONMeta {
-			if jsonMeta.HasCreation {
-				p.Meta.CreationTime = jsonMeta.CreationTime.Format(time.RFC3339)
-			}
-			p.Meta.Description = jsonMeta.Description
-			p.Meta.Favorited = jsonMeta.Favorited
-			p.Meta.People = append([]string{}, jsonMeta.People...)
-			p.Meta.URL = jsonMeta.URL
-			p.Meta.AppSource = jsonMeta.AppSource
-			p.Meta.Origin = models.GooglePhotosOrigin{
-				FromSharedAlbum:          jsonMeta.Origin.FromSharedAlbum,
-				WebUpload:                jsonMeta.Origin.WebUpload,
-				MobileUpload:             jsonMeta.Origin.MobileUpload,
-				MobileUploadDeviceType:   jsonMeta.Origin.MobileUploadDeviceType,
-				MobileUploadDeviceFolder: jsonMeta.Origin.MobileUploadDeviceFolder,
-				CompositionType:          jsonMeta.Origin.CompositionType,
-			}
-			if jsonMeta.HasGeo {
-				p.Meta.HasGeo = true
-				p.Meta.GPSLat = jsonMeta.Geo.Latitude
-				p.Meta.GPSLon = jsonMeta.Geo.Longitude
-				p.Meta.GPSAlt = jsonMeta.Geo.Altitude
-				p.Meta.GPSSpanLat = jsonMeta.Geo.LatitudeSpan
-				p.Meta.GPSSpanLon = jsonMeta.Geo.LongitudeSpan
+	stages.next("Scanning")
+	pairs, unreadable, err := importer.Scan(inRoot, *verbose)
+	if err != nil {
+		console.Errorln("Scan error:", err)
+		return exitScanError
+	}
+	printUnreadable(unreadable)
+	if len(pairs) == 0 {
+		fmt.Println(i18n.T("No media files found."))
+		return exitScanError
+	}
+	var artifacts []scanner.Artifact
+	var scannedByDir map[string]int
+	var takeoutFolders []scanner.FolderCount
+	overlapKept := make(map[string]string)
+	if importer.Sidecars() {
+		artifacts, err = scanner.FindArtifacts(inRoot)
+		if err != nil {
+			console.Errorln("Scan error:", err)
+			return exitScanError
+		}
+		if len(artifacts) > 0 && *takeoutExtras == "skip" {
+			fmt.Printf("Non-photo Takeout files skipped: %d\n", len(artifacts))
+			summary.skip("non-photo Takeout files", len(artifacts))
+		}
+		if *verbose {
+			for _, a := range artifacts {
+				fmt.Printf("  %s (%s)\n", a.Path, a.Kind)
 			}
 		}
-		if !ok {
-			accuracy = metadata.DateAccuracyNone
-		}
-		proposals = append(proposals, dateProposal{
-			photo:    p,
-			jsonTime: jsonTime,
-			fileTime: fileTime,
-			exifTime: exifTime,
-			hasJSON:  hasJSON,
-			hasFile:  hasFile,
-			hasExif:  hasExif,
-			proposed: proposed,
-			accuracy: accuracy,
-		})
-		processed++
-		if progress != nil {
-			progress(processed, total)
+		// Counted now, before -reorganize-in-place moves anything.
+		takeoutFolders, err = scanner.CountTakeoutFolders(inRoot)
+		if err != nil {
+			console.Errorln("Reconciliation error:", err)
+		} else {
+			scannedByDir = scanner.CountPairsByDir(pairs)
+		}
+		var overlaps []scanner.Overlap
+		pairs, overlaps = scanner.CollapseOverlappingParts(inRoot, pairs)
+		for _, o := range overlaps {
+			for _, dropped := range o.Dropped {
+				overlapKept[dropped] = o.Kept
+			}
+		}
+		printOverlapSummary(overlaps, *verbose)
+		if err := resolveJSONMatches(inRoot, pairs); err != nil {
+			console.Errorln("JSON override error:", err)
+			return exitUsage
 		}
 	}
-	return proposals
-}
+	printScanSummary(pairs)
+	if strings.TrimSpace(*onlyExts) != "" {
+		before := len(pairs)
+		pairs = filterPairsByExt(pairs, *onlyExts)
+		summary.skip("filtered by extension", before-len(pairs))
+		if len(pairs) == 0 {
+			fmt.Println("No media files matched the requested extensions.")
+			return exitScanError
+		}
+		fmt.Printf("Filtered media by extensions, remaining: %d\n", len(pairs))
+	}
+	if *mediaType != "all" {
+		before := len(pairs)
+		pairs = filterPairsByMediaType(pairs, *mediaType == "video")
+		summary.skip("filtered by media type", before-len(pairs))
+		if len(pairs) == 0 {
+			fmt.Printf("No %s files found.\n", *mediaType)
+			return exitScanError
+		}
+		fmt.Printf("Filtered media by type (%s), remaining: %d\n", *mediaType, len(pairs))
+	}
+	if minBytes > 0 || maxBytes > 0 {
+		before := len(pairs)
+		pairs = filterPairsBySize(pairs, minBytes, maxBytes)
+		summary.skip("filtered by size", before-len(pairs))
+		if len(pairs) == 0 {
+			fmt.Println("No media files matched the requested size range.")
+			return exitScanError
+		}
+		fmt.Printf("Filtered media by size, remaining: %d\n", len(pairs))
+	}
+	if len(albumPatterns) > 0 {
+		before := len(pairs)
+		pairs = filterPairsByAlbum(pairs, albumPatterns)
+		summary.skip("outside -only-albums", before-len(pairs))
+		if len(pairs) == 0 {
+			fmt.Println("No media files found in the requested albums.")
+			return exitScanError
+		}
+		fmt.Printf("Filtered media by album, remaining: %d\n", len(pairs))
+	}
 
-func filterUnknown(proposals []dateProposal) []dateProposal {
-	var out []dateProposal
-	for _, p := range proposals {
-		if !p.hasJSON && !p.hasFile {
-			out = append(out, p)
+	if *datesOnly {
+		photos := photosFromScan(pairs)
+		stages.next("Analyzing dates")
+		if err := applyDatesWithReview(photos, review); err != nil {
+			return dateErrorCode(err)
+		}
+		if !*writeDates {
+			fmt.Println("Dates-only analysis complete.")
+			return exitOK
 		}
+		// Only the dates are written; everything else stays as it is.
+		for _, p := range photos {
+			p.Meta = models.MetaData{TakenTime: p.Meta.TakenTime}
+		}
+		stages.next("Writing dates")
+		return tagSources(photos, inRoot, output.Options{
+			DryRun:      *dryRun,
+			DryRunMeta:  dryRunMetaMode,
+			Verbose:     *verbose,
+			ExifBatch:   *exifBatch,
+			ExifWorkers: *exifWorkers,
+			ArgFile:     *exifArgFile,
+		})
 	}
-	return out
-}
 
-func printDateReview(proposals []dateProposal) {
-	var overrides []dateProposal
-	var filenameOnly []dateProposal
-	var exifOnly []dateProposal
-	var unknown []dateProposal
+	if *inPlace {
+		// Every source copy is tagged, so duplicates are not merged.
+		photos := photosFromScan(pairs)
+		stages.next("Analyzing dates")
+		if err := applyDatesWithReview(photos, review); err != nil {
+			return dateErrorCode(err)
+		}
+		// The source is the file being tagged, so its path is not
+		// recorded; that would rewrite every file on every run.
+		if *embedJSON {
+			fmt.Printf("Embedding source JSON for %d files\n", embedSourceJSON(photos))
+		}
+		stages.next("Tagging in place")
+		return tagSources(photos, inRoot, output.Options{
+			DryRun:      *dryRun,
+			DryRunMeta:  dryRunMetaMode,
+			Verbose:     *verbose,
+			ExifBatch:   *exifBatch,
+			ExifWorkers: *exifWorkers,
+			ArgFile:     *exifArgFile,
+		})
+	}
 
-	for _, p := range proposals {
-		switch {
-		case p.hasJSON && p.hasFile && p.accuracy == metadata.DateAccuracyFilename:
-			overrides = append(overrides, p)
-		case !p.hasJSON && p.hasFile:
-			filenameOnly = append(filenameOnly, p)
-		case !p.hasJSON && !p.hasFile && p.hasExif:
-			exifOnly = append(exifOnly, p)
-		case !p.hasJSON && !p.hasFile:
-			unknown = append(unknown, p)
+	quarantined := 0
+	if *quarantine {
+		var broken []output.QuarantineItem
+		pairs, broken = splitBrokenPairs(pairs)
+		quarantined = len(broken)
+		summary.quarantined = quarantined
+		if len(broken) > 0 {
+			fmt.Printf("Quarantined %d zero-byte or corrupted files\n", len(broken))
+			if err := output.QuarantineFiles(broken, outRoot, *dryRun, journal); err != nil {
+				console.Errorln("Quarantine error:", err)
+				return exitFailure
+			}
 		}
 	}
 
-	fmt.Println("Date review:")
-	fmt.Printf("Overrides (filename older than JSON): %d\n", len(overrides))
-	for i, p := range overrides {
-		fmt.Printf("%d. %s\n", i+1, p.photo.SrcPath)
-		fmt.Printf("   JSON: %s  Filename: %s\n", p.jsonTime.Format(time.RFC3339), p.fileTime.Format(time.RFC3339))
+	var photos []*models.Photo
+	if *noDedup {
+		photos = photosFromScan(pairs)
+		fmt.Println("Deduplication disabled, hashing skipped.")
+	} else {
+		stages.next("Building registry")
+		hashBar := newProgressBar("Hashing")
+		registry := dedup.BuildRegistry(pairs, cachePath, *trustCache, *verbose, hashBar.Update)
+		hashBar.Finish()
+		photos = registryToSlice(registry)
+		summary.scannedBytes = scannedSize(photos)
+		fmt.Print(i18n.Tf("Unique files (by hash): %d\n", len(registry)))
+		if err := reportCopyChoices(dedup.ApplyCopyPreference(photos, *preferCopy), *preferCopy, inRoot); err != nil {
+			console.Errorln("Copy preference report error:", err)
+		}
+	}
+	// The photos carry everything later stages need; drop the scan results
+	// so they can be collected on a -memory-target run.
+	pairs = nil
+
+	stages.next("Analyzing dates")
+	if err := applyDatesWithReview(photos, review); err != nil {
+		return dateErrorCode(err)
 	}
+	infoBar := newProgressBar("Reading media info")
+	metadata.LoadMediaInfo(photos, runtime.NumCPU(), infoBar.Update)
+	infoBar.Finish()
 
-	fmt.Printf("Filename-only dates: %d\n", len(filenameOnly))
-	for i, p := range filenameOnly {
-		fmt.Printf("%d. %s\n", i+1, p.photo.SrcPath)
-		fmt.Printf("   Filename: %s\n", p.fileTime.Format(time.RFC3339))
+	if !*noDedup {
+		stages.next("Merging duplicates")
+		mergeBar := newProgressBar("Merging")
+		before := len(photos)
+		photos = dedup.MergeIdentical(photos, mergeBar.Update)
+		mergeBar.Finish()
+		fmt.Print(i18n.Tf("Duplicates merged: %d -> %d\n", before, len(photos)))
+		reloadSidecars(dedup.AlignLivePairs(photos))
 	}
 
-	fmt.Printf("EXIF-only dates: %d\n", len(exifOnly))
-	for i, p := range exifOnly {
-		fmt.Printf("%d. %s\n", i+1, p.photo.SrcPath)
-		fmt.Printf("   EXIF: %s\n", p.exifTime.Format(time.RFC3339))
+	if *mergeRecompressed && !*noDedup {
+		recompressBar := newProgressBar("Matching recompressed copies")
+		var drops []dedup.RecompressedDrop
+		photos, drops = dedup.MergeRecompressed(photos, recompressWindow, recompressBar.Update)
+		recompressBar.Finish()
+		printRecompressedReport(drops)
 	}
+	summary.uniqueBytes = totalSize(photos)
+	// Live Photo halves are paired now, so the passes below that exclude
+	// or route files can be undone for a video separated from its still.
+	livePairs := dedup.LivePairs(photos)
+
+	stages.next("Selecting albums")
+	if untitledTemplate != "" {
+		fmt.Printf("Untitled albums renamed: %d\n", nameUntitledAlbums(photos, untitledTemplate, review.interactive()))
+	}
+	allAlbums := albums.ListDistinctAlbums(photos)
+	fmt.Print(i18n.Tf("Distinct albums detected: %d\n", len(allAlbums)))
+	selectionPath := statePath("albums.json")
+	if albumLinkMode != "" {
+		// Every membership gets a link, so no album has to win.
+		fmt.Println("Album links enabled, all files go to Library/.")
+	} else {
+		previous, err := albums.LoadSelection(selectionPath)
+		if err != nil {
+			console.Errorln("Album selection error:", err)
+			return exitFailure
+		}
+		previous = albums.FilterKnown(previous, allAlbums)
+		var selected []string
+		if *albumStrategy != albums.StrategyManual {
+			selected, err = albums.OrderByStrategy(*albumStrategy, photos, allAlbums)
+			if err != nil {
+				console.Errorln("Album selection error:", err)
+				return exitFailure
+			}
+			fmt.Printf("Album priority (%s): %s\n", *albumStrategy, strings.Join(selected, ", "))
+		} else if *albumList != "" {
+			selected, err = albums.ParseSelection(*albumList, allAlbums)
+			if err != nil {
+				console.Errorln("Album selection error:", err)
+				return exitUsage
+			}
+			fmt.Printf("Album priority (-albums): %s\n", strings.Join(selected, ", "))
+			// As below, a run limited by -only-albums keeps the saved
+			// selection of the full Takeout.
+			if len(albumPatterns) == 0 {
+				if err := albums.SaveSelection(selectionPath, selected); err != nil {
+					console.Errorln("Album selection error:", err)
+					return exitFailure
+				}
+			}
+		} else if (*reuseSelection || *assumeApply) && len(previous) > 0 {
+			selected = previous
+			fmt.Printf("Reusing saved album selection: %s\n", strings.Join(selected, ", "))
+		} else if *assumeApply {
+			fmt.Println("No saved album selection, all files go to Library/.")
+		} else {
+			selected, err = albums.PromptAlbumSelection(allAlbums, previous)
+			if err != nil {
+				console.Errorln("Album selection error:", err)
+				return exitFailure
+			}
+			// A run limited by -only-albums sees only some albums, so it
+			// must not replace the selection saved for the full Takeout.
+			if len(allAlbums) > 0 && len(albumPatterns) == 0 {
+				if err := albums.SaveSelection(selectionPath, selected); err != nil {
+					console.Errorln("Album selection error:", err)
+					return exitFailure
+				}
+			}
+		}
+		assignBar := newProgressBar("Assigning albums")
+		albums.AssignFinalAlbums(photos, selected, assignBar.Update)
+		assignBar.Finish()
+	}
+	switch strings.ToLower(strings.TrimSpace(*appMode)) {
+	case "", "off":
+	case "albums":
+		fmt.Printf("App albums assigned: %d\n", assignAppAlbums(photos))
+	case "exclude-messaging":
+		before := len(photos)
+		photos = excludeMessagingMedia(photos)
+		fmt.Printf("Messaging app media excluded: %d\n", before-len(photos))
+		summary.skip("messaging app media", before-len(photos))
+	}
+	switch strings.ToLower(strings.TrimSpace(*partnerMode)) {
+	case "folder":
+		fmt.Printf("Partner Sharing media routed: %d\n", routePartnerMedia(photos))
+	case "exclude":
+		before := len(photos)
+		photos = excludePartnerMedia(photos)
+		fmt.Printf("Partner Sharing media excluded: %d\n", before-len(photos))
+		summary.skip("Partner Sharing media", before-len(photos))
+	}
+	switch strings.ToLower(strings.TrimSpace(*creationsMode)) {
+	case "folder":
+		fmt.Printf("Google creations routed: %d\n", routeCreations(photos))
+	case "skip":
+		before := len(photos)
+		photos = excludeCreations(photos)
+		fmt.Printf("Google creations skipped: %d\n", before-len(photos))
+		summary.skip("Google creations", before-len(photos))
+	}
+	if *deviceFolders {
+		fmt.Printf("Library files placed in device folders: %d\n", routeDeviceFolders(photos))
+	}
+	if *screenshots {
+		fmt.Printf("Screenshots routed: %d\n", routeScreenshots(photos))
+	}
+	if *videoKindFolders {
+		slowMotion, timelapse := routeVideoKinds(photos)
+		fmt.Printf("Slow-motion videos routed: %d, timelapses routed: %d\n", slowMotion, timelapse)
+	}
+	for _, kind := range []struct {
+		name, mode, folder string
+		is                 func(*models.Photo) bool
+	}{
+		{"Trashed", *trashedMode, "Trash", isTrashed},
+		{"Archived", *archivedMode, "Archive", isArchived},
+	} {
+		switch strings.ToLower(strings.TrimSpace(kind.mode)) {
+		case "folder":
+			if n := routeFlagged(photos, kind.is, kind.folder); n > 0 {
+				fmt.Printf("%s items routed: %d\n", kind.name, n)
+			}
+		case "exclude":
+			before := len(photos)
+			photos = excludeFlagged(photos, kind.is)
+			fmt.Printf("%s items excluded: %d\n", kind.name, before-len(photos))
+			summary.skip(strings.ToLower(kind.name)+" items", before-len(photos))
+		}
+	}
+	if *unknownBucket {
+		if n := routeUnknownDates(inRoot, photos); n > 0 {
+			fmt.Printf("Files without a date routed to Unknown/: %d\n", n)
+		}
+	}
+	if strings.TrimSpace(*layout) != "" {
+		fmt.Printf("Library files placed by layout: %d\n", routeByLayout(photos, *layout))
+	}
+	var rejoined int
+	if photos, rejoined = dedup.KeepLivePairs(photos, livePairs); rejoined > 0 {
+		fmt.Printf("Live Photo videos routed, kept or dropped with their still: %d\n", rejoined)
+	}
+	if *mergeInto != "" {
+		indexBar := newProgressBar("Indexing library")
+		library, err := output.IndexLibrary(*mergeInto, indexBar.Update)
+		indexBar.Finish()
+		if err != nil {
+			console.Errorln("Library index error:", err)
+			return exitFailure
+		}
+		var present int
+		photos, present = library.Merge(photos)
+		summary.skip("already in library", present)
+		fmt.Printf("Existing library layout: %s, already present: %d, new: %d\n", library.Layout, present, len(photos))
+	}
+	printAlbumSummary(photos)
+
+	if *renameMode == "chrono" {
+		fmt.Printf("Files renamed chronologically: %d\n", output.ChronoNames(photos))
+	}
+	if *stripCopySuffix {
+		fmt.Printf("Copy suffixes removed from output names: %d\n", output.StripCopySuffixes(photos, outRoot))
+	}
+	dedup.AlignLiveNames(livePairs)
+	recordSourcePaths(inRoot, photos)
+	if *embedJSON {
+		fmt.Printf("Embedding source JSON for %d files\n", embedSourceJSON(photos))
+	}
+
+	toCopy := photos
+	if !*dryRun {
+		var resumed int
+		toCopy, resumed, err = output.ResumeStopped(outRoot, photos)
+		if err != nil {
+			console.Errorln("Resume error:", err)
+			return exitFailure
+		}
+		if resumed > 0 {
+			fmt.Printf("Resuming the stopped run: %d files already copied, %d to go\n", resumed, len(toCopy))
+		}
+	}
+
+	stages.next("Organizing output")
+	copyBar := newProgressBar("Copying")
+	pauseFile := ""
+	if !*dryRun {
+		pauseFile = output.PausePath(outRoot)
+	}
+	opts := output.Options{
+		DryRun:              *dryRun,
+		DryRunMeta:          dryRunMetaMode,
+		Verbose:             *verbose,
+		Workers:             *workers,
+		ExifBatch:           *exifBatch,
+		ExifWorkers:         *exifWorkers,
+		DescriptionSidecars: *descriptionTxt,
+		JSONSidecars:        *copyJSON,
+		Journal:             journal,
+		VerifySample:        verifySample,
+		ArgFile:             *exifArgFile,
+		MotionPhotos:        motionMode,
+		Deadline:            deadline,
+		PauseFile:           pauseFile,
+	}
+	stats, err := output.OrganizePhotos(toCopy, outRoot, opts, copyBar.Update)
+	copyBar.Finish()
+	printCollisions(stats.Collisions, *verbose)
+	if err != nil {
+		console.Errorln("Output error:", err)
+		return exitCopyFailure
+	}
+	if !*dryRun {
+		// Files a stopped run already copied are recorded.
+		if err := output.RecordLibraryIndex(outRoot, toCopy); err != nil {
+			console.Errorln("Library index error:", err)
+		}
+		if err := output.RecordCatalog(outRoot, inRoot, toCopy); err != nil {
+			console.Errorln("Catalog error:", err)
+		}
+	}
+	if stats.Stopped {
+		if err := output.MarkStopped(outRoot); err != nil {
+			console.Errorln("Resume state error:", err)
+			return exitFailure
+		}
+		console.Warnf("-max-runtime reached after copying %d of %d files; run the same command again to resume\n", len(photos)-len(toCopy)+stats.Copied, len(photos))
+		return exitStopped
+	}
+	if !*dryRun {
+		if err := output.ClearStopped(outRoot); err != nil {
+			console.Errorln("Resume state error:", err)
+		}
+	}
+	if scannedByDir != nil {
+		reportReconciliation(inRoot, outRoot, takeoutFolders, scannedByDir, overlapKept, photos, *dryRun)
+	}
+	printPlannedTags(stats.PlannedTags)
+	if stats.MotionExtracted+stats.MotionStripped > 0 {
+		fmt.Printf("Motion photos: videos extracted: %d, stills stripped: %d\n", stats.MotionExtracted, stats.MotionStripped)
+	}
+	if stats.Verified > 0 {
+		fmt.Printf("Copies verified: %d, mismatched: %d\n", stats.Verified, len(stats.VerifyFailures))
+		for _, path := range stats.VerifyFailures {
+			console.Errorln("Verification failed:", path)
+		}
+	}
+	if len(stats.XMPSidecars) > 0 {
+		reportPath, err := output.WriteSidecarReport(outRoot, stats.XMPSidecars)
+		if err != nil {
+			console.Errorln("Sidecar report error:", err)
+		} else {
+			fmt.Printf("Formats exiftool cannot write got XMP sidecars: %d (see %s)\n", len(stats.XMPSidecars), reportPath)
+		}
+	}
+	if len(stats.MetadataReport) > 0 {
+		reportPath, err := output.WriteMetadataReport(outRoot, stats.MetadataReport)
+		if err != nil {
+			console.Errorln("Metadata report error:", err)
+		} else {
+			console.Warnf("Metadata problems for %d files, see %s\n", len(stats.MetadataReport), reportPath)
+		}
+	}
+
+	if *checksums != "off" && !*dryRun {
+		sumBar := newProgressBar("Checksums")
+		hashed, err := output.WriteChecksums(photos, outRoot, *checksums == "per-folder", sumBar.Update)
+		sumBar.Finish()
+		if err != nil {
+			console.Errorln("Checksum manifest error:", err)
+			return exitFailure
+		}
+		fmt.Printf("SHA256SUMS entries written: %d\n", hashed)
+	}
+
+	if *xattrChecksums && !*dryRun {
+		xattrBar := newProgressBar("Checksum xattrs")
+		tagged, err := output.WriteChecksumXattrs(photos, xattrBar.Update)
+		xattrBar.Finish()
+		if err != nil {
+			console.Errorln("Checksum xattr error:", err)
+			return exitFailure
+		}
+		fmt.Printf("Files with checksum xattrs: %d\n", tagged)
+	}
+
+	if *picasaIni {
+		written, err := output.WritePicasaIni(photos, *dryRun)
+		if err != nil {
+			console.Errorln("picasa.ini error:", err)
+			return exitFailure
+		}
+		fmt.Printf("picasa.ini files written: %d\n", written)
+	}
+	if *albumCSV {
+		written, err := output.WriteAlbumCSV(photos, *dryRun)
+		if err != nil {
+			console.Errorln("album.csv error:", err)
+			return exitFailure
+		}
+		fmt.Printf("album.csv files written: %d\n", written)
+	}
+	if *takeoutExtras == "misc" && len(artifacts) > 0 {
+		copied, err := output.CollectArtifacts(artifacts, inRoot, outRoot, *dryRun)
+		if err != nil {
+			console.Errorln("Misc/ error:", err)
+			return exitFailure
+		}
+		fmt.Printf("Non-photo Takeout files copied to Misc/: %d\n", copied)
+	}
+
+	if albumLinkMode != "" {
+		mode, code := resolveLinkMode(outRoot, albumLinkMode, "-album-links", *dryRun)
+		if code != exitOK {
+			return code
+		}
+		linked, err := output.LinkAlbums(photos, outRoot, mode, *dryRun)
+		if err != nil {
+			console.Errorln("Album links error:", err)
+			return exitFailure
+		}
+		fmt.Printf("Album links created: %d\n", linked)
+	}
+
+	if mode := strings.ToLower(strings.TrimSpace(*peopleLinks)); mode != "" && mode != "off" {
+		mode, code := resolveLinkMode(outRoot, mode, "-people-links", *dryRun)
+		if code != exitOK {
+			return code
+		}
+		linked, err := output.LinkPeople(photos, outRoot, mode, *dryRun)
+		if err != nil {
+			console.Errorln("People links error:", err)
+			return exitFailure
+		}
+		fmt.Printf("People links created: %d\n", linked)
+	}
+
+	summary.stats = stats
+	// Logged before the upload, since -rclone-mode move removes the output.
+	if err := summary.report(outRoot, stages); err != nil {
+		console.Errorln("Run summary error:", err)
+	}
+	if !*dryRun {
+		if id, err := output.RecordRun(outRoot, inRoot, photos, summary.rendered); err != nil {
+			console.Errorln("Run history error:", err)
+		} else {
+			fmt.Printf("Run recorded as %s (compare with: gphotos runs diff %s)\n", id, outRoot)
+		}
+	}
+
+	if *rcloneRemote != "" && !*dryRun {
+		if *rcloneMode == "list" {
+			listPath, err := output.WriteRcloneList(outRoot, nil)
+			if err != nil {
+				console.Errorln("rclone list error:", err)
+				return exitFailure
+			}
+			fmt.Printf("rclone file list written: %s\n", listPath)
+			fmt.Printf("Upload with: %s\n", strings.Join(output.RcloneCommand("copy", outRoot, *rcloneRemote, listPath), " "))
+		} else {
+			fmt.Printf("Uploading to %s with rclone %s\n", *rcloneRemote, *rcloneMode)
+			// move deletes what it uploads, so it only gets this run's files.
+			var files []string
+			if *rcloneMode == "move" {
+				files = output.RunFiles(outRoot, photos)
+			}
+			if err := output.RcloneTransfer(*rcloneMode, outRoot, *rcloneRemote, files); err != nil {
+				console.Errorln("rclone error:", err)
+				return exitCopyFailure
+			}
+		}
+	}
+
+	if *dryRun {
+		fmt.Println(console.Success(i18n.T("Dry run complete.")))
+	} else {
+		fmt.Println(console.Success(i18n.T("Done.")))
+	}
+	if len(stats.VerifyFailures) > 0 {
+		return exitCopyFailure
+	}
+	if stats.MetadataFailures > 0 {
+		console.Warnf("Metadata writes failed for %d files\n", stats.MetadataFailures)
+		return exitMetadataFailure
+	}
+	if quarantined > 0 {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// reportCopyChoices summarizes which side won for files present both in an
+// album and in a year folder and lists them in .gphotos/copy_preference_report.txt.
+func reportCopyChoices(choices []dedup.CopyChoice, pref, inRoot string) error {
+	if len(choices) == 0 {
+		return nil
+	}
+	side := "album"
+	if pref == dedup.PreferYear {
+		side = "year-folder"
+	}
+	var b strings.Builder
+	for _, c := range choices {
+		kept, _ := filepath.Rel(inRoot, c.Kept.MediaPath)
+		dropped, _ := filepath.Rel(inRoot, c.Dropped.MediaPath)
+		fmt.Fprintf(&b, "%s\t(over %s)\n", kept, dropped)
+	}
+	path := statePath("copy_preference_report.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Files in both an album and a year folder: %d, using the %s copy (see %s)\n", len(choices), side, path)
+	return nil
+}
+
+// printUnreadable warns about paths the scan gave up on so a flaky network
+// share does not silently drop part of the export.
+func printUnreadable(unreadable []scanner.Unreadable) {
+	if len(unreadable) == 0 {
+		return
+	}
+	console.Warnf("Unreadable paths skipped after retries: %d\n", len(unreadable))
+	for _, u := range unreadable {
+		fmt.Printf("  %s: %v\n", u.Path, u.Err)
+	}
+}
+
+// resolveJSONMatches applies .gphotos/json_overrides.json and reports the
+// sidecar matches that are still only guesses.
+func resolveJSONMatches(inRoot string, pairs []scanner.FilePair) error {
+	overridePath := statePath("json_overrides.json")
+	overrides, err := scanner.LoadJSONOverrides(overridePath)
+	if err != nil {
+		return err
+	}
+	if n := scanner.ApplyJSONOverrides(inRoot, pairs, overrides); n > 0 {
+		fmt.Printf("JSON overrides applied: %d\n", n)
+	}
+	reportPath := statePath("json_match_report.txt")
+	n, err := scanner.WriteJSONMatchReport(inRoot, pairs, reportPath)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		console.Warnf("Low-confidence JSON matches: %d (see %s; pin pairs in %s)\n", n, reportPath, overridePath)
+	}
+	return nil
+}
+
+func filterPairsByExt(pairs []scanner.FilePair, onlyExts string) []scanner.FilePair {
+	set := make(map[string]bool)
+	for _, part := range strings.Split(onlyExts, ",") {
+		ext := strings.ToLower(strings.TrimSpace(part))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
+	}
+	if len(set) == 0 {
+		return pairs
+	}
+	out := make([]scanner.FilePair, 0, len(pairs))
+	for _, p := range pairs {
+		ext := strings.ToLower(filepath.Ext(p.MediaPath))
+		if set[ext] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func filterPairsByMediaType(pairs []scanner.FilePair, videos bool) []scanner.FilePair {
+	out := make([]scanner.FilePair, 0, len(pairs))
+	for _, p := range pairs {
+		if metadata.IsVideoPath(p.MediaPath) == videos {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// parseAlbumPatterns splits a comma-separated -only-albums value into
+// lowercased glob patterns.
+func parseAlbumPatterns(value string) ([]string, error) {
+	var patterns []string
+	for _, part := range strings.Split(value, ",") {
+		pattern := strings.ToLower(strings.TrimSpace(part))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%q: %v", part, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// filterPairsByAlbum keeps media in an album matching one of patterns
+// (case-insensitive). The same files in the year folders are dropped; the
+// album copies carry the same content and JSON.
+func filterPairsByAlbum(pairs []scanner.FilePair, patterns []string) []scanner.FilePair {
+	matches := func(album string) bool {
+		album = strings.ToLower(strings.TrimSpace(album))
+		if album == "" {
+			return false
+		}
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, album); ok {
+				return true
+			}
+		}
+		return false
+	}
+	out := make([]scanner.FilePair, 0, len(pairs))
+	for _, p := range pairs {
+		keep := matches(p.Album)
+		for _, album := range p.MoreAlbums {
+			keep = keep || matches(album)
+		}
+		if keep {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// filterPairsBySize keeps media whose size is within [minBytes, maxBytes].
+// A zero bound is ignored.
+func filterPairsBySize(pairs []scanner.FilePair, minBytes, maxBytes int64) []scanner.FilePair {
+	out := make([]scanner.FilePair, 0, len(pairs))
+	for _, p := range pairs {
+		info, err := os.Stat(p.MediaPath)
+		if err != nil {
+			continue
+		}
+		if minBytes > 0 && info.Size() < minBytes {
+			continue
+		}
+		if maxBytes > 0 && info.Size() > maxBytes {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// resolveLinkMode switches a link flag's mode to what the output
+// filesystem supports, saying so when symlinks fall back to hardlinks.
+// Dry runs keep the mode, since the output may not exist yet.
+func resolveLinkMode(outRoot, mode, flagName string, dryRun bool) (string, int) {
+	if dryRun {
+		return mode, exitOK
+	}
+	effective, reason, err := output.LinkMode(outRoot, mode)
+	if err != nil {
+		console.Errorln(flagName+":", err)
+		return "", exitFailure
+	}
+	if reason != nil {
+		console.Warnf("%s: symlinks are not available here (%v); using hardlinks instead\n", flagName, reason)
+	}
+	return effective, exitOK
+}
+
+// parseSize parses sizes like "50KB", "1.5GB", or "2048" (bytes) using
+// 1024-based units.
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	mult := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return int64(n * mult), nil
+}
+
+// parsePermissions builds the output permissions from -dir-mode,
+// -file-mode, -owner and -group; empty values keep the defaults.
+func parsePermissions(dirMode, fileMode, owner, group string) (fsutil.Permissions, error) {
+	perms := fsutil.Permissions{DirMode: 0o755, FileMode: 0o644}
+	for _, m := range []struct {
+		flag  string
+		value string
+		mode  *os.FileMode
+	}{{"-dir-mode", dirMode, &perms.DirMode}, {"-file-mode", fileMode, &perms.FileMode}} {
+		if m.value == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(m.value, 8, 32)
+		if err != nil || n > 0o777 {
+			return perms, fmt.Errorf("invalid %s: %s", m.flag, m.value)
+		}
+		*m.mode = os.FileMode(n)
+	}
+	if (owner != "" || group != "") && runtime.GOOS == "windows" {
+		return perms, fmt.Errorf("-owner and -group are not supported on Windows")
+	}
+	var err error
+	perms.UID, perms.GID, err = fsutil.LookupOwner(owner, group)
+	if err != nil {
+		return perms, fmt.Errorf("invalid -owner/-group: %v", err)
+	}
+	return perms, nil
+}
+
+// parseVerifyMode parses -verify into the fraction of copies to re-hash:
+// "off" is 0, "full" is 1 and "sample:5%" is 0.05.
+func parseVerifyMode(value string) (float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", "off":
+		return 0, nil
+	case "full":
+		return 1, nil
+	}
+	pct, ok := strings.CutPrefix(value, "sample:")
+	if !ok {
+		return 0, fmt.Errorf("expected off, full, or sample:N%%, got %q", value)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(pct), "%"), 64)
+	if err != nil || n <= 0 || n > 100 {
+		return 0, fmt.Errorf("invalid sample percentage: %s", pct)
+	}
+	return n / 100, nil
+}
+
+func splitBrokenPairs(pairs []scanner.FilePair) ([]scanner.FilePair, []output.QuarantineItem) {
+	out := make([]scanner.FilePair, 0, len(pairs))
+	var broken []output.QuarantineItem
+	for _, p := range pairs {
+		if reason, ok := metadata.CheckMediaIntegrity(p.MediaPath); !ok {
+			broken = append(broken, output.QuarantineItem{SrcPath: p.MediaPath, Reason: reason})
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, broken
+}
+
+var errReviewCancelled = errors.New("date review not confirmed")
+
+// errReviewExported stops a -review-export run once the CSV is written.
+var errReviewExported = errors.New("date review exported")
+
+// dateErrorCode reports an error from applyDatesWithReview and returns the
+// exit code for it. An export is a successful run, so it prints nothing.
+func dateErrorCode(err error) int {
+	if errors.Is(err, errReviewExported) {
+		return exitOK
+	}
+	console.Errorln("Date parsing error:", err)
+	if errors.Is(err, errReviewCancelled) {
+		return exitCancelled
+	}
+	return exitFailure
+}
+
+type dateProposal struct {
+	photo    *models.Photo
+	jsonTime time.Time
+	fileTime time.Time
+	exifTime time.Time
+	hasJSON  bool
+	hasFile  bool
+	hasExif  bool
+	proposed time.Time
+	accuracy int
+}
+
+func applyDatesWithReview(photos []*models.Photo, review reviewOptions) error {
+	exclusionPath := statePath("date_exclusions.json")
+	decisionPath := statePath("date_decisions.json")
+	cachePath := statePath("date_cache.json")
+	custom, err := metadata.LoadCustomPatterns(patternPath())
+	if err != nil {
+		return err
+	}
+	exclusions, err := metadata.LoadDateExclusions(exclusionPath)
+	if err != nil {
+		return err
+	}
+	decisions := map[string]metadata.DateDecision{}
+	if review.reuseDecisions {
+		decisions, err = metadata.LoadDateDecisions(decisionPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	var cache map[string]metadata.DateCacheEntry
+	if review.dateCache {
+		cache, err = metadata.LoadDateCache(cachePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	dateBar := newProgressBar("Analyzing dates")
+	proposals := collectDateProposals(photos, custom, exclusions, cache, dateBar.Update)
+	dateBar.Finish()
+	all := photos
+	proposals, reused := applySavedDecisions(proposals, decisions)
+	if reused > 0 {
+		fmt.Printf("Reused saved date decisions: %d\n", reused)
+	}
+	if len(proposals) == 0 {
+		return nil
+	}
+	photos = make([]*models.Photo, 0, len(proposals))
+	for _, p := range proposals {
+		photos = append(photos, p.photo)
+	}
+	for review.interactive() {
+		unknown := filterUnknown(proposals)
+		if len(unknown) == 0 {
+			break
+		}
+		updated, updatedExclusions, err := promptCustomPatternsLoop(unknown, custom, exclusions, patternPath(), exclusionPath)
+		if err != nil {
+			return err
+		}
+		if len(updated) == len(custom) && len(updatedExclusions) == len(exclusions) {
+			break
+		}
+		custom = updated
+		exclusions = updatedExclusions
+		dateBar = newProgressBar("Analyzing dates")
+		proposals = collectDateProposals(photos, custom, exclusions, cache, dateBar.Update)
+		dateBar.Finish()
+	}
+	if cache != nil {
+		if err := metadata.SaveDateCache(cachePath, cache); err != nil {
+			return err
+		}
+	}
+
+	if review.interpolate > 0 {
+		if n := interpolateDates(proposals, all, review.interpolate); n > 0 {
+			fmt.Printf("Dates interpolated from neighboring files: %d\n", n)
+		}
+	}
+
+	switch {
+	case review.export != "":
+		written, err := exportReviewCSV(review.export, proposals)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Review entries exported: %d\n", written)
+		fmt.Printf("Fill in the decision and date columns, then re-run with -review-file %s\n", review.export)
+		return errReviewExported
+	case review.file != "":
+		applied, err := applyReviewCSV(review.file, proposals)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Review decisions applied from %s: %d\n", review.file, applied)
+	default:
+		if err := printDateReview(proposals, review); err != nil {
+			return err
+		}
+		if review.assumeApply {
+			fmt.Println("Applying date changes without confirmation (-yes).")
+			break
+		}
+		reviewOverrides(proposals)
+		if !promptApplyConfirmation() {
+			return errReviewCancelled
+		}
+	}
+
+	for _, p := range proposals {
+		if p.accuracy == metadata.DateAccuracyNone {
+			p.photo.Meta.TakenTime = ""
+			p.photo.DateAccuracy = metadata.DateAccuracyNone
+			continue
+		}
+		p.photo.Meta.TakenTime = p.proposed.Format(time.RFC3339)
+		p.photo.DateAccuracy = p.accuracy
+		if p.photo.Hash != "" {
+			decisions[p.photo.Hash] = metadata.DateDecision{
+				TakenTime: p.photo.Meta.TakenTime,
+				Accuracy:  p.accuracy,
+			}
+		}
+	}
+
+	if review.reuseDecisions {
+		return metadata.SaveDateDecisions(decisionPath, decisions)
+	}
+	return nil
+}
+
+// interpolateDates gives unknown-date proposals a date between the nearest
+// dated files before and after them in the same folder (by file name),
+// provided those neighbors are at most maxGap apart. all includes photos
+// whose dates were settled earlier, so they count as neighbors too.
+func interpolateDates(proposals []dateProposal, all []*models.Photo, maxGap time.Duration) int {
+	type entry struct {
+		name     string
+		taken    time.Time
+		proposal int // index into proposals, or -1
+	}
+	pending := make(map[*models.Photo]int, len(proposals))
+	for i, p := range proposals {
+		pending[p.photo] = i
+	}
+	folders := make(map[string][]entry)
+	for _, photo := range all {
+		e := entry{name: filepath.Base(photo.SrcPath), proposal: -1}
+		if i, ok := pending[photo]; ok {
+			e.proposal = i
+			if proposals[i].accuracy != metadata.DateAccuracyNone {
+				e.taken = proposals[i].proposed
+			}
+		} else if photo.Meta.TakenTime != "" {
+			e.taken, _ = time.Parse(time.RFC3339, photo.Meta.TakenTime)
+		}
+		dir := filepath.Dir(photo.SrcPath)
+		folders[dir] = append(folders[dir], e)
+	}
+
+	inferred := 0
+	for _, entries := range folders {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+		for i, e := range entries {
+			if e.proposal < 0 || !e.taken.IsZero() {
+				continue
+			}
+			prev, next := -1, -1
+			for j := i - 1; j >= 0; j-- {
+				if !entries[j].taken.IsZero() {
+					prev = j
+					break
+				}
+			}
+			for j := i + 1; j < len(entries); j++ {
+				if !entries[j].taken.IsZero() {
+					next = j
+					break
+				}
+			}
+			if prev < 0 || next < 0 {
+				continue
+			}
+			from, to := entries[prev].taken, entries[next].taken
+			gap := to.Sub(from)
+			if gap < 0 || gap > maxGap {
+				continue
+			}
+			step := gap * time.Duration(i-prev) / time.Duration(next-prev)
+			p := &proposals[e.proposal]
+			p.proposed = from.Add(step)
+			p.accuracy = metadata.DateAccuracyInterpolated
+			inferred++
+		}
+	}
+	return inferred
+}
+
+// applySavedDecisions applies previously confirmed dates to photos whose hash
+// has a saved decision and returns the proposals that still need review.
+func applySavedDecisions(proposals []dateProposal, decisions map[string]metadata.DateDecision) ([]dateProposal, int) {
+	if len(decisions) == 0 {
+		return proposals, 0
+	}
+	pending := make([]dateProposal, 0, len(proposals))
+	reused := 0
+	for _, p := range proposals {
+		d, ok := decisions[p.photo.Hash]
+		if p.photo.Hash == "" || !ok || d.TakenTime == "" {
+			pending = append(pending, p)
+			continue
+		}
+		p.photo.Meta.TakenTime = d.TakenTime
+		p.photo.DateAccuracy = d.Accuracy
+		reused++
+	}
+	return pending, reused
+}
+
+// dateSpillDir is where collectDateProposals keeps parsed sidecars on
+// -memory-target runs; empty keeps them in memory.
+var dateSpillDir string
+
+// dateSpillChunk is how many parsed sidecars a -memory-target run holds in
+// memory at once.
+const dateSpillChunk = 4096
+
+// parsedDate is what collectDateProposals reads for one photo before EXIF.
+// Its fields are exported so it can be spilled to disk.
+type parsedDate struct {
+	JSONMeta    metadata.JSONMeta
+	HasJSONMeta bool
+	JSONTime    time.Time
+	HasJSON     bool
+	FileTime    time.Time
+	HasFile     bool
+}
+
+// collectDateProposals works out a date for every photo. When cache is not
+// nil, photos with a matching entry (same hash, name, sidecar presence and
+// settings) skip parsing, and fresh results are added to it. With
+// dateSpillDir set, the parsed sidecars wait on disk for the EXIF pass in
+// dateSpillChunk slices instead of all being held in memory.
+func collectDateProposals(photos []*models.Photo, custom []metadata.CustomPattern, exclusions map[string]bool, cache map[string]metadata.DateCacheEntry, progress func(done, total int)) []dateProposal {
+	proposals, err := collectDateProposalsTo(photos, custom, exclusions, cache, progress, dateSpillDir)
+	if err != nil {
+		fmt.Printf("Spill to disk failed, analyzing dates in memory: %v\n", err)
+		proposals, _ = collectDateProposalsTo(photos, custom, exclusions, cache, progress, "")
+	}
+	return proposals
+}
+
+func collectDateProposalsTo(photos []*models.Photo, custom []metadata.CustomPattern, exclusions map[string]bool, cache map[string]metadata.DateCacheEntry, progress func(done, total int), spillTo string) ([]dateProposal, error) {
+	workers := runtime.NumCPU()
+	total := len(photos)
+	var processed int64
+
+	settings := metadata.DateSettingsFingerprint(custom, exclusions)
+	cached := make([]bool, total)
+	proposals := make([]dateProposal, total)
+	var keys []string
+	var sidecars [][2]int64
+	if cache != nil {
+		keys = make([]string, total)
+		sidecars = make([][2]int64, total)
+		for i, p := range photos {
+			keys[i] = dateCacheKey(p)
+			sidecars[i] = sidecarStamp(p)
+			entry, ok := cache[keys[i]]
+			if keys[i] == "" || !ok || entry.Settings != settings || entry.Name != filepath.Base(p.SrcPath) || entry.HasSidecar != hasSidecar(p) {
+				continue
+			}
+			if entry.SidecarSize != sidecars[i][0] || entry.SidecarMtimeNs != sidecars[i][1] {
+				continue
+			}
+			cached[i] = true
+			if entry.HasJSONMeta {
+				applyJSONMeta(p, entry.JSON)
+			}
+			proposals[i] = dateProposal{
+				photo:    p,
+				jsonTime: entry.JSONTime,
+				fileTime: entry.FileTime,
+				exifTime: entry.ExifTime,
+				hasJSON:  entry.HasJSON,
+				hasFile:  entry.HasFile,
+				hasExif:  entry.HasExif,
+				proposed: entry.Proposed,
+				accuracy: entry.Accuracy,
+			}
+		}
+	}
+
+	chunk := max(total, 1)
+	var log *spill.Log[parsedDate]
+	if spillTo != "" {
+		var err error
+		if log, err = spill.New[parsedDate](spillTo); err != nil {
+			return nil, err
+		}
+		defer log.Remove()
+		chunk = min(chunk, dateSpillChunk)
+	}
+	results := make([]parsedDate, chunk)
+
+	// JSON sidecars and filenames first; EXIF is only needed for files that
+	// have neither, and is read in batches afterwards.
+	var needExif []string
+	for start := 0; start < total; start += chunk {
+		rs := results[:min(chunk, total-start)]
+		parallelFor(len(rs), workers, func(j int) {
+			i := start + j
+			r := &rs[j]
+			*r = parsedDate{}
+			if !cached[i] {
+				p := photos[i]
+				r.JSONMeta, r.HasJSONMeta = metadata.ParseJSONMeta(p.JsonPath)
+				if !r.HasJSONMeta && p.Imported != nil {
+					r.JSONMeta, r.HasJSONMeta = metadata.JSONMetaFromImport(*p.Imported), true
+				}
+				r.JSONTime = r.JSONMeta.PhotoTakenTime
+				r.HasJSON = r.JSONMeta.HasPhotoTaken
+				if !r.HasJSON && r.JSONMeta.HasCreation {
+					r.JSONTime = r.JSONMeta.CreationTime
+					r.HasJSON = true
+				}
+				r.FileTime, r.HasFile = metadata.GuessDateFromFilenameWithCustomAndExclusions(p.SrcPath, custom, exclusions)
+			}
+			if progress != nil {
+				progress(int(atomic.AddInt64(&processed, 1)), total)
+			}
+		})
+		for j, r := range rs {
+			if !cached[start+j] && !r.HasJSON && !r.HasFile {
+				needExif = append(needExif, photos[start+j].SrcPath)
+			}
+			if log != nil {
+				if err := log.Append(r); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	metadata.PrefetchExifTakenTimes(needExif, workers, func(done, _ int) {
+		if progress != nil {
+			progress(total+done, total+len(needExif))
+		}
+	})
+
+	finish := func(start int, rs []parsedDate) {
+		parallelFor(len(rs), workers, func(j int) {
+			i := start + j
+			if cached[i] {
+				return
+			}
+			p := photos[i]
+			r := rs[j]
+			proposed, accuracy, ok, exifTime, hasExif := metadata.ExtractBestDateWithCustomAndExclusions(p.SrcPath, r.JSONTime, r.HasJSON, custom, exclusions)
+			if r.HasJSONMeta {
+				applyJSONMeta(p, r.JSONMeta)
+			}
+			if !ok {
+				accuracy = metadata.DateAccuracyNone
+			}
+			proposals[i] = dateProposal{
+				photo:    p,
+				jsonTime: r.JSONTime,
+				fileTime: r.FileTime,
+				exifTime: exifTime,
+				hasJSON:  r.HasJSON,
+				hasFile:  r.HasFile,
+				hasExif:  hasExif,
+				proposed: proposed,
+				accuracy: accuracy,
+			}
+		})
+		if cache == nil {
+			return
+		}
+		for j, r := range rs {
+			i := start + j
+			p := photos[i]
+			if cached[i] || keys[i] == "" {
+				continue
+			}
+			d := proposals[i]
+			cache[keys[i]] = metadata.DateCacheEntry{
+				Name:           filepath.Base(p.SrcPath),
+				HasSidecar:     hasSidecar(p),
+				SidecarSize:    sidecars[i][0],
+				SidecarMtimeNs: sidecars[i][1],
+				Settings:       settings,
+				JSON:           r.JSONMeta,
+				HasJSONMeta:    r.HasJSONMeta,
+				JSONTime:       r.JSONTime,
+				HasJSON:        r.HasJSON,
+				FileTime:       r.FileTime,
+				HasFile:        r.HasFile,
+				ExifTime:       d.exifTime,
+				HasExif:        d.hasExif,
+				Proposed:       d.proposed,
+				Accuracy:       d.accuracy,
+			}
+		}
+	}
+	if log == nil {
+		finish(0, results[:total])
+		return proposals, nil
+	}
+	read := 0
+	err := log.Each(func(r parsedDate) error {
+		results[read%chunk] = r
+		read++
+		if read%chunk == 0 || read == total {
+			n := (read-1)%chunk + 1
+			finish(read-n, results[:n])
+		}
+		return nil
+	})
+	return proposals, err
+}
+
+// dateCacheKey is p's key in the date cache: its content hash, or for
+// runs that do not hash (-dates-only, -in-place, -no-dedup) its absolute
+// path, size and mtime. It is empty when neither is known.
+func dateCacheKey(p *models.Photo) string {
+	if p.Hash != "" {
+		return p.Hash
+	}
+	info, err := os.Stat(p.SrcPath)
+	if err != nil {
+		return ""
+	}
+	path, err := filepath.Abs(p.SrcPath)
+	if err != nil {
+		path = p.SrcPath
+	}
+	return fmt.Sprintf("file:%s:%d:%d", path, info.Size(), info.ModTime().UnixNano())
+}
+
+// sidecarStamp returns the size and mtime of p's JSON sidecar, or zeros
+// without one, so an edited sidecar invalidates the cached date.
+func sidecarStamp(p *models.Photo) [2]int64 {
+	if p.JsonPath == "" {
+		return [2]int64{}
+	}
+	info, err := os.Stat(p.JsonPath)
+	if err != nil {
+		return [2]int64{}
+	}
+	return [2]int64{info.Size(), info.ModTime().UnixNano()}
+}
+
+// reloadSidecars reads the JSON of photos whose source copy was switched
+// after date analysis again, so their metadata comes from the copy used.
+func reloadSidecars(photos []*models.Photo) {
+	for _, p := range photos {
+		jsonMeta, ok := metadata.ParseJSONMeta(p.JsonPath)
+		if !ok {
+			continue
+		}
+		applyJSONMeta(p, jsonMeta)
+		if p.DateAccuracy == metadata.DateAccuracyJSON && jsonMeta.HasPhotoTaken {
+			p.Meta.TakenTime = jsonMeta.PhotoTakenTime.Format(time.RFC3339)
+		}
+	}
+}
+
+// hasSidecar reports whether p has sidecar metadata, from a JSON file or
+// an importer.
+func hasSidecar(p *models.Photo) bool {
+	return p.JsonPath != "" || p.Imported != nil
+}
+
+// applyJSONMeta copies the non-date fields of a JSON sidecar onto the photo.
+func applyJSONMeta(p *models.Photo, jsonMeta metadata.JSONMeta) {
+	if jsonMeta.HasCreation {
+		p.Meta.CreationTime = jsonMeta.CreationTime.Format(time.RFC3339)
+	}
+	p.Meta.Description = jsonMeta.Description
+	p.Meta.Favorited = jsonMeta.Favorited
+	p.Meta.People = append([]string{}, jsonMeta.People...)
+	p.Meta.URL = jsonMeta.URL
+	p.Meta.AppSource = jsonMeta.AppSource
+	p.Meta.Origin = models.GooglePhotosOrigin{
+		FromSharedAlbum:          jsonMeta.Origin.FromSharedAlbum,
+		FromPartnerSharing:       jsonMeta.Origin.FromPartnerSharing,
+		WebUpload:                jsonMeta.Origin.WebUpload,
+		MobileUpload:             jsonMeta.Origin.MobileUpload,
+		MobileUploadDeviceType:   jsonMeta.Origin.MobileUploadDeviceType,
+		MobileUploadDeviceFolder: jsonMeta.Origin.MobileUploadDeviceFolder,
+		CompositionType:          jsonMeta.Origin.CompositionType,
+	}
+	if jsonMeta.HasModified {
+		p.Meta.ModifyTime = jsonMeta.LastModified.Format(time.RFC3339)
+	}
+	p.Meta.Archived, p.Meta.HasArchived = jsonMeta.Archived, jsonMeta.HasArchived
+	p.Meta.Trashed, p.Meta.HasTrashed = jsonMeta.Trashed, jsonMeta.HasTrashed
+	p.Meta.ImageViews = jsonMeta.ImageViews
+	if jsonMeta.HasGeo {
+		p.Meta.HasGeo = true
+		p.Meta.GPSLat = jsonMeta.Geo.Latitude
+		p.Meta.GPSLon = jsonMeta.Geo.Longitude
+		p.Meta.GPSAlt = jsonMeta.Geo.Altitude
+		p.Meta.GPSSpanLat = jsonMeta.Geo.LatitudeSpan
+		p.Meta.GPSSpanLon = jsonMeta.Geo.LongitudeSpan
+	}
+}
+
+// parallelFor calls fn(0..n-1) on up to workers goroutines.
+func parallelFor(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	next := int64(-1)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func filterUnknown(proposals []dateProposal) []dateProposal {
+	var out []dateProposal
+	for _, p := range proposals {
+		if !p.hasJSON && !p.hasFile {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+type reviewOptions struct {
+	pageSize   int
+	limit      int
+	overflow   string
+	categories map[string]bool
+
+	reuseDecisions bool
+	dateCache      bool
+	// export writes the review to a CSV file instead of prompting; file
+	// applies a filled-in CSV without prompting.
+	export string
+	file   string
+	// assumeApply accepts the proposed dates without any prompt.
+	assumeApply bool
+	// interpolate is the largest gap between dated neighbors that unknown
+	// dates are interpolated across; 0 disables interpolation.
+	interpolate time.Duration
+}
+
+// interactive reports whether the date review may prompt on stdin.
+func (o reviewOptions) interactive() bool {
+	return o.export == "" && o.file == "" && !o.assumeApply
+}
+
+type reviewCategory struct {
+	key   string
+	title string
+	items []dateProposal
+	lines func(p dateProposal) []string
+}
+
+// parseDurationDays extends time.ParseDuration with a "d" (24h) unit.
+func parseDurationDays(value string) (time.Duration, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(value, "d"), 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+// parsePatternZones parses "name=zone,name=zone" for -pattern-tz.
+func parsePatternZones(value string) (map[string]string, error) {
+	zones := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, zone, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=zone, got %q", item)
+		}
+		zones[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(zone)
+	}
+	return zones, nil
+}
+
+func parseReviewCategories(value string) (map[string]bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "all") {
+		return nil, nil
+	}
+	set := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		key := strings.ToLower(strings.TrimSpace(part))
+		switch key {
+		case "":
+			continue
+		case "overrides", "filename", "exif", "interpolated", "unknown":
+			set[key] = true
+		default:
+			return nil, fmt.Errorf("unknown review category: %s", part)
+		}
+	}
+	return set, nil
+}
+
+func buildReviewCategories(proposals []dateProposal) []reviewCategory {
+	var overrides []dateProposal
+	var filenameOnly []dateProposal
+	var exifOnly []dateProposal
+	var interpolated []dateProposal
+	var unknown []dateProposal
+
+	for _, p := range proposals {
+		switch {
+		case p.hasJSON && p.hasFile && p.accuracy == metadata.DateAccuracyFilename:
+			overrides = append(overrides, p)
+		case !p.hasJSON && p.hasFile:
+			filenameOnly = append(filenameOnly, p)
+		case !p.hasJSON && !p.hasFile && p.hasExif:
+			exifOnly = append(exifOnly, p)
+		case p.accuracy == metadata.DateAccuracyInterpolated:
+			interpolated = append(interpolated, p)
+		case !p.hasJSON && !p.hasFile:
+			unknown = append(unknown, p)
+		}
+	}
+
+	return []reviewCategory{
+		{
+			key:   "overrides",
+			title: "Overrides (filename older than JSON)",
+			items: overrides,
+			lines: func(p dateProposal) []string {
+				return []string{fmt.Sprintf("JSON: %s  Filename: %s", p.jsonTime.Format(time.RFC3339), p.fileTime.Format(time.RFC3339))}
+			},
+		},
+		{
+			key:   "filename",
+			title: "Filename-only dates",
+			items: filenameOnly,
+			lines: func(p dateProposal) []string {
+				return []string{fmt.Sprintf("Filename: %s", p.fileTime.Format(time.RFC3339))}
+			},
+		},
+		{
+			key:   "exif",
+			title: "EXIF-only dates",
+			items: exifOnly,
+			lines: func(p dateProposal) []string {
+				return []string{fmt.Sprintf("EXIF: %s", p.exifTime.Format(time.RFC3339))}
+			},
+		},
+		{
+			key:   "interpolated",
+			title: "Interpolated from neighboring files",
+			items: interpolated,
+			lines: func(p dateProposal) []string {
+				return []string{fmt.Sprintf("Interpolated: %s", p.proposed.Format(time.RFC3339))}
+			},
+		},
+		{
+			key:   "unknown",
+			title: "Unknown dates",
+			items: unknown,
+			lines: func(p dateProposal) []string { return nil },
+		},
+	}
+}
+
+func printDateReview(proposals []dateProposal, opts reviewOptions) error {
+	var overflow strings.Builder
+	quit := false
+
+	fmt.Println(i18n.T("Date review:"))
+	for _, cat := range buildReviewCategories(proposals) {
+		fmt.Printf("%s: %d\n", i18n.T(cat.title), len(cat.items))
+		if opts.categories != nil && !opts.categories[cat.key] {
+			continue
+		}
+		shown := len(cat.items)
+		if opts.limit > 0 && shown > opts.limit {
+			shown = opts.limit
+		}
+		for i := 0; i < len(cat.items); i++ {
+			p := cat.items[i]
+			if quit || i >= shown {
+				if opts.overflow != "" {
+					fmt.Fprintf(&overflow, "[%s] %s\n", cat.key, p.photo.SrcPath)
+					for _, line := range cat.lines(p) {
+						fmt.Fprintf(&overflow, "   %s\n", line)
+					}
+				}
+				continue
+			}
+			fmt.Printf("%d. %s\n", i+1, p.photo.SrcPath)
+			for _, line := range cat.lines(p) {
+				fmt.Printf("   %s\n", line)
+			}
+			if opts.pageSize > 0 && !opts.assumeApply && (i+1)%opts.pageSize == 0 && i+1 < shown {
+				switch promptPage(i+1, shown) {
+				case "s":
+					shown = i + 1
+				case "q":
+					quit = true
+				}
+			}
+		}
+		if hidden := len(cat.items) - shown; hidden > 0 && !quit {
+			fmt.Printf("   ... %d more\n", hidden)
+		}
+	}
+
+	if opts.overflow != "" && overflow.Len() > 0 {
+		if err := os.MkdirAll(filepath.Dir(opts.overflow), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(opts.overflow, []byte(overflow.String()), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Remaining review entries written to %s\n", opts.overflow)
+	}
+	return nil
+}
+
+// reviewOverrides lets the user accept or reject "filename older than JSON"
+// overrides for all of them, per name-pattern group, or per file. Rejected
+// overrides fall back to the JSON timestamp.
+func reviewOverrides(proposals []dateProposal) {
+	var idxs []int
+	for i, p := range proposals {
+		if p.hasJSON && p.hasFile && p.accuracy == metadata.DateAccuracyFilename {
+			idxs = append(idxs, i)
+		}
+	}
+	if len(idxs) == 0 {
+		return
+	}
+
+	// Anything else is asked again, so a typo never accepts every override.
+	// An empty answer, also what a closed input gives, takes the default.
+choose:
+	for {
+		decision := strings.ToLower(promptLine(fmt.Sprintf("Overrides (%d): accept all / reject all / group [accept]", len(idxs))))
+		switch decision {
+		case "", "accept", "accept all", "all":
+			return
+		case "reject", "reject all", "none":
+			for _, i := range idxs {
+				rejectOverride(&proposals[i])
+			}
+			fmt.Printf("Rejected %d overrides, keeping JSON dates.\n", len(idxs))
+			return
+		case "group", "groups":
+			break choose
+		default:
+			fmt.Println("Unrecognized choice; answer accept, reject or group.")
+		}
+	}
+
+	groups := make(map[string][]int)
+	var keys []string
+	for _, i := range idxs {
+		key := normalizeNamePattern(filepath.Base(proposals[i].photo.SrcPath))
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if len(groups[keys[a]]) == len(groups[keys[b]]) {
+			return keys[a] < keys[b]
+		}
+		return len(groups[keys[a]]) > len(groups[keys[b]])
+	})
+
+	rejected := 0
+	for _, key := range keys {
+		members := groups[key]
+		first := proposals[members[0]]
+		fmt.Printf("  %s (%d files), e.g. %s\n", key, len(members), filepath.Base(first.photo.SrcPath))
+		fmt.Printf("    JSON: %s  Filename: %s\n", first.jsonTime.Format(time.RFC3339), first.fileTime.Format(time.RFC3339))
+		answer := strings.ToLower(promptLine("  accept / reject / each [accept]"))
+		for !overrideGroupAnswers[answer] {
+			fmt.Println("  Unrecognized choice; answer accept, reject or each.")
+			answer = strings.ToLower(promptLine("  accept / reject / each [accept]"))
+		}
+		switch answer {
+		case "reject", "r", "n":
+			for _, i := range members {
+				rejectOverride(&proposals[i])
+			}
+			rejected += len(members)
+		case "each", "e":
+			for _, i := range members {
+				p := proposals[i]
+				label := fmt.Sprintf("    %s JSON %s -> filename %s, accept", filepath.Base(p.photo.SrcPath), p.jsonTime.Format(time.RFC3339), p.fileTime.Format(time.RFC3339))
+				if !promptYesNo(label, true) {
+					rejectOverride(&proposals[i])
+					rejected++
+				}
+			}
+		}
+	}
+	fmt.Printf("Overrides accepted: %d, rejected: %d\n", len(idxs)-rejected, rejected)
+}
+
+// overrideGroupAnswers are the answers the per-group override prompt takes.
+var overrideGroupAnswers = map[string]bool{
+	"": true, "accept": true, "a": true, "y": true,
+	"reject": true, "r": true, "n": true,
+	"each": true, "e": true,
+}
+
+func rejectOverride(p *dateProposal) {
+	p.proposed = p.jsonTime
+	p.accuracy = metadata.DateAccuracyJSON
+}
+
+func promptPage(shown, total int) string {
+	line := promptLine(fmt.Sprintf("-- %d/%d shown. Enter for next page, s to skip category, q to stop listing", shown, total))
+	return strings.ToLower(strings.TrimSpace(line))
+}
+
+func promptCustomPatternsLoop(unknown []dateProposal, custom []metadata.CustomPattern, exclusions map[string]bool, path string, exclusionPath string) ([]metadata.CustomPattern, map[string]bool, error) {
+	fmt.Print(i18n.T("Unknown date files detected. You can add custom date regex patterns.\n"))
+	fmt.Print(i18n.Tf("Patterns will be saved to %s\n", path))
+
+	unknownPaths := make([]string, 0, len(unknown))
+	for _, p := range unknown {
+		unknownPaths = append(unknownPaths, p.photo.SrcPath)
+	}
+
+	for {
+		fmt.Println(i18n.T("Unknown file groups (by name pattern):"))
+		printUnknownGroups(unknown, 50)
+		fmt.Println(i18n.T("Enter a regex that matches only the date portion."))
+		fmt.Println(i18n.T("If you include a capture group, group 1 will be parsed as the date."))
+		fmt.Println("Example regex: (20|19)\\d{2}[01]\\d[0-3]\\d_\\d{6}")
+		fmt.Println("Special layouts: UNIX (seconds), UNIXMS (milliseconds).")
+
+		regex := promptLine("Date regex (blank to stop)")
+		if strings.TrimSpace(regex) == "" {
+			break
+		}
+		layout := promptLine("Time layout for regex match (example: 20060102_150405)")
+		if strings.TrimSpace(layout) == "" {
+			fmt.Println(i18n.T("Layout is required."))
+			continue
+		}
+
+		zone := ""
+		if upper := strings.ToUpper(strings.TrimSpace(layout)); upper != "UNIX" && upper != "UNIXMS" {
+			zone = strings.TrimSpace(promptLine("Timezone of the timestamp (blank for local, e.g. UTC, +05:30, Europe/Berlin)"))
+		}
+		loc, err := metadata.ParseTimezone(zone)
+		if err != nil {
+			fmt.Println("Invalid timezone:", err)
+			continue
+		}
+
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			fmt.Println("Invalid regex:", err)
+			continue
+		}
+
+		matched, parsed, previews := previewCustomPattern(re, layout, loc, unknownPaths)
+		fmt.Print(i18n.Tf("Pattern matched %d files, parsed %d dates.\n", matched, parsed))
+		if len(previews) > 0 {
+			fmt.Println(i18n.T("Preview of parsed dates:"))
+			for i, p := range previews {
+				fmt.Printf("  %d. %s -> %s\n", i+1, p.path, p.date)
+			}
+		}
+		if matched == 0 || parsed == 0 {
+			if !promptYesNo("Keep this pattern anyway", false) {
+				continue
+			}
+		}
+
+		decision := promptLine("Accept? all / none / exclude 1,2,3")
+		decision = strings.TrimSpace(strings.ToLower(decision))
+		if decision == "none" {
+			continue
+		}
+		if decision != "all" && decision != "" {
+			excluded, err := parseIndexList(decision, len(previews))
+			if err != nil {
+				fmt.Println("Invalid exclude list:", err)
+				continue
+			}
+			for _, idx := range excluded {
+				if idx < 1 || idx > len(previews) {
+					continue
+				}
+				exclusions[previews[idx-1].path] = true
+			}
+			if err := metadata.SaveDateExclusions(exclusionPath, exclusions); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		custom = append(custom, metadata.CustomPattern{
+			Regex:    regex,
+			Layout:   layout,
+			Timezone: zone,
+		})
+		if err := metadata.SaveCustomPatterns(path, custom); err != nil {
+			return nil, nil, err
+		}
+		break
+	}
+
+	return custom, exclusions, nil
+}
+
+type previewEntry struct {
+	path string
+	date string
+}
+
+func previewCustomPattern(re *regexp.Regexp, layout string, loc *time.Location, paths []string) (int, int, []previewEntry) {
+	matched := 0
+	parsed := 0
+	previews := make([]previewEntry, 0, len(paths))
+	for _, path := range paths {
+		base := filepath.Base(path)
+		sub := re.FindStringSubmatch(base)
+		if len(sub) == 0 {
+			continue
+		}
+		target := sub[0]
+		if len(sub) > 1 {
+			target = sub[1]
+		}
+		matched++
+		t, ok := metadata.ParseWithLayoutIn(layout, target, loc)
+		if !ok {
+			continue
+		}
+		parsed++
+		previews = append(previews, previewEntry{
+			path: base,
+			date: t.Format(time.RFC3339),
+		})
+	}
+	return matched, parsed, previews
+}
+
+func promptApplyConfirmation() bool {
+	fmt.Println(i18n.T("Review is required before applying date changes."))
+	fmt.Println(i18n.T("Type APPLY to continue, or anything else to cancel."))
+	line := promptLine("Confirmation")
+	return strings.EqualFold(strings.TrimSpace(line), "APPLY")
+}
+
+func promptPath(label, defaultPath string) string {
+	reader := bufio.NewReader(os.Stdin)
+	label = i18n.T(label)
+	if defaultPath != "" {
+		fmt.Printf("%s (default: %s): ", label, defaultPath)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+	line, _ := reader.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return defaultPath
+	}
+	return line
+}
+
+func promptLine(label string) string {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("%s: ", i18n.T(label))
+	line, _ := reader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func promptYesNo(label string, defaultYes bool) bool {
+	reader := bufio.NewReader(os.Stdin)
+	label = i18n.T(label)
+	if defaultYes {
+		fmt.Printf("%s [Y/n]: ", label)
+	} else {
+		fmt.Printf("%s [y/N]: ", label)
+	}
+	line, _ := reader.ReadString('\n')
+	line = strings.TrimSpace(strings.ToLower(line))
+	if line == "" {
+		return defaultYes
+	}
+	return line == "y" || line == "yes"
+}
+
+// printPlannedTags summarizes the metadata a dry run would have written.
+func printPlannedTags(counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	fmt.Println("Metadata that would be written (tag: files):")
+	for _, tag := range tags {
+		fmt.Printf("  %s: %d\n", tag, counts[tag])
+	}
+}
+
+// tagSources writes each photo's metadata into its source file and reports
+// the outcome as an exit code.
+func tagSources(photos []*models.Photo, inRoot string, opts output.Options) int {
+	tagBar := newProgressBar("Tagging")
+	tagStats, err := output.TagInPlace(photos, opts, tagBar.Update)
+	tagBar.Finish()
+	if err != nil {
+		console.Errorln("Tagging error:", err)
+		return exitMetadataFailure
+	}
+	fmt.Printf("Tagged in place: %d, XMP sidecars: %d\n", tagStats.Tagged, tagStats.Sidecars)
+	printPlannedTags(tagStats.PlannedTags)
+	if opts.ArgFile != "" && !opts.DryRun {
+		fmt.Printf("exiftool commands written to %s: %d\n", opts.ArgFile, tagStats.Exported)
+	}
+	if len(tagStats.MetadataReport) > 0 {
+		reportPath, err := output.WriteMetadataReport(inRoot, tagStats.MetadataReport)
+		if err != nil {
+			console.Errorln("Metadata report error:", err)
+		} else {
+			console.Warnf("Metadata problems for %d files, see %s\n", len(tagStats.MetadataReport), reportPath)
+		}
+	}
+	if tagStats.MetadataFailures > 0 {
+		console.Warnf("Metadata writes failed for %d files\n", tagStats.MetadataFailures)
+		return exitMetadataFailure
+	}
+	if opts.DryRun {
+		fmt.Println(console.Success(i18n.T("Dry run complete.")))
+	} else {
+		fmt.Println(console.Success(i18n.T("Done.")))
+	}
+	return exitOK
+}
+
+// recordSourcePaths stores each photo's Takeout-relative path in its
+// metadata so written files can be traced back to their origin.
+func recordSourcePaths(inRoot string, photos []*models.Photo) {
+	for _, p := range photos {
+		if rel, err := filepath.Rel(inRoot, p.SrcPath); err == nil {
+			p.Meta.SourcePath = filepath.ToSlash(rel)
+		}
+	}
+}
+
+// embedSourceJSON loads each photo's sidecar into Meta.SourceJSON in compact
+// form, so the embedded copy is no larger than it needs to be.
+func embedSourceJSON(photos []*models.Photo) int {
+	embedded := 0
+	for _, p := range photos {
+		if p.JsonPath == "" {
+			continue
+		}
+		data, err := os.ReadFile(p.JsonPath)
+		if err != nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, data); err != nil {
+			continue
+		}
+		p.Meta.SourceJSON = buf.String()
+		embedded++
+	}
+	return embedded
+}
+
+func routeScreenshots(photos []*models.Photo) int {
+	routed := 0
+	for _, p := range photos {
+		if classify.IsScreenshot(p) {
+			p.Route = "Screenshots"
+			routed++
+		}
+	}
+	return routed
+}
+
+// videoKindFolders names the folders -video-kind-folders and the {{kind}}
+// layout token use.
+var videoKindFolders = map[string]string{
+	classify.KindSlowMotion: "Slow-motion",
+	classify.KindTimelapse:  "Timelapse",
+}
+
+func routeVideoKinds(photos []*models.Photo) (slowMotion, timelapse int) {
+	for _, p := range photos {
+		kind := classify.VideoKind(p)
+		folder, ok := videoKindFolders[kind]
+		if !ok {
+			continue
+		}
+		p.Route = folder
+		if kind == classify.KindSlowMotion {
+			slowMotion++
+		} else {
+			timelapse++
+		}
+	}
+	return slowMotion, timelapse
+}
+
+// kindFolder expands {{kind}}: Photos, Videos, Slow-motion or Timelapse.
+func kindFolder(p *models.Photo) string {
+	if folder, ok := videoKindFolders[classify.VideoKind(p)]; ok {
+		return folder
+	}
+	if metadata.IsVideoPath(p.SrcPath) {
+		return "Videos"
+	}
+	return "Photos"
+}
+
+func routePartnerMedia(photos []*models.Photo) int {
+	routed := 0
+	for _, p := range photos {
+		if classify.IsPartnerShared(p) {
+			p.Route = "Partner"
+			routed++
+		}
+	}
+	return routed
+}
+
+func excludePartnerMedia(photos []*models.Photo) []*models.Photo {
+	out := make([]*models.Photo, 0, len(photos))
+	for _, p := range photos {
+		if !classify.IsPartnerShared(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// routeUnknownDates sends undated library files to Unknown/<folder>/, where
+// folder is their source folder relative to the Takeout root.
+func routeUnknownDates(inRoot string, photos []*models.Photo) int {
+	routed := 0
+	for _, p := range photos {
+		if p.Meta.TakenTime != "" || strings.TrimSpace(p.FinalAlbum) != "" || strings.TrimSpace(p.Route) != "" {
+			continue
+		}
+		folder, err := filepath.Rel(inRoot, filepath.Dir(p.SrcPath))
+		if err != nil || strings.HasPrefix(folder, "..") {
+			folder = filepath.Base(filepath.Dir(p.SrcPath))
+		}
+		p.Route = filepath.Join("Unknown", folder)
+		routed++
+	}
+	return routed
+}
+
+// trashFolders and archiveFolders are the folder names Takeout has used for
+// the bin and the archive across versions and languages. They flag items
+// whose JSON predates the trashed and archived fields, and only in Takeout's
+// own top-level folders, so a user album named "Archive" is left alone.
+var (
+	trashFolders   = map[string]bool{"trash": true, "bin": true, "papierkorb": true, "corbeille": true, "papelera": true, "cestino": true, "prullenbak": true, "lixeira": true}
+	archiveFolders = map[string]bool{"archive": true, "archiv": true, "archives": true, "archivo": true, "archivio": true, "archief": true, "arquivo": true}
+)
 
-	fmt.Printf("Unknown dates: %d\n", len(unknown))
-	for i, p := range unknown {
-		fmt.Printf("%d. %s\n", i+1, p.photo.SrcPath)
+func isTrashed(p *models.Photo) bool {
+	if p.Meta.HasTrashed {
+		return p.Meta.Trashed
 	}
+	return inSpecialFolder(p, trashFolders)
 }
 
-func promptCustomPatternsLoop(unknown []dateProposal, custom []metadata.CustomPattern, exclusions map[string]bool, path string, exclusionPath string) ([]metadata.CustomPattern, map[string]bool, error) {
-	fmt.Printf("Unknown date files detected. You can add custom date regex patterns.\n")
-	fmt.Printf("Patterns will be saved to %s\n", path)
-
-	unknownPaths := make([]string, 0, len(unknown))
-	for _, p := range unknown {
-		unknownPaths = append(unknownPaths, p.photo.SrcPath)
+func isArchived(p *models.Photo) bool {
+	if p.Meta.HasArchived {
+		return p.Meta.Archived
 	}
+	return inSpecialFolder(p, archiveFolders)
+}
 
-	for {
-		fmt.Println("Unknown file groups (by name pattern):")
-		printUnknownGroups(unknown, 50)
-		fmt.Println("Enter a regex that matches only the date portion.")
-		fmt.Println("If you include a capture group, group 1 will be parsed as the date.")
-		fmt.Println("Example regex: (20|19)\\d{2}[01]\\d[0-3]\\d_\\d{6}")
-		fmt.Println("Special layouts: UNIX (seconds), UNIXMS (milliseconds).")
-
-		regex := promptLine("Date regex (blank to stop)")
-		if strings.TrimSpace(regex) == "" {
-			break
-		}
-		layout := promptLine("Time layout for regex match (example: 20060102_150405)")
-		if strings.TrimSpace(layout) == "" {
-			fmt.Println("Layout is required.")
+// inSpecialFolder reports whether any source copy of p sits in one of
+// names directly below a Google Photos folder. User albums carry a
+// metadata.json (and newer exports keep them below Albums/), so a folder
+// with one is never taken for Takeout's own.
+func inSpecialFolder(p *models.Photo, names map[string]bool) bool {
+	for _, c := range p.Copies {
+		dir := filepath.Dir(c.MediaPath)
+		if !names[strings.ToLower(strings.TrimSpace(filepath.Base(dir)))] {
 			continue
 		}
-
-		re, err := regexp.Compile(regex)
-		if err != nil {
-			fmt.Println("Invalid regex:", err)
+		if !strings.EqualFold(filepath.Base(filepath.Dir(dir)), "Google Photos") {
 			continue
 		}
-
-		matched, parsed, previews := previewCustomPattern(re, layout, unknownPaths)
-		fmt.Printf("Pattern matched %d files, parsed %d dates.\n", matched, parsed)
-		if len(previews) > 0 {
-			fmt.Println("Preview of parsed dates:")
-			for i, p := range previews {
-				fmt.Printf("  %d. %s -> %s\n", i+1, p.path, p.date)
-			}
-		}
-		if matched == 0 || parsed == 0 {
-			if !promptYesNo("Keep this pattern anyway", false) {
-				continue
-			}
+		if _, err := os.Stat(filepath.Join(dir, "metadata.json")); err == nil {
+			continue
 		}
+		return true
+	}
+	return false
+}
 
-		decision := promptLine("Accept? all / none / exclude 1,2,3")
-		decision = strings.TrimSpace(strings.ToLower(decision))
-		if decision == "none" {
+// routeFlagged sends flagged items into folder, overriding any other
+// routing except Trash/: an item both trashed and archived stays there.
+func routeFlagged(photos []*models.Photo, flagged func(*models.Photo) bool, folder string) int {
+	routed := 0
+	for _, p := range photos {
+		if p.Route == "Trash" || !flagged(p) {
 			continue
 		}
-		if decision != "all" && decision != "" {
-			excluded, err := parseIndexList(decision, len(previews))
-			if err != nil {
-				fmt.Println("Invalid exclude list:", err)
-				continue
-			}
-			for _, idx := range excluded {
-				if idx < 1 || idx > len(previews) {
-					continue
-				}
-				exclusions[previews[idx-1].path] = true
-			}
-			if err := metadata.SaveDateExclusions(exclusionPath, exclusions); err != nil {
-				return nil, nil, err
-			}
-		}
+		p.Route = folder
+		routed++
+	}
+	return routed
+}
 
-		custom = append(custom, metadata.CustomPattern{
-			Regex:  regex,
-			Layout: layout,
-		})
-		if err := metadata.SaveCustomPatterns(path, custom); err != nil {
-			return nil, nil, err
+func excludeFlagged(photos []*models.Photo, flagged func(*models.Photo) bool) []*models.Photo {
+	out := make([]*models.Photo, 0, len(photos))
+	for _, p := range photos {
+		if !flagged(p) {
+			out = append(out, p)
 		}
-		break
 	}
+	return out
+}
 
-	return custom, exclusions, nil
+func routeCreations(photos []*models.Photo) int {
+	routed := 0
+	for _, p := range photos {
+		if classify.IsCreation(p) {
+			p.Route = "Creations"
+			routed++
+		}
+	}
+	return routed
 }
 
-type previewEntry struct {
-	path string
-	date string
+func excludeCreations(photos []*models.Photo) []*models.Photo {
+	out := make([]*models.Photo, 0, len(photos))
+	for _, p := range photos {
+		if !classify.IsCreation(p) {
+			out = append(out, p)
+		}
+	}
+	return out
 }
 
-func previewCustomPattern(re *regexp.Regexp, layout string, paths []string) (int, int, []previewEntry) {
-	matched := 0
-	parsed := 0
-	previews := make([]previewEntry, 0, len(paths))
-	for _, path := range paths {
-		base := filepath.Base(path)
-		sub := re.FindStringSubmatch(base)
-		if len(sub) == 0 {
+func routeDeviceFolders(photos []*models.Photo) int {
+	routed := 0
+	for _, p := range photos {
+		if strings.TrimSpace(p.FinalAlbum) != "" || strings.TrimSpace(p.Route) != "" {
 			continue
 		}
-		target := sub[0]
-		if len(sub) > 1 {
-			target = sub[1]
-		}
-		matched++
-		t, ok := metadata.ParseWithLayout(layout, target)
-		if !ok {
+		folder := strings.TrimSpace(p.Meta.Origin.MobileUploadDeviceFolder)
+		if folder == "" {
 			continue
 		}
-		parsed++
-		previews = append(previews, previewEntry{
-			path: base,
-			date: t.Format(time.RFC3339),
-		})
+		p.Route = filepath.Join("Library", folder)
+		routed++
 	}
-	return matched, parsed, previews
-}
-
-func promptApplyConfirmation() bool {
-	fmt.Println("Review is required before applying date changes.")
-	fmt.Println("Type APPLY to continue, or anything else to cancel.")
-	line := promptLine("Confirmation")
-	return strings.EqualFold(strings.TrimSpace(line), "APPLY")
+	return routed
 }
 
-func promptPath(label, defaultPath string) string {
-	reader := bufio.NewReader(os.Stdin)
-	if defaultPath != "" {
-		fmt.Printf("%s (default: %s): ", label, defaultPath)
-	} else {
-		fmt.Printf("%s: ", label)
+// nameUntitledAlbums renames "Untitled" albums after their date range and
+// returns how many were renamed. Interactive runs review the proposed names
+// first; each can be accepted, replaced, or dropped.
+func nameUntitledAlbums(photos []*models.Photo, template string, interactive bool) int {
+	proposals := albums.ProposeUntitledNames(photos, template)
+	if len(proposals) == 0 {
+		return 0
 	}
-	line, _ := reader.ReadString('\n')
-	line = strings.TrimSpace(line)
-	if line == "" {
-		return defaultPath
+	fmt.Println(i18n.T("Proposed names for untitled albums:"))
+	for _, r := range proposals {
+		fmt.Printf("  %s -> %s (%d files, %s to %s)\n", r.Old, r.New, r.Files,
+			r.First.Format("2006-01-02"), r.Last.Format("2006-01-02"))
 	}
-	return line
+	renames := make(map[string]string, len(proposals))
+	if !interactive || promptYesNo("Use these album names?", true) {
+		for _, r := range proposals {
+			renames[r.Old] = r.New
+		}
+	} else {
+		fmt.Println(i18n.T("Enter a name for each album: empty accepts the proposal, - keeps the old name."))
+		for _, r := range proposals {
+			name := promptLine(fmt.Sprintf("%s [%s]", r.Old, r.New))
+			switch name {
+			case "":
+				renames[r.Old] = r.New
+			case "-":
+			default:
+				renames[r.Old] = name
+			}
+		}
+	}
+	albums.RenameAlbums(photos, renames)
+	return len(renames)
 }
 
-func promptLine(label string) string {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("%s: ", label)
-	line, _ := reader.ReadString('\n')
-	return strings.TrimSpace(line)
+func assignAppAlbums(photos []*models.Photo) int {
+	assigned := 0
+	for _, p := range photos {
+		if strings.TrimSpace(p.FinalAlbum) != "" {
+			continue
+		}
+		if app := classify.DetectApp(p); app != "" {
+			p.FinalAlbum = app
+			assigned++
+		}
+	}
+	return assigned
 }
 
-func promptYesNo(label string, defaultYes bool) bool {
-	reader := bufio.NewReader(os.Stdin)
-	if defaultYes {
-		fmt.Printf("%s [Y/n]: ", label)
-	} else {
-		fmt.Printf("%s [y/N]: ", label)
-	}
-	line, _ := reader.ReadString('\n')
-	line = strings.TrimSpace(strings.ToLower(line))
-	if line == "" {
-		return defaultYes
+func excludeMessagingMedia(photos []*models.Photo) []*models.Photo {
+	out := make([]*models.Photo, 0, len(photos))
+	for _, p := range photos {
+		if classify.IsMessagingApp(classify.DetectApp(p)) {
+			continue
+		}
+		out = append(out, p)
 	}
-	return line == "y" || line == "yes"
+	return out
 }
 
+// registryToSlice lists the registry by source path, so later stages see
+// the photos in the same order on every run.
 func registryToSlice(registry map[string]*models.Photo) []*models.Photo {
 	photos := make([]*models.Photo, 0, len(registry))
 	for _, p := range registry {
 		photos = append(photos, p)
 	}
+	sort.Slice(photos, func(i, j int) bool {
+		if photos[i].SrcPath != photos[j].SrcPath {
+			return photos[i].SrcPath < photos[j].SrcPath
+		}
+		return photos[i].Hash < photos[j].Hash
+	})
 	return photos
 }
 
@@ -494,9 +2875,13 @@ func photosFromScan(pairs []scanner.FilePair) []*models.Photo {
 		if p.Album != "" {
 			albumsMap[p.Album] = true
 		}
+		for _, album := range p.MoreAlbums {
+			albumsMap[album] = true
+		}
 		photos = append(photos, &models.Photo{
 			SrcPath:  p.MediaPath,
 			JsonPath: p.JsonPath,
+			Imported: p.Meta,
 			Albums:   albumsMap,
 		})
 	}
@@ -516,7 +2901,96 @@ func printScanSummary(pairs []scanner.FilePair) {
 			}
 		}
 	}
-	fmt.Printf("Scan summary: %d media files, %d with album, %d with JSON\n", len(pairs), withAlbum, withJSON)
+	fmt.Print(i18n.Tf("Scan summary: %d media files, %d with album, %d with JSON\n", len(pairs), withAlbum, withJSON))
+}
+
+// reportReconciliation compares the Takeout's folder listing and album
+// metadata, both taken at scan time, with what the run scanned and copied,
+// and lists the folders that disagree in the reconcile report.
+func reportReconciliation(inRoot, outRoot string, folders []scanner.FolderCount, scanned map[string]int, overlapKept map[string]string, photos []*models.Photo, dryRun bool) {
+	rows := output.Reconcile(folders, scanned, overlapKept, photos)
+	var files, unrecognized, missing, notCopied int
+	for _, f := range rows {
+		files += f.Files
+		unrecognized += f.Unrecognized()
+		missing += f.Missing()
+		notCopied += f.NotCopied()
+	}
+	if unrecognized+missing+notCopied == 0 {
+		fmt.Printf("Count reconciliation: all %d files in %d folders accounted for\n", files, len(rows))
+		if !dryRun {
+			if _, _, err := output.WriteReconcileReport(inRoot, outRoot, rows); err != nil {
+				console.Errorln("Reconciliation report error:", err)
+			}
+		}
+		return
+	}
+	console.Warnf("Count reconciliation: %d files in %d folders, differences found\n", files, len(rows))
+	if unrecognized > 0 {
+		fmt.Printf("  files not recognized as media: %d\n", unrecognized)
+	}
+	if missing > 0 {
+		fmt.Printf("  items album metadata declares but the export lacks: %d\n", missing)
+	}
+	if notCopied > 0 {
+		fmt.Printf("  scanned files not in the output (filtered, excluded or dropped; see the run summary): %d\n", notCopied)
+	}
+	if dryRun {
+		return
+	}
+	path, listed, err := output.WriteReconcileReport(inRoot, outRoot, rows)
+	if err != nil {
+		console.Errorln("Reconciliation report error:", err)
+		return
+	}
+	fmt.Printf("  folders with differences: %d (see %s)\n", listed, path)
+}
+
+func printRecompressedReport(drops []dedup.RecompressedDrop) {
+	fmt.Printf("Recompressed copies dropped: %d\n", len(drops))
+	for i, d := range drops {
+		fmt.Printf("%d. %s (%d bytes)\n", i+1, d.Dropped.SrcPath, d.Dropped.Size)
+		fmt.Printf("   kept: %s (%d bytes)\n", d.Kept.SrcPath, d.Kept.Size)
+	}
+}
+
+func printCollisions(collisions []output.Collision, verbose bool) {
+	if len(collisions) == 0 {
+		return
+	}
+	console.Warnf("Name collisions resolved: %d\n", len(collisions))
+	if !verbose {
+		return
+	}
+	for _, c := range collisions {
+		fmt.Printf("  %s -> %s\n", c.Wanted, filepath.Base(c.Resolved))
+	}
+}
+
+func printOverlapSummary(overlaps []scanner.Overlap, verbose bool) {
+	if len(overlaps) == 0 {
+		return
+	}
+	dropped := 0
+	conflicting := 0
+	for _, ov := range overlaps {
+		dropped += len(ov.Dropped)
+		conflicting += len(ov.Conflicting)
+	}
+	fmt.Printf("Overlapping Takeout parts: %d files, %d identical copies skipped, %d conflicting copies kept\n", len(overlaps), dropped, conflicting)
+	for _, ov := range overlaps {
+		if len(ov.Conflicting) > 0 {
+			console.Warnf("  Content differs for %s:\n", ov.LogicalPath)
+			fmt.Printf("    %s\n", ov.Kept)
+			for _, path := range ov.Conflicting {
+				fmt.Printf("    %s\n", path)
+			}
+			continue
+		}
+		if verbose {
+			fmt.Printf("  %s (kept %s)\n", ov.LogicalPath, ov.Kept)
+		}
+	}
 }
 
 func printAlbumSummary(photos []*models.Photo) {
@@ -526,14 +3000,122 @@ func printAlbumSummary(photos []*models.Photo) {
 			continue
 		}
 		album := strings.TrimSpace(p.FinalAlbum)
-		if album == "" {
+		if route := strings.TrimSpace(p.Route); route != "" {
+			album = route + "/"
+		} else if album == "" {
 			album = "(library)"
 		}
 		counts[album]++
 	}
-	fmt.Println("Album assignment summary:")
-	for album, count := range counts {
-		fmt.Printf("  %s: %d\n", album, count)
+	albums := make([]string, 0, len(counts))
+	for album := range counts {
+		albums = append(albums, album)
+	}
+	sort.Strings(albums)
+	fmt.Println(i18n.T("Album assignment summary:"))
+	for _, album := range albums {
+		fmt.Printf("  %s: %d\n", album, counts[album])
+	}
+	printCameraSummary(photos)
+}
+
+var layoutTokenRe = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+
+// validateLayout rejects -layout templates with unknown tokens.
+func validateLayout(layout string) error {
+	for _, m := range layoutTokenRe.FindAllStringSubmatch(layout, -1) {
+		switch m[1] {
+		case "camera", "year", "month", "kind":
+		default:
+			return fmt.Errorf("unknown -layout token: %s", m[0])
+		}
+	}
+	return nil
+}
+
+// routeByLayout places library files (no album or route yet) under
+// Library/<expanded layout>. Unknown cameras and dates get "Unknown Camera"
+// and "Undated" folders.
+func routeByLayout(photos []*models.Photo, layout string) int {
+	routed := 0
+	for _, p := range photos {
+		if strings.TrimSpace(p.FinalAlbum) != "" || strings.TrimSpace(p.Route) != "" {
+			continue
+		}
+		var taken time.Time
+		if p.Meta.TakenTime != "" {
+			taken, _ = time.Parse(time.RFC3339, p.Meta.TakenTime)
+		}
+		folder := layoutTokenRe.ReplaceAllStringFunc(layout, func(tok string) string {
+			switch layoutTokenRe.FindStringSubmatch(tok)[1] {
+			case "camera":
+				if camera := cameraName(p.Meta); camera != "" {
+					return strings.ReplaceAll(camera, "/", "_")
+				}
+				return "Unknown Camera"
+			case "year":
+				if taken.IsZero() {
+					return "Undated"
+				}
+				return taken.Format("2006")
+			case "month":
+				if taken.IsZero() {
+					return "Undated"
+				}
+				return taken.Format("01")
+			case "kind":
+				return kindFolder(p)
+			}
+			return tok
+		})
+		p.Route = filepath.Join("Library", folder)
+		routed++
+	}
+	return routed
+}
+
+// cameraName joins make and model, skipping the make when the model already
+// starts with it ("Canon" + "Canon EOS R5").
+func cameraName(meta models.MetaData) string {
+	maker, model := strings.TrimSpace(meta.CameraMake), strings.TrimSpace(meta.CameraModel)
+	if maker == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		return model
+	}
+	return strings.TrimSpace(maker + " " + model)
+}
+
+// printCameraSummary lists the most common camera models.
+func printCameraSummary(photos []*models.Photo) {
+	counts := make(map[string]int)
+	for _, p := range photos {
+		if p == nil {
+			continue
+		}
+		camera := cameraName(p.Meta)
+		if camera == "" {
+			camera = "(unknown)"
+		}
+		counts[camera]++
+	}
+	if len(counts) == 0 {
+		return
+	}
+	cameras := make([]string, 0, len(counts))
+	for c := range counts {
+		cameras = append(cameras, c)
+	}
+	sort.Slice(cameras, func(i, j int) bool {
+		if counts[cameras[i]] != counts[cameras[j]] {
+			return counts[cameras[i]] > counts[cameras[j]]
+		}
+		return cameras[i] < cameras[j]
+	})
+	if len(cameras) > 5 {
+		cameras = cameras[:5]
+	}
+	fmt.Println("Top cameras:")
+	for _, c := range cameras {
+		fmt.Printf("  %s: %d\n", c, counts[c])
 	}
 }
 
@@ -646,18 +3228,80 @@ func parseIndexList(input string, max int) ([]int, error) {
 	return out, nil
 }
 
+// pipeline tracks which stage of the run is active so every progress bar can
+// show the overall position and elapsed time.
+type pipeline struct {
+	total   int
+	current int
+	start   time.Time
+	// label and stageStart describe the running stage; timings holds the
+	// wall-clock time of the stages that have finished.
+	label      string
+	stageStart time.Time
+	timings    []stageTiming
+}
+
+type stageTiming struct {
+	label string
+	took  time.Duration
+}
+
+var stages *pipeline
+
+func newPipeline(total int) *pipeline {
+	return &pipeline{total: total, start: time.Now()}
+}
+
+func (pl *pipeline) next(label string) {
+	if pl == nil {
+		fmt.Println(label + "...")
+		return
+	}
+	if pl.current < pl.total {
+		pl.current++
+	}
+	pl.finishStage()
+	pl.label, pl.stageStart = label, time.Now()
+	fmt.Println(console.Heading(fmt.Sprintf("== Stage %d/%d: %s (elapsed %s) ==", pl.current, pl.total, label, pl.elapsed())))
+}
+
+// finishStage records the running stage's wall-clock time.
+func (pl *pipeline) finishStage() {
+	if pl == nil || pl.label == "" {
+		return
+	}
+	pl.timings = append(pl.timings, stageTiming{label: pl.label, took: time.Since(pl.stageStart)})
+	pl.label = ""
+}
+
+func (pl *pipeline) elapsed() string {
+	return time.Since(pl.start).Round(time.Second).String()
+}
+
+// plainProgress makes progress bars print whole lines at plainProgressEvery
+// instead of redrawing themselves with carriage returns.
+var plainProgress bool
+
+const plainProgressEvery = 10 * time.Second
+
 type progressBar struct {
+	// mu serializes Update, which worker pools call concurrently.
+	mu          sync.Mutex
 	label       string
 	width       int
 	lastPercent int
 	lastTime    time.Time
+	stages      *pipeline
+	printed     bool // a plain status line was printed
 }
 
 func newProgressBar(label string) *progressBar {
-	return &progressBar{label: label, width: 30}
+	return &progressBar{label: label, width: 30, stages: stages}
 }
 
 func (p *progressBar) Update(done, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if total <= 0 {
 		return
 	}
@@ -666,6 +3310,10 @@ func (p *progressBar) Update(done, total int) {
 	}
 	percent := int(float64(done) / float64(total) * 100)
 	now := time.Now()
+	if plainProgress {
+		p.plainUpdate(done, total, percent, now)
+		return
+	}
 	if done != total {
 		if percent == p.lastPercent && now.Sub(p.lastTime) < 750*time.Millisecond {
 			return
@@ -682,9 +3330,35 @@ func (p *progressBar) Update(done, total int) {
 		filled = p.width
 	}
 	bar := strings.Repeat("#", filled) + strings.Repeat("-", p.width-filled)
+	if p.stages != nil {
+		fmt.Printf("\r%s [%s] %d/%d  (stage %d/%d, %s)", p.label, bar, done, total, p.stages.current, p.stages.total, p.stages.elapsed())
+		return
+	}
 	fmt.Printf("\r%s [%s] %d/%d", p.label, bar, done, total)
 }
 
+// plainUpdate prints one status line when the bar starts, when it is done,
+// and otherwise every plainProgressEvery.
+func (p *progressBar) plainUpdate(done, total, percent int, now time.Time) {
+	if p.printed && done != total && now.Sub(p.lastTime) < plainProgressEvery {
+		return
+	}
+	if p.printed && done == total && p.lastPercent == 100 {
+		return
+	}
+	p.printed = true
+	p.lastPercent = percent
+	p.lastTime = now
+	if p.stages != nil {
+		fmt.Printf("%s: %d/%d (%d%%, stage %d/%d, %s)\n", p.label, done, total, percent, p.stages.current, p.stages.total, p.stages.elapsed())
+		return
+	}
+	fmt.Printf("%s: %d/%d (%d%%)\n", p.label, done, total, percent)
+}
+
 func (p *progressBar) Finish() {
+	if plainProgress {
+		return
+	}
 	fmt.Println()
 }
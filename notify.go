@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// exitCodeNames describes each exit code for notifications.
+var exitCodeNames = map[int]string{
+	exitOK:              "run completed",
+	exitFailure:         "unexpected error",
+	exitUsage:           "invalid flags or arguments",
+	exitScanError:       "scan error or no media found",
+	exitCancelled:       "date review cancelled",
+	exitCopyFailure:     "copy failure",
+	exitMetadataFailure: "metadata write failures",
+	exitPartial:         "completed with quarantined files",
+	exitStopped:         "stopped by -max-runtime",
+}
+
+// notifyTarget is a parsed -notify value: webhook:URL, email:ADDRESS, or
+// desktop.
+type notifyTarget struct {
+	kind string
+	dest string
+}
+
+func parseNotifyTarget(value string) (notifyTarget, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "desktop") {
+		return notifyTarget{kind: "desktop"}, nil
+	}
+	kind, dest, ok := strings.Cut(value, ":")
+	kind = strings.ToLower(kind)
+	dest = strings.TrimSpace(dest)
+	switch {
+	case !ok || dest == "":
+	case kind == "webhook" && (strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://")):
+		return notifyTarget{kind: kind, dest: dest}, nil
+	case kind == "email" && strings.Contains(dest, "@"):
+		if _, err := exec.LookPath("sendmail"); err != nil {
+			return notifyTarget{}, fmt.Errorf("email notifications need sendmail on PATH")
+		}
+		return notifyTarget{kind: kind, dest: dest}, nil
+	}
+	return notifyTarget{}, fmt.Errorf("expected webhook:URL, email:ADDRESS, or desktop, got %q", value)
+}
+
+// send reports the end of a run with its exit code and, when the run got
+// that far, its summary.
+func (t notifyTarget) send(code int, summary []string) error {
+	title := fmt.Sprintf("gphotos finished: %s (exit code %d)", exitCodeNames[code], code)
+	body := strings.Join(summary, "\n")
+	switch t.kind {
+	case "webhook":
+		// "text" is what Slack and Mattermost incoming webhooks display.
+		payload, err := json.Marshal(map[string]any{
+			"title":     title,
+			"exit_code": code,
+			"summary":   summary,
+			"text":      strings.TrimSpace(title + "\n" + body),
+		})
+		if err != nil {
+			return err
+		}
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Post(t.dest, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned %s", resp.Status)
+		}
+		return nil
+	case "email":
+		var msg strings.Builder
+		fmt.Fprintf(&msg, "To: %s\nSubject: %s\n\n%s\n", t.dest, title, body)
+		cmd := exec.Command("sendmail", "-t")
+		cmd.Stdin = strings.NewReader(msg.String())
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("sendmail: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	case "desktop":
+		return desktopNotify(title, body)
+	}
+	return fmt.Errorf("unknown notification target %q", t.kind)
+}
+
+func desktopNotify(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("notify-send", title, body)
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		cmd = exec.Command("osascript", "-e", script)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", cmd.Path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gphotos/core/console"
+	"gphotos/core/metadata"
+)
+
+// patternPath is where custom filename patterns are kept.
+func patternPath() string {
+	return statePath("date_patterns.json")
+}
+
+// runPatterns implements `gphotos patterns list|export|import`, which
+// shares custom filename patterns as pattern packs.
+func runPatterns(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: gphotos patterns list")
+		fmt.Fprintln(os.Stderr, "       gphotos patterns export [-name NAME] [-description TEXT] <pack.json>")
+		fmt.Fprintln(os.Stderr, "       gphotos patterns import [-dry-run] <pack.json>")
+	}
+	if len(args) == 0 {
+		usage()
+		return exitUsage
+	}
+
+	custom, err := metadata.LoadCustomPatterns(patternPath())
+	if err != nil {
+		console.Errorln("Pattern file error:", err)
+		return exitFailure
+	}
+
+	switch args[0] {
+	case "list":
+		if len(custom) == 0 {
+			fmt.Println("No custom patterns.")
+			return exitOK
+		}
+		for i, c := range custom {
+			name := c.Name
+			if name == "" {
+				name = "(unnamed)"
+			}
+			fmt.Printf("%d. %s  regex=%s layout=%s", i+1, name, c.Regex, c.Layout)
+			if c.Timezone != "" {
+				fmt.Printf(" timezone=%s", c.Timezone)
+			}
+			if c.Priority != 0 {
+				fmt.Printf(" priority=%d", c.Priority)
+			}
+			fmt.Println()
+		}
+		return exitOK
+
+	case "export":
+		fs := flag.NewFlagSet("patterns export", flag.ContinueOnError)
+		name := fs.String("name", "", "Pack name")
+		description := fs.String("description", "", "Pack description")
+		if err := fs.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+		if fs.NArg() != 1 {
+			usage()
+			return exitUsage
+		}
+		if len(custom) == 0 {
+			console.Errorln("No custom patterns to export")
+			return exitFailure
+		}
+		pack := metadata.PatternPack{Name: *name, Description: *description, Patterns: custom}
+		if pack.Name == "" {
+			pack.Name = filepath.Base(fs.Arg(0))
+		}
+		if err := metadata.SavePatternPack(fs.Arg(0), pack); err != nil {
+			console.Errorln("Pattern pack error:", err)
+			return exitFailure
+		}
+		fmt.Println(console.Success(fmt.Sprintf("Exported %d patterns to %s", len(custom), fs.Arg(0))))
+		return exitOK
+
+	case "import":
+		fs := flag.NewFlagSet("patterns import", flag.ContinueOnError)
+		dryRun := fs.Bool("dry-run", false, "Check the pack without installing it")
+		if err := fs.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+		if fs.NArg() != 1 {
+			usage()
+			return exitUsage
+		}
+		pack, err := metadata.LoadPatternPack(fs.Arg(0))
+		if err != nil {
+			console.Errorln("Pattern pack error:", err)
+			return exitFailure
+		}
+		var valid []metadata.CustomPattern
+		for _, c := range pack.Patterns {
+			if err := metadata.CheckPattern(c); err != nil {
+				console.Warnf("Skipping pattern %q: %v\n", c.Name, err)
+				continue
+			}
+			valid = append(valid, c)
+		}
+		merged, added, replaced := metadata.MergePatterns(custom, valid)
+		fmt.Printf("Pack %q: %d patterns added, %d replaced, %d skipped\n", pack.Name, added, replaced, len(pack.Patterns)-len(valid))
+		if *dryRun || len(valid) == 0 {
+			return exitOK
+		}
+		if err := metadata.SaveCustomPatterns(patternPath(), merged); err != nil {
+			console.Errorln("Pattern file error:", err)
+			return exitFailure
+		}
+		fmt.Println(console.Success("Patterns installed."))
+		return exitOK
+	}
+
+	usage()
+	return exitUsage
+}
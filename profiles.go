@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profiles bundle flag values for common goals. A profile only supplies
+// defaults: flags given on the command line keep their values.
+var profiles = map[string][]profileSetting{
+	// fast trusts cached hashes, writes metadata with more parallelism and
+	// skips everything that re-reads the output.
+	"fast": {
+		{"workers", "8"},
+		{"exif-workers", "4"},
+		{"exif-batch", "100"},
+		{"trust-cache", "true"},
+		{"checksums", "off"},
+		{"xattr-checksums", "false"},
+		{"verify", "off"},
+	},
+	// safe re-checks cached hashes, quarantines broken media, spot-checks
+	// copies and records them in a SHA256SUMS manifest.
+	"safe": {
+		{"trust-cache", "false"},
+		{"quarantine", "true"},
+		{"verify", "sample:5%"},
+		{"checksums", "root"},
+	},
+	// archival verifies every copy and adds per-folder manifests, checksum
+	// xattrs and every sidecar, so the output stands on its own without the
+	// Takeout.
+	"archival": {
+		{"trust-cache", "false"},
+		{"quarantine", "true"},
+		{"verify", "full"},
+		{"checksums", "per-folder"},
+		{"xattr-checksums", "true"},
+		{"copy-json", "true"},
+		{"embed-json", "true"},
+		{"description-txt", "true"},
+	},
+}
+
+// mediaServers arrange the output for the photo apps of media servers and
+// write the fields they index. They combine with -profile.
+var mediaServers = map[string][]profileSetting{
+	// plex reads EXIF dates and IPTC captions and keywords, dates videos by
+	// their file time, and cannot play the video inside motion photos.
+	"plex": {
+		{"layout", "{{year}}/{{month}}"},
+		{"file-dates", "taken"},
+		{"iptc", "true"},
+		{"motion-photos", "split"},
+	},
+	// jellyfin's photo libraries read EXIF dates only and fall back to the
+	// file time; embedded motion videos are not played.
+	"jellyfin": {
+		{"layout", "{{year}}/{{month}}"},
+		{"file-dates", "taken"},
+		{"motion-photos", "split"},
+	},
+	// synology indexes XMP (descriptions, people as tags, ratings), shows
+	// motion photos natively, and sorts files without EXIF by file time
+	// into the dated folders.
+	"synology": {
+		{"layout", "{{year}}/{{month}}"},
+		{"file-dates", "taken"},
+	},
+}
+
+type profileSetting struct {
+	flag  string
+	value string
+}
+
+func profileNames() []string {
+	return settingNames(profiles)
+}
+
+func mediaServerNames() []string {
+	return settingNames(mediaServers)
+}
+
+func settingNames(presets map[string][]profileSetting) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the flags of the named profile on fs, skipping flags
+// that were set explicitly, and returns the settings it applied.
+func applyProfile(fs *flag.FlagSet, name string) ([]string, error) {
+	settings, ok := profiles[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q (use %s)", name, strings.Join(profileNames(), ", "))
+	}
+	return applySettings(fs, settings)
+}
+
+// applyMediaServer is applyProfile for -media-server presets.
+func applyMediaServer(fs *flag.FlagSet, name string) ([]string, error) {
+	settings, ok := mediaServers[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unknown media server %q (use %s)", name, strings.Join(mediaServerNames(), ", "))
+	}
+	return applySettings(fs, settings)
+}
+
+func applySettings(fs *flag.FlagSet, settings []profileSetting) ([]string, error) {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var applied []string
+	for _, s := range settings {
+		if explicit[s.flag] {
+			continue
+		}
+		if err := fs.Set(s.flag, s.value); err != nil {
+			return nil, err
+		}
+		applied = append(applied, fmt.Sprintf("-%s=%s", s.flag, s.value))
+	}
+	return applied, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gphotos/core/console"
+	"gphotos/core/fsutil"
+	"gphotos/core/output"
+)
+
+// runQuery implements `gphotos query`, which lists the catalogued files of
+// an output folder matching person, year, album, and location filters.
+func runQuery(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	person := fs.String("person", "", "Only files with this person tagged")
+	year := fs.String("year", "", "Only files taken in this year")
+	album := fs.String("album", "", "Only files in a matching album (patterns like Vacation* allowed)")
+	withGPS := fs.Bool("gps", false, "Only files with a location")
+	noGPS := fs.Bool("no-gps", false, "Only files without a location")
+	abs := fs.Bool("abs", false, "Print absolute paths instead of paths relative to the output folder")
+	listFile := fs.String("o", "", "Write the matching paths to this file (one per line, e.g. for rclone --files-from) instead of printing them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gphotos query [-person NAME] [-year YYYY] [-album NAME] [-gps|-no-gps] [-abs] [-o FILE] <output>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *withGPS && *noGPS {
+		console.Errorln("-gps and -no-gps cannot be combined")
+		return exitUsage
+	}
+	if *year != "" {
+		if _, err := strconv.Atoi(*year); err != nil || len(*year) != 4 {
+			console.Errorln("Invalid -year:", *year)
+			return exitUsage
+		}
+	}
+	outRoot := fs.Arg(0)
+
+	entries, err := output.LoadCatalog(outRoot)
+	if err != nil {
+		console.Errorln("Catalog error:", err)
+		return exitFailure
+	}
+	if len(entries) == 0 {
+		console.Errorln("No catalog in", outRoot+"; run gphotos into this folder first")
+		return exitFailure
+	}
+
+	query := output.CatalogQuery{Person: strings.TrimSpace(*person), Album: strings.TrimSpace(*album), Year: *year}
+	switch {
+	case *withGPS:
+		query.GPS = "with"
+	case *noGPS:
+		query.GPS = "without"
+	}
+	var paths []string
+	for _, e := range entries {
+		if !query.Match(e) {
+			continue
+		}
+		path := filepath.FromSlash(e.Path)
+		if *abs {
+			path = filepath.Join(outRoot, path)
+			if a, err := filepath.Abs(path); err == nil {
+				path = a
+			}
+		}
+		paths = append(paths, path)
+	}
+
+	if *listFile != "" {
+		var data []byte
+		for _, path := range paths {
+			data = append(data, path+"\n"...)
+		}
+		if err := fsutil.WriteFile(*listFile, data); err != nil {
+			console.Errorln("File list error:", err)
+			return exitFailure
+		}
+		fmt.Printf("Matching files written to %s: %d\n", *listFile, len(paths))
+		return exitOK
+	}
+	for _, path := range paths {
+		fmt.Println(path)
+	}
+	fmt.Fprintf(os.Stderr, "Matching files: %d of %d\n", len(paths), len(entries))
+	return exitOK
+}
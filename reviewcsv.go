@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gphotos/core/console"
+	"gphotos/core/metadata"
+)
+
+var reviewCSVHeader = []string{"category", "path", "json", "filename", "exif", "proposed", "decision", "date"}
+
+// reviewDateLayouts are the date formats accepted in the date column of a
+// filled-in review CSV. Dates without a zone are read as local time.
+var reviewDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// exportReviewCSV writes the override, EXIF-only, interpolated and unknown
+// proposals to path with empty decision and date columns and returns the
+// number of rows written.
+func exportReviewCSV(path string, proposals []dateProposal) (int, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return 0, err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(reviewCSVHeader); err != nil {
+		return 0, err
+	}
+	written := 0
+	for _, cat := range buildReviewCategories(proposals) {
+		if cat.key == "filename" {
+			continue
+		}
+		for _, p := range cat.items {
+			row := []string{
+				cat.key,
+				p.photo.SrcPath,
+				formatReviewTime(p.hasJSON, p.jsonTime),
+				formatReviewTime(p.hasFile, p.fileTime),
+				formatReviewTime(p.hasExif, p.exifTime),
+				formatReviewTime(p.accuracy != metadata.DateAccuracyNone, p.proposed),
+				"",
+				"",
+			}
+			if err := w.Write(row); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return written, err
+	}
+	return written, f.Close()
+}
+
+func formatReviewTime(ok bool, t time.Time) string {
+	if !ok {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// applyReviewCSV reads decisions from a CSV written by exportReviewCSV and
+// applies them to the matching proposals. An empty decision or "accept"
+// keeps the proposed date, "reject" drops it (overrides fall back to the
+// JSON date) and a value in the date column replaces it. Rows for files
+// that are not part of this run are reported and skipped. It returns the
+// number of rows that changed a proposal.
+func applyReviewCSV(path string, proposals []dateProposal) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	byPath := make(map[string]int, len(proposals))
+	for i, p := range proposals {
+		byPath[p.photo.SrcPath] = i
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"path", "decision", "date"} {
+		if _, ok := cols[name]; !ok {
+			return 0, fmt.Errorf("%s: missing %q column", path, name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i := cols[name]; i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	applied, missing := 0, 0
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return applied, fmt.Errorf("%s: %w", path, err)
+		}
+		// Quoted fields may span lines, so ask the reader where the row
+		// starts.
+		line, _ := r.FieldPos(0)
+		i, ok := byPath[field(row, "path")]
+		if !ok {
+			missing++
+			continue
+		}
+		p := &proposals[i]
+		if value := field(row, "date"); value != "" {
+			t, err := parseReviewDate(value)
+			if err != nil {
+				return applied, fmt.Errorf("%s:%d: invalid date %q", path, line, value)
+			}
+			p.proposed = t
+			p.accuracy = metadata.DateAccuracyManual
+			applied++
+			continue
+		}
+		switch decision := strings.ToLower(field(row, "decision")); decision {
+		case "", "accept", "a", "y", "yes":
+		case "reject", "r", "n", "no":
+			if p.hasJSON && p.hasFile && p.accuracy == metadata.DateAccuracyFilename {
+				rejectOverride(p)
+			} else {
+				p.proposed = time.Time{}
+				p.accuracy = metadata.DateAccuracyNone
+			}
+			applied++
+		default:
+			return applied, fmt.Errorf("%s:%d: unknown decision %q (use accept or reject)", path, line, decision)
+		}
+	}
+	if missing > 0 {
+		console.Warnf("Review rows skipped for files not in this run: %d\n", missing)
+	}
+	return applied, nil
+}
+
+func parseReviewDate(value string) (time.Time, error) {
+	var err error
+	for _, layout := range reviewDateLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
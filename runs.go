@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gphotos/core/console"
+	"gphotos/core/output"
+)
+
+// runRuns implements `gphotos runs list|diff`, which shows the runs recorded
+// in an output folder and compares two of them.
+func runRuns(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: gphotos runs list <output>")
+		fmt.Fprintln(os.Stderr, "       gphotos runs diff [-v] <output> [old-id new-id]")
+	}
+	if len(args) == 0 {
+		usage()
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 2 {
+			usage()
+			return exitUsage
+		}
+		runs, err := output.ListRuns(args[1])
+		if err != nil {
+			console.Errorln("Run history error:", err)
+			return exitFailure
+		}
+		if len(runs) == 0 {
+			fmt.Println("No recorded runs.")
+			return exitOK
+		}
+		for _, r := range runs {
+			fmt.Printf("%s  files=%d duplicates merged=%d\n", r.ID, r.Files, r.Duplicates)
+		}
+		return exitOK
+
+	case "diff":
+		fs := flag.NewFlagSet("runs diff", flag.ContinueOnError)
+		verbose := fs.Bool("v", false, "List every file instead of only the counts")
+		if err := fs.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+		if fs.NArg() != 1 && fs.NArg() != 3 {
+			usage()
+			return exitUsage
+		}
+		outRoot := fs.Arg(0)
+		oldID, newID := fs.Arg(1), fs.Arg(2)
+		if oldID == "" {
+			runs, err := output.ListRuns(outRoot)
+			if err != nil {
+				console.Errorln("Run history error:", err)
+				return exitFailure
+			}
+			if len(runs) < 2 {
+				console.Errorln("Need at least two recorded runs to compare")
+				return exitFailure
+			}
+			oldID, newID = runs[len(runs)-2].ID, runs[len(runs)-1].ID
+		}
+		older, err := output.LoadRunManifest(outRoot, oldID)
+		if err != nil {
+			console.Errorln("Run history error:", err)
+			return exitFailure
+		}
+		newer, err := output.LoadRunManifest(outRoot, newID)
+		if err != nil {
+			console.Errorln("Run history error:", err)
+			return exitFailure
+		}
+		printRunDiff(oldID, newID, output.DiffRuns(older, newer), *verbose)
+		return exitOK
+	}
+
+	usage()
+	return exitUsage
+}
+
+func printRunDiff(oldID, newID string, diff output.RunDiff, verbose bool) {
+	fmt.Printf("Comparing %s -> %s\n", oldID, newID)
+	fmt.Printf("New files: %d\n", len(diff.Added))
+	if verbose {
+		for _, e := range diff.Added {
+			fmt.Printf("  + %s (from %s)\n", e.Path, e.Source)
+		}
+	}
+	fmt.Printf("Files no longer present: %d\n", len(diff.Removed))
+	if verbose {
+		for _, e := range diff.Removed {
+			fmt.Printf("  - %s (from %s)\n", e.Path, e.Source)
+		}
+	}
+	fmt.Printf("Changed dates: %d\n", len(diff.Dates))
+	if verbose {
+		for _, c := range diff.Dates {
+			fmt.Printf("  %s: %s -> %s\n", c.New.Path, orNone(c.Old.Taken), orNone(c.New.Taken))
+		}
+	}
+	fmt.Printf("Changed duplicate counts: %d\n", len(diff.Duplicates))
+	if verbose {
+		for _, c := range diff.Duplicates {
+			fmt.Printf("  %s: %d -> %d merged copies\n", c.New.Path, c.Old.Duplicates, c.New.Duplicates)
+		}
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gphotos/core/console"
+	"gphotos/core/fsutil"
+	"gphotos/core/output"
+)
+
+// runPause implements `gphotos pause`, which holds a running copy into an
+// output folder before its next file until `gphotos resume` is run.
+func runPause(args []string) int {
+	outRoot, code := scheduleTarget("pause", args)
+	if code != exitOK {
+		return code
+	}
+	path := output.PausePath(outRoot)
+	if err := fsutil.MkdirAll(filepath.Dir(path)); err != nil {
+		console.Errorln("Pause error:", err)
+		return exitFailure
+	}
+	if err := fsutil.WriteFile(path, []byte(time.Now().Format(time.RFC3339)+"\n")); err != nil {
+		console.Errorln("Pause error:", err)
+		return exitFailure
+	}
+	fmt.Println("Runs copying into", outRoot, "pause before their next file.")
+	return exitOK
+}
+
+// runResume implements `gphotos resume`, which lets a paused copy continue.
+func runResume(args []string) int {
+	outRoot, code := scheduleTarget("resume", args)
+	if code != exitOK {
+		return code
+	}
+	err := os.Remove(output.PausePath(outRoot))
+	if os.IsNotExist(err) {
+		fmt.Println("Not paused:", outRoot)
+		return exitOK
+	}
+	if err != nil {
+		console.Errorln("Resume error:", err)
+		return exitFailure
+	}
+	fmt.Println("Resumed copying into", outRoot+".")
+	return exitOK
+}
+
+func scheduleTarget(name string, args []string) (string, int) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gphotos %s <output>\n", name)
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", exitUsage
+	}
+	return fs.Arg(0), exitOK
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gphotos/core/console"
+	"gphotos/core/dedup"
+	"gphotos/core/metadata"
+	"gphotos/core/scanner"
+)
+
+// runShiftDates implements `gphotos shift-dates`, which moves the saved date
+// decisions of a subset of a Takeout by a fixed offset. The next run reuses
+// the shifted dates.
+func runShiftDates(args []string) int {
+	fs := flag.NewFlagSet("shift-dates", flag.ContinueOnError)
+	offsetFlag := fs.String("offset", "", "Offset to add to each date (e.g. +7h, -30m, 1d)")
+	album := fs.String("album", "", "Only shift files in this album")
+	folder := fs.String("folder", "", "Only shift files below this folder, relative to the Takeout root")
+	from := fs.String("from", "", "Only shift dates on or after this day (YYYY-MM-DD)")
+	to := fs.String("to", "", "Only shift dates on or before this day (YYYY-MM-DD)")
+	dryRun := fs.Bool("dry-run", false, "Print the changes without saving them")
+	stateDir := fs.String("state-dir", "", "State folder holding the hash cache (default $XDG_DATA_HOME/gphotos)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gphotos shift-dates -offset <duration> [filters] <takeout-root>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || *offsetFlag == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	offset, err := parseDurationDays(strings.TrimPrefix(*offsetFlag, "+"))
+	if err != nil || offset == 0 {
+		console.Errorln("Invalid -offset:", *offsetFlag)
+		return exitUsage
+	}
+	var fromDay, toDay time.Time
+	if *from != "" {
+		if fromDay, err = time.ParseInLocation("2006-01-02", *from, time.Local); err != nil {
+			console.Errorln("Invalid -from date:", *from)
+			return exitUsage
+		}
+	}
+	if *to != "" {
+		if toDay, err = time.ParseInLocation("2006-01-02", *to, time.Local); err != nil {
+			console.Errorln("Invalid -to date:", *to)
+			return exitUsage
+		}
+		toDay = toDay.AddDate(0, 0, 1)
+	}
+
+	inRoot := fs.Arg(0)
+	takeoutState, err := resolveTakeoutState(*stateDir, inRoot)
+	if err != nil {
+		console.Errorln("State dir error:", err)
+		return exitUsage
+	}
+	cachePath := filepath.Join(takeoutState, "hash_cache.json")
+	migrateHashCache(inRoot, cachePath)
+	pairs, unreadable, err := scanner.ScanTakeout(inRoot, false)
+	if err != nil {
+		console.Errorln("Scan error:", err)
+		return exitScanError
+	}
+	printUnreadable(unreadable)
+	subset := pairs[:0]
+	prefix := filepath.Clean(filepath.Join(inRoot, *folder)) + string(filepath.Separator)
+	for _, pair := range pairs {
+		if *album != "" && pair.Album != *album {
+			continue
+		}
+		if *folder != "" && !strings.HasPrefix(pair.MediaPath, prefix) {
+			continue
+		}
+		subset = append(subset, pair)
+	}
+	if len(subset) == 0 {
+		fmt.Println("No media files matched the filters.")
+		return exitScanError
+	}
+
+	hashBar := newProgressBar("Hashing")
+	registry := dedup.BuildRegistry(subset, cachePath, false, false, hashBar.Update)
+	hashBar.Finish()
+
+	decisionPath := statePath("date_decisions.json")
+	decisions, err := metadata.LoadDateDecisions(decisionPath)
+	if err != nil {
+		console.Errorln("Date decisions error:", err)
+		return exitFailure
+	}
+	shifted, undecided := 0, 0
+	for hash, p := range registry {
+		d, ok := decisions[hash]
+		if !ok || d.TakenTime == "" {
+			undecided++
+			continue
+		}
+		taken, err := time.Parse(time.RFC3339, d.TakenTime)
+		if err != nil {
+			undecided++
+			continue
+		}
+		if (!fromDay.IsZero() && taken.Before(fromDay)) || (!toDay.IsZero() && !taken.Before(toDay)) {
+			continue
+		}
+		d.TakenTime = taken.Add(offset).Format(time.RFC3339)
+		if *dryRun {
+			fmt.Printf("DRY RUN: %s %s -> %s\n", p.SrcPath, taken.Format(time.RFC3339), d.TakenTime)
+		}
+		decisions[hash] = d
+		shifted++
+	}
+
+	fmt.Printf("Dates shifted: %d\n", shifted)
+	if undecided > 0 {
+		console.Warnf("Skipped %d files without a saved date decision; run gphotos on them first\n", undecided)
+	}
+	if *dryRun || shifted == 0 {
+		return exitOK
+	}
+	if err := metadata.SaveDateDecisions(decisionPath, decisions); err != nil {
+		console.Errorln("Date decisions error:", err)
+		return exitFailure
+	}
+	fmt.Println(console.Success("Shifted dates are applied on the next run."))
+	return exitOK
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gphotos/core/fsutil"
+)
+
+// stateFolder holds the patterns, exclusions, date decisions and other
+// state kept between runs: .gphotos in the working directory, or
+// <output>/.gphotos with -portable.
+var stateFolder = ".gphotos"
+
+func statePath(name string) string {
+	return filepath.Join(stateFolder, name)
+}
+
+// portableFiles are the state files a first -portable run copies from the
+// working directory's .gphotos, so earlier decisions come along.
+var portableFiles = []string{
+	"date_patterns.json",
+	"date_exclusions.json",
+	"date_decisions.json",
+	"date_cache.json",
+	"json_overrides.json",
+	"albums.json",
+}
+
+// usePortableState moves all state into <outRoot>/.gphotos: stateFolder,
+// the hash cache and the Takeout lock. State files that only exist in the
+// working directory's .gphotos, and a hash cache from the Takeout's state
+// folder, are copied over first. It returns the new Takeout state folder.
+func usePortableState(outRoot, takeoutState string) string {
+	dir := filepath.Join(outRoot, ".gphotos")
+	for _, name := range portableFiles {
+		copyMissing(filepath.Join(stateFolder, name), filepath.Join(dir, name))
+	}
+	copyMissing(filepath.Join(takeoutState, "hash_cache.json"), filepath.Join(dir, "hash_cache.json"))
+	stateFolder = dir
+	return dir
+}
+
+// copyMissing copies src to dst unless dst exists or src cannot be read.
+func copyMissing(src, dst string) {
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		return
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return
+	}
+	if err := fsutil.MkdirAll(filepath.Dir(dst)); err != nil {
+		return
+	}
+	_ = fsutil.WriteFile(dst, data)
+}
+
+// defaultStateDir is where gphotos keeps per-Takeout state such as the hash
+// cache when -state-dir is not given: $XDG_DATA_HOME/gphotos, falling back
+// to ~/.local/share/gphotos, or the platform's local application data
+// folder on Windows and macOS.
+func defaultStateDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "gphotos"), nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, "gphotos"), nil
+		}
+		return "", errors.New("%LOCALAPPDATA% is not set")
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Application Support", "gphotos"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "gphotos"), nil
+}
+
+// takeoutStateDir is the folder under stateDir that holds the state of one
+// Takeout root, named after the root and a short hash of its absolute path
+// so different Takeouts with the same folder name do not collide.
+func takeoutStateDir(stateDir, inRoot string) (string, error) {
+	abs, err := filepath.Abs(inRoot)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(stateDir, "takeouts", filepath.Base(abs)+"-"+hex.EncodeToString(sum[:])[:12]), nil
+}
+
+// resolveTakeoutState returns the state folder for inRoot, using the
+// default state dir when stateDir is empty.
+func resolveTakeoutState(stateDir, inRoot string) (string, error) {
+	if stateDir == "" {
+		var err error
+		if stateDir, err = defaultStateDir(); err != nil {
+			return "", err
+		}
+	}
+	return takeoutStateDir(stateDir, inRoot)
+}
+
+// migrateHashCache copies the hash cache that older versions kept in
+// <input>/.gphotos to cachePath, so upgrading does not mean re-hashing.
+// The input copy is left untouched.
+func migrateHashCache(inRoot, cachePath string) {
+	copyMissing(filepath.Join(inRoot, ".gphotos", "hash_cache.json"), cachePath)
+}
+
+// checkReadOnlySource refuses -read-only-source runs whose output or state
+// would land inside the Takeout, including stateFolder.
+func checkReadOnlySource(inRoot, outRoot, takeoutState, cachePath, argFile string) error {
+	for _, c := range []struct{ what, path string }{
+		{"the output folder", outRoot},
+		{"the state folder", takeoutState},
+		{"the hash cache", cachePath},
+		{"the gphotos state folder", stateFolder},
+		{"the exiftool argfile", argFile},
+	} {
+		if c.path != "" && fsutil.IsWithin(inRoot, c.path) {
+			return fmt.Errorf("%s (%s) is inside the Takeout folder", c.what, c.path)
+		}
+	}
+	return nil
+}
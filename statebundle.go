@@ -0,0 +1,274 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gphotos/core/console"
+	"gphotos/core/dedup"
+	"gphotos/core/fsutil"
+)
+
+// bundleManifest is the first entry of a state archive.
+const bundleManifest = "manifest.json"
+
+// bundleVersion 2 stores the hash cache with paths relative to the Takeout
+// root; version 1 archives hold it as it was on disk.
+const bundleVersion = 2
+
+// bundleHashCache is the archive entry of the Takeout's hash cache.
+const bundleHashCache = "takeout/hash_cache.json"
+
+type stateManifest struct {
+	Version     int       `json:"version"`
+	Created     time.Time `json:"created"`
+	TakeoutRoot string    `json:"takeout_root"` // absolute, as on the exporting machine
+	Files       []string  `json:"files"`
+}
+
+// bundleFile is one piece of state and where it lives for a Takeout root.
+type bundleFile struct {
+	name string // path inside the archive
+	path string // path on disk
+}
+
+// stateBundleFiles lists everything `state export` bundles: the working
+// state folder (patterns, exclusions, decisions, album selection, ...), the
+// Takeout's hash cache, and the rename journal of a -reorganize-in-place
+// run.
+func stateBundleFiles(inRoot, takeoutState string) []bundleFile {
+	var files []bundleFile
+	for _, name := range portableFiles {
+		files = append(files, bundleFile{name: "state/" + name, path: statePath(name)})
+	}
+	return append(files,
+		bundleFile{name: bundleHashCache, path: filepath.Join(takeoutState, "hash_cache.json")},
+		bundleFile{name: "input/rename_journal.jsonl", path: journalPath(inRoot)},
+	)
+}
+
+// runState implements `gphotos state export|import`, which moves the state
+// of a half-finished migration to another machine in one archive.
+func runState(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: gphotos state export [-state-dir DIR] <takeout-root> <archive.tar.gz>")
+		fmt.Fprintln(os.Stderr, "       gphotos state import [-state-dir DIR] [-force] <takeout-root> <archive.tar.gz>")
+	}
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		usage()
+		return exitUsage
+	}
+	fs := flag.NewFlagSet("state "+args[0], flag.ContinueOnError)
+	stateDir := fs.String("state-dir", "", "State folder holding the hash cache (default $XDG_DATA_HOME/gphotos)")
+	force := fs.Bool("force", false, "With import, overwrite state that already exists")
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 2 {
+		usage()
+		return exitUsage
+	}
+	inRoot, archive := fs.Arg(0), fs.Arg(1)
+
+	takeoutState, err := resolveTakeoutState(*stateDir, inRoot)
+	if err != nil {
+		console.Errorln("State dir error:", err)
+		return exitUsage
+	}
+	lock, err := lockTakeout(takeoutState)
+	if err != nil {
+		console.Errorln("Lock error:", err)
+		return exitFailure
+	}
+	defer lock.Release()
+
+	files := stateBundleFiles(inRoot, takeoutState)
+	if args[0] == "export" {
+		n, err := exportState(archive, inRoot, files)
+		if err != nil {
+			console.Errorln("State export error:", err)
+			return exitFailure
+		}
+		fmt.Printf("State files exported to %s: %d\n", archive, n)
+		return exitOK
+	}
+	n, err := importState(archive, inRoot, files, takeoutState, *force)
+	if err != nil {
+		console.Errorln("State import error:", err)
+		return exitFailure
+	}
+	fmt.Printf("State files imported: %d\n", n)
+	return exitOK
+}
+
+// exportState writes every existing file of files into a gzipped tar at
+// archive and returns how many were included.
+func exportState(archive, inRoot string, files []bundleFile) (int, error) {
+	abs, err := filepath.Abs(inRoot)
+	if err != nil {
+		return 0, err
+	}
+	manifest := stateManifest{Version: bundleVersion, Created: time.Now(), TakeoutRoot: abs}
+	contents := make(map[string][]byte)
+	for _, f := range files {
+		data, err := os.ReadFile(f.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil && f.name == bundleHashCache {
+			data, err = dedup.PortableHashCache(f.path, abs)
+		}
+		if err != nil {
+			return 0, err
+		}
+		manifest.Files = append(manifest.Files, f.name)
+		contents[f.name] = data
+	}
+	if len(manifest.Files) == 0 {
+		return 0, fmt.Errorf("no state found for %s", inRoot)
+	}
+
+	out, err := fsutil.Create(archive)
+	if err != nil {
+		return 0, err
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = write(bundleManifest, data)
+	}
+	for _, name := range manifest.Files {
+		if err != nil {
+			break
+		}
+		err = write(name, contents[name])
+	}
+	for _, c := range []io.Closer{tw, gz, out} {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(manifest.Files), nil
+}
+
+// importState restores an archive written by exportState for inRoot. Nothing
+// is written when any target already exists, unless force is set. Hash
+// cache entries recorded under the exporting machine's Takeout path are
+// moved to inRoot's, so the cache keeps matching after the move.
+func importState(archive, inRoot string, files []bundleFile, takeoutState string, force bool) (int, error) {
+	in, err := os.Open(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a gphotos state archive: %v", archive, err)
+	}
+	defer gz.Close()
+
+	known := make(map[string]string, len(files))
+	for _, f := range files {
+		known[f.name] = f.path
+	}
+	var manifest stateManifest
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case hdr.Name == bundleManifest:
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return 0, fmt.Errorf("bad manifest: %v", err)
+			}
+		case known[hdr.Name] != "":
+			contents[hdr.Name] = data
+		default:
+			console.Warnf("Ignoring unknown archive entry %s\n", hdr.Name)
+		}
+	}
+	if manifest.Version < 1 || manifest.Version > bundleVersion {
+		return 0, fmt.Errorf("%s is not a gphotos state archive", archive)
+	}
+
+	var conflicts []string
+	for name := range contents {
+		if _, err := os.Stat(known[name]); err == nil {
+			conflicts = append(conflicts, known[name])
+		}
+	}
+	if len(conflicts) > 0 && !force {
+		return 0, fmt.Errorf("state already exists (use -force to overwrite): %s", strings.Join(conflicts, ", "))
+	}
+
+	abs, err := filepath.Abs(inRoot)
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		data, ok := contents[f.name]
+		if !ok {
+			continue
+		}
+		if err := fsutil.MkdirAll(filepath.Dir(f.path)); err != nil {
+			return 0, err
+		}
+		if f.name == bundleHashCache && manifest.Version >= 2 {
+			placed, err := dedup.RestorePortableHashCache(f.path, data, abs)
+			if err != nil {
+				return 0, err
+			}
+			fmt.Printf("Hash cache entries placed under %s: %d\n", abs, placed)
+			continue
+		}
+		if err := fsutil.WriteFile(f.path, data); err != nil {
+			return 0, err
+		}
+	}
+
+	// Version 1 archives hold the cache keyed by the exporting machine's
+	// absolute paths, which only rebase onto a path of the same OS.
+	if _, ok := contents[bundleHashCache]; ok && manifest.Version == 1 && manifest.TakeoutRoot != "" && abs != manifest.TakeoutRoot {
+		moved, err := dedup.RebaseHashCache(known[bundleHashCache], manifest.TakeoutRoot, abs)
+		if err != nil {
+			return 0, err
+		}
+		if moved == 0 {
+			console.Warnf("No hash cache entries matched %s; files will be hashed again. Export the state with this version to move it across systems.\n", manifest.TakeoutRoot)
+		} else {
+			fmt.Printf("Hash cache entries moved from %s to %s: %d\n", manifest.TakeoutRoot, abs, moved)
+		}
+	}
+	return len(contents), nil
+}
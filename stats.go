@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gphotos/core/console"
+	"gphotos/core/metadata"
+	"gphotos/core/output"
+)
+
+// statsBarWidth is the length of the longest bar in `gphotos stats`.
+const statsBarWidth = 40
+
+type yearStats struct {
+	year           string
+	photos, videos int
+	photoBytes     int64
+	videoBytes     int64
+}
+
+// runStats implements `gphotos stats`, which charts the catalogued files of
+// an output folder per year.
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gphotos stats <output>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	outRoot := fs.Arg(0)
+
+	entries, err := output.LoadCatalog(outRoot)
+	if err != nil {
+		console.Errorln("Catalog error:", err)
+		return exitFailure
+	}
+	if len(entries) == 0 {
+		console.Errorln("No catalog in", outRoot+"; run gphotos into this folder first")
+		return exitFailure
+	}
+
+	byYear := make(map[string]*yearStats)
+	var total yearStats
+	missing := 0
+	for _, e := range entries {
+		info, err := os.Stat(filepath.Join(outRoot, filepath.FromSlash(e.Path)))
+		if err != nil {
+			missing++
+			continue
+		}
+		year := "unknown"
+		if t, err := time.Parse(time.RFC3339, e.Taken); err == nil {
+			year = t.Format("2006")
+		}
+		ys := byYear[year]
+		if ys == nil {
+			ys = &yearStats{year: year}
+			byYear[year] = ys
+		}
+		for _, s := range []*yearStats{ys, &total} {
+			if metadata.IsVideoPath(e.Path) {
+				s.videos++
+				s.videoBytes += info.Size()
+			} else {
+				s.photos++
+				s.photoBytes += info.Size()
+			}
+		}
+	}
+
+	years := make([]*yearStats, 0, len(byYear))
+	for _, ys := range byYear {
+		years = append(years, ys)
+	}
+	// "unknown" sorts after the digits, so it ends up last.
+	sort.Slice(years, func(i, j int) bool { return years[i].year < years[j].year })
+
+	var maxFiles int
+	var maxBytes int64
+	for _, ys := range years {
+		maxFiles = max(maxFiles, ys.photos+ys.videos)
+		maxBytes = max(maxBytes, ys.photoBytes+ys.videoBytes)
+	}
+
+	fmt.Println(console.Heading("Files per year") + "  (# photos, = videos)")
+	for _, ys := range years {
+		photos, videos := statsBars(int64(ys.photos), int64(ys.videos), int64(maxFiles))
+		fmt.Printf("  %-7s %s%s %d photos, %d videos\n", ys.year, photos, videos, ys.photos, ys.videos)
+	}
+	fmt.Println()
+	fmt.Println(console.Heading("Size per year") + "  (# photos, = videos)")
+	for _, ys := range years {
+		photos, videos := statsBars(ys.photoBytes, ys.videoBytes, maxBytes)
+		fmt.Printf("  %-7s %s%s %s\n", ys.year, photos, videos, formatBytes(ys.photoBytes+ys.videoBytes))
+	}
+	fmt.Println()
+	fmt.Printf("Total: %d photos (%s), %d videos (%s)\n",
+		total.photos, formatBytes(total.photoBytes), total.videos, formatBytes(total.videoBytes))
+	if missing > 0 {
+		console.Warnf("%d catalogued files are no longer in the output folder\n", missing)
+	}
+	return exitOK
+}
+
+// statsBars returns the photo and video parts of a bar scaled against
+// limit, padded to statsBarWidth. A non-zero value always gets at least one
+// mark.
+func statsBars(photos, videos, limit int64) (string, string) {
+	scale := func(n int64) int {
+		if n <= 0 || limit <= 0 {
+			return 0
+		}
+		w := int(n * statsBarWidth / limit)
+		if w == 0 {
+			w = 1
+		}
+		return w
+	}
+	p, v := scale(photos), scale(videos)
+	if p+v > statsBarWidth {
+		p = statsBarWidth - v
+	}
+	return strings.Repeat("#", p), strings.Repeat("=", v) + strings.Repeat(" ", max(0, statsBarWidth-p-v))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gphotos/core/fsutil"
+	"gphotos/core/models"
+	"gphotos/core/output"
+)
+
+// summaryFile is where the end-of-run summary is logged, under the output
+// folder's .gphotos directory.
+const summaryFile = "run_summary.txt"
+
+// runSummary collects the numbers reported at the end of a copy run.
+type runSummary struct {
+	started time.Time
+	dryRun  bool
+	// dedup is false with -no-dedup, when nothing was hashed and the byte
+	// counts below are unknown.
+	dedup        bool
+	scannedBytes int64
+	uniqueBytes  int64
+	skipped      []skipCount
+	quarantined  int
+	stats        output.Stats
+	// rendered holds the lines printed by report.
+	rendered []string
+}
+
+type skipCount struct {
+	reason string
+	count  int
+}
+
+// skip records n files that were left out of the output for reason.
+func (s *runSummary) skip(reason string, n int) {
+	if n > 0 {
+		s.skipped = append(s.skipped, skipCount{reason: reason, count: n})
+	}
+}
+
+// lines renders the summary, including the stage timings of pl.
+func (s *runSummary) lines(pl *pipeline) []string {
+	var out []string
+	add := func(format string, a ...any) {
+		out = append(out, fmt.Sprintf(format, a...))
+	}
+	copied := "Files copied"
+	if s.dryRun {
+		copied = "Files to copy"
+	}
+	add("%s: %d (%s)", copied, s.stats.Copied, formatBytes(s.stats.BytesCopied))
+	if s.stats.AlreadyPresent > 0 {
+		add("  already in place, copy skipped: %d", s.stats.AlreadyPresent)
+	}
+	if s.dedup {
+		saved := s.scannedBytes - s.uniqueBytes
+		if saved < 0 {
+			saved = 0
+		}
+		add("Duplicate space saved: %s of %s scanned", formatBytes(saved), formatBytes(s.scannedBytes))
+	}
+	if s.stats.MetadataExported > 0 {
+		add("Metadata writes exported to argfile: %d", s.stats.MetadataExported)
+	} else if !s.dryRun {
+		failed := s.stats.MetadataFailures
+		add("Metadata writes: %d attempted, %d succeeded, %d failed", s.stats.MetadataAttempted, s.stats.MetadataAttempted-failed, failed)
+	}
+	add("Name collisions resolved: %d", len(s.stats.Collisions))
+	skipped := s.quarantined
+	for _, sk := range s.skipped {
+		skipped += sk.count
+	}
+	add("Files skipped: %d", skipped)
+	if s.quarantined > 0 {
+		add("  quarantined: %d", s.quarantined)
+	}
+	for _, sk := range s.skipped {
+		add("  %s: %d", sk.reason, sk.count)
+	}
+	if pl != nil && len(pl.timings) > 0 {
+		add("Stage times:")
+		for _, t := range pl.timings {
+			add("  %s: %s", t.label, t.took.Round(time.Millisecond))
+		}
+	}
+	add("Total time: %s", time.Since(s.started).Round(time.Second))
+	return out
+}
+
+// report prints the summary and, unless this is a dry run, logs it to
+// <outRoot>/.gphotos/run_summary.txt.
+func (s *runSummary) report(outRoot string, pl *pipeline) error {
+	pl.finishStage()
+	lines := s.lines(pl)
+	s.rendered = lines
+	fmt.Println("Run summary:")
+	for _, line := range lines {
+		fmt.Println("  " + line)
+	}
+	if s.dryRun {
+		return nil
+	}
+	path := filepath.Join(outRoot, ".gphotos", summaryFile)
+	if err := fsutil.MkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	header := "Run finished " + time.Now().Format(time.RFC3339) + "\n"
+	return fsutil.WriteFile(path, []byte(header+strings.Join(lines, "\n")+"\n"))
+}
+
+// totalSize sums the sizes recorded while hashing.
+func totalSize(photos []*models.Photo) int64 {
+	var n int64
+	for _, p := range photos {
+		n += p.Size
+	}
+	return n
+}
+
+// scannedSize sums the sizes of every source copy of photos, duplicates
+// included.
+func scannedSize(photos []*models.Photo) int64 {
+	var n int64
+	for _, p := range photos {
+		copies := len(p.Copies)
+		if copies < 1 {
+			copies = 1
+		}
+		n += p.Size * int64(copies)
+	}
+	return n
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gphotos/core/console"
+	"gphotos/core/dedup"
+	"gphotos/core/metadata"
+	"gphotos/core/models"
+	"gphotos/core/scanner"
+)
+
+// diffedFile is one logical file of a Takeout: its path below the
+// "Google Photos" folder, which stays the same between exports.
+type diffedFile struct {
+	name  string
+	photo *models.Photo
+}
+
+// metaChange is a file whose content is the same in both exports but
+// whose JSON says something different.
+type metaChange struct {
+	name   string
+	fields []string
+}
+
+// runDiff implements `gphotos diff`, which compares two Takeout exports by
+// content hash to check that a newer export supersedes an older one.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	verbose := fs.Bool("v", false, "List every file instead of only the counts")
+	stateDir := fs.String("state-dir", "", "State folder holding the hash caches (default $XDG_DATA_HOME/gphotos)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gphotos diff [-v] [-state-dir DIR] <old-takeout> <new-takeout>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	oldRoot, newRoot := fs.Arg(0), fs.Arg(1)
+
+	older, code := indexTakeout(oldRoot, *stateDir)
+	if code != exitOK {
+		return code
+	}
+	newer, code := indexTakeout(newRoot, *stateDir)
+	if code != exitOK {
+		return code
+	}
+
+	oldByName := make(map[string]string) // name -> hash
+	for hash, p := range older {
+		for _, c := range p.Copies {
+			oldByName[takeoutName(oldRoot, c.MediaPath)] = hash
+		}
+	}
+	var added, removed, changed []diffedFile
+	var metaChanged []metaChange
+	unchanged := 0
+	replaced := make(map[string]bool) // old hashes superseded by changed content
+	for hash, p := range newer {
+		name := takeoutName(newRoot, p.SrcPath)
+		old, ok := older[hash]
+		if !ok {
+			if oldHash, same := oldByName[name]; same {
+				changed = append(changed, diffedFile{name, p})
+				replaced[oldHash] = true
+			} else {
+				added = append(added, diffedFile{name, p})
+			}
+			continue
+		}
+		if fields := diffJSONMeta(old, p); len(fields) > 0 {
+			metaChanged = append(metaChanged, metaChange{name, fields})
+		} else {
+			unchanged++
+		}
+	}
+	for hash, p := range older {
+		if _, ok := newer[hash]; !ok && !replaced[hash] {
+			removed = append(removed, diffedFile{takeoutName(oldRoot, p.SrcPath), p})
+		}
+	}
+	for _, list := range [][]diffedFile{added, removed, changed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	}
+	sort.Slice(metaChanged, func(i, j int) bool { return metaChanged[i].name < metaChanged[j].name })
+
+	fmt.Printf("Comparing %s -> %s\n", oldRoot, newRoot)
+	fmt.Printf("Unchanged: %d\n", unchanged)
+	fmt.Printf("Added: %d\n", len(added))
+	if *verbose {
+		for _, f := range added {
+			fmt.Printf("  + %s\n", f.name)
+		}
+	}
+	fmt.Printf("Removed: %d\n", len(removed))
+	if *verbose {
+		for _, f := range removed {
+			fmt.Printf("  - %s\n", f.name)
+		}
+	}
+	fmt.Printf("Content changed: %d\n", len(changed))
+	if *verbose {
+		for _, f := range changed {
+			fmt.Printf("  ~ %s\n", f.name)
+		}
+	}
+	fmt.Printf("Metadata changed only: %d\n", len(metaChanged))
+	if *verbose {
+		for _, c := range metaChanged {
+			fmt.Printf("  m %s (%s)\n", c.name, strings.Join(c.fields, ", "))
+		}
+	}
+	if len(removed) == 0 {
+		fmt.Println("Every file of the old export is in the new one.")
+	} else {
+		console.Warnf("%d files of the old export are missing from the new one\n", len(removed))
+	}
+	return exitOK
+}
+
+// indexTakeout scans and hashes one Takeout, reusing and updating its hash
+// cache.
+func indexTakeout(root, stateDir string) (map[string]*models.Photo, int) {
+	takeoutState, err := resolveTakeoutState(stateDir, root)
+	if err != nil {
+		console.Errorln("State dir error:", err)
+		return nil, exitUsage
+	}
+	lock, err := lockTakeout(takeoutState)
+	if err != nil {
+		console.Errorln("Lock error:", err)
+		return nil, exitFailure
+	}
+	defer lock.Release()
+
+	pairs, unreadable, err := scanner.ScanTakeout(root, false)
+	if err != nil {
+		console.Errorln("Scan error:", err)
+		return nil, exitScanError
+	}
+	printUnreadable(unreadable)
+	pairs, _ = scanner.CollapseOverlappingParts(root, pairs)
+	bar := newProgressBar("Hashing " + filepath.Base(filepath.Clean(root)))
+	registry := dedup.BuildRegistry(pairs, filepath.Join(takeoutState, "hash_cache.json"), false, false, bar.Update)
+	bar.Finish()
+	return registry, exitOK
+}
+
+// takeoutName is path relative to the Takeout's "Google Photos" folder, or to
+// root when there is none, so the same file matches across exports whose
+// part folders are named differently.
+func takeoutName(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, part := range parts {
+		if strings.EqualFold(part, "Google Photos") && i+1 < len(parts) {
+			return strings.Join(parts[i+1:], "/")
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// diffJSONMeta names the user-visible JSON fields and albums that differ
+// between two copies of the same content. View counts and modification
+// times change on every export and are ignored.
+func diffJSONMeta(older, newer *models.Photo) []string {
+	a, _ := metadata.ParseJSONMeta(older.JsonPath)
+	b, _ := metadata.ParseJSONMeta(newer.JsonPath)
+	var fields []string
+	check := func(name string, differs bool) {
+		if differs {
+			fields = append(fields, name)
+		}
+	}
+	check("taken", a.HasPhotoTaken != b.HasPhotoTaken || !a.PhotoTakenTime.Equal(b.PhotoTakenTime))
+	check("description", a.Description != b.Description)
+	check("favorite", a.Favorited != b.Favorited)
+	check("people", strings.Join(sortedCopy(a.People), "\x00") != strings.Join(sortedCopy(b.People), "\x00"))
+	check("location", a.HasGeo != b.HasGeo || a.Geo.Latitude != b.Geo.Latitude || a.Geo.Longitude != b.Geo.Longitude)
+	check("archived", a.Archived != b.Archived)
+	check("trashed", a.Trashed != b.Trashed)
+	check("albums", strings.Join(albumNames(older), "\x00") != strings.Join(albumNames(newer), "\x00"))
+	return fields
+}
+
+func sortedCopy(values []string) []string {
+	out := append([]string(nil), values...)
+	sort.Strings(out)
+	return out
+}
+
+func albumNames(p *models.Photo) []string {
+	names := make([]string, 0, len(p.Albums))
+	for album := range p.Albums {
+		names = append(names, album)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"gphotos/core/console"
+	"gphotos/core/fsutil"
+	"gphotos/core/output"
+)
+
+// journalPath is where -reorganize-in-place records its renames.
+func journalPath(inRoot string) string {
+	return filepath.Join(inRoot, ".gphotos", "rename_journal.jsonl")
+}
+
+// lockRoot takes the lock that keeps two gphotos runs from working on the
+// same output folder at once.
+func lockRoot(root string) (*fsutil.Lock, error) {
+	return fsutil.AcquireLock(filepath.Join(root, ".gphotos", "gphotos.lock"))
+}
+
+// lockTakeout takes the lock of a Takeout root, kept in its state folder so
+// the Takeout itself is not written.
+func lockTakeout(takeoutState string) (*fsutil.Lock, error) {
+	return fsutil.AcquireLock(filepath.Join(takeoutState, "gphotos.lock"))
+}
+
+// runUndo implements `gphotos undo`, which reverts the renames made by
+// -reorganize-in-place runs on a Takeout folder.
+func runUndo(args []string) int {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Print the moves without performing them")
+	stateDir := fs.String("state-dir", "", "State folder used by the run being undone (default $XDG_DATA_HOME/gphotos)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gphotos undo [-dry-run] <takeout-root>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	takeoutState, err := resolveTakeoutState(*stateDir, fs.Arg(0))
+	if err != nil {
+		console.Errorln("State dir error:", err)
+		return exitUsage
+	}
+	lock, err := lockTakeout(takeoutState)
+	if err != nil {
+		console.Errorln("Lock error:", err)
+		return exitFailure
+	}
+	defer lock.Release()
+
+	restored, skipped, err := output.UndoJournal(journalPath(fs.Arg(0)), *dryRun)
+	if err != nil {
+		console.Errorln("Undo error:", err)
+		return exitFailure
+	}
+	fmt.Printf("Files restored: %d\n", restored)
+	if skipped > 0 {
+		console.Warnf("Skipped %d entries whose files were moved or replaced since\n", skipped)
+		return exitPartial
+	}
+	fmt.Println(console.Success("Undo complete."))
+	return exitOK
+}